@@ -0,0 +1,60 @@
+// Package adapters drives mova machines from external event streams.
+//
+// All adapters accept events encoded as JSON object:
+//
+//	{"event": "ACCEL", "data": {"x": 1, "y": 2, "z": 3}}
+//
+// The keys of data are the event-data names as used in machine files. An optional "id"
+// is used by Consumer to deduplicate redelivered events, see mova.WithDedup.
+package adapters
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/friedelschoen/mova"
+)
+
+// Sink receives decoded events, e.g. a *mova.StateMachine.
+type Sink interface {
+	EmitContext(ctx context.Context, name string, v any) error
+}
+
+var _ Sink = (*mova.StateMachine)(nil)
+
+type message struct {
+	ID    string          `json:"id"`
+	Event string          `json:"event"`
+	Data  json.RawMessage `json:"data"`
+}
+
+// decode decodes a single message into its event name and event-data.
+func decode(reg *mova.Registry, raw []byte) (string, any, error) {
+	ev, err := decodeEvent(reg, raw)
+	return ev.Name, ev.Data, err
+}
+
+func decodeEvent(reg *mova.Registry, raw []byte) (mova.Event, error) {
+	var msg message
+	if err := json.Unmarshal(raw, &msg); err != nil {
+		return mova.Event{}, fmt.Errorf("invalid message: %w", err)
+	}
+	if msg.Event == "" {
+		return mova.Event{}, fmt.Errorf("invalid message: missing event")
+	}
+	v, err := reg.Decode(msg.Event, msg.Data)
+	if err != nil {
+		return mova.Event{}, err
+	}
+	return mova.Event{ID: msg.ID, Name: msg.Event, Data: v}, nil
+}
+
+// emit decodes a single message and passes it to sink.
+func emit(ctx context.Context, reg *mova.Registry, sink Sink, raw []byte) error {
+	name, v, err := decode(reg, raw)
+	if err != nil {
+		return err
+	}
+	return sink.EmitContext(ctx, name, v)
+}
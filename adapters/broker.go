@@ -0,0 +1,127 @@
+package adapters
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/friedelschoen/mova"
+)
+
+// Message is a message received from a broker such as Kafka or NATS.
+type Message struct {
+	ID      string // used to deduplicate redeliveries if the message has no id, e.g. topic/partition/offset
+	Subject string // topic or subject the message was received on
+	Data    []byte
+	Ack     func() error // acknowledges the message, e.g. commits its Kafka offset or acks it to NATS
+	Nack    func() error // requests a redelivery, may be nil
+}
+
+// Source receives messages from a broker. The modules github.com/friedelschoen/mova/kafkamova
+// and github.com/friedelschoen/mova/natsmova implement it for Kafka readers and NATS
+// subscriptions, other brokers are wrapped like
+//
+//	func (s amqpSource) Receive(ctx context.Context) (adapters.Message, error) {
+//		select {
+//		case <-ctx.Done():
+//			return adapters.Message{}, ctx.Err()
+//		case d, ok := <-s.deliveries:
+//			if !ok {
+//				return adapters.Message{}, io.EOF
+//			}
+//			return adapters.Message{
+//				ID: d.MessageId, Subject: d.RoutingKey, Data: d.Body,
+//				Ack:  func() error { return d.Ack(false) },
+//				Nack: func() error { return d.Nack(false, true) },
+//			}, nil
+//		}
+//	}
+type Source interface {
+	// Receive waits for the next message, it returns io.EOF if there are no more messages.
+	Receive(ctx context.Context) (Message, error)
+}
+
+// Decoder decodes a message into an event.
+type Decoder func(reg *mova.Registry, msg Message) (mova.Event, error)
+
+// DecodeJSON decodes messages encoded as JSON message, see the package documentation.
+func DecodeJSON(reg *mova.Registry, msg Message) (mova.Event, error) {
+	return decodeEvent(reg, msg.Data)
+}
+
+// DecodeSubject decodes messages whose subject is the name of the event and whose data
+// is the encoded event-data, such as a NATS message on subject `payment.received`
+// for an event of that name. The data is decoded by Registry.DecodeBytes, thus JSON or
+// the wire format of a protobuf trigger.
+func DecodeSubject(reg *mova.Registry, msg Message) (mova.Event, error) {
+	v, err := reg.DecodeBytes(msg.Subject, msg.Data)
+	if err != nil {
+		return mova.Event{}, err
+	}
+	return mova.Event{Name: msg.Subject, Data: v}, nil
+}
+
+// Consumer routes messages of a broker to the instances of a router. A message is acknowledged
+// once its event was handled, ignored as unhandled or duplicate, or cannot be decoded or routed
+// at all. If handling the event fails, the message is passed to Nack for a redelivery.
+type Consumer struct {
+	Source  Source
+	Router  *mova.Router
+	Decoder Decoder              // DecodeJSON if nil
+	OnError func(Message, error) // called for messages which failed, may be nil
+}
+
+// Run consumes messages until the source is exhausted or ctx is cancelled.
+// Errors acknowledging messages stop consuming.
+func (c *Consumer) Run(ctx context.Context) error {
+	for {
+		msg, err := c.Source.Receive(ctx)
+		if errors.Is(err, io.EOF) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if err := c.handle(ctx, msg); err != nil {
+			return err
+		}
+	}
+}
+
+func (c *Consumer) handle(ctx context.Context, msg Message) error {
+	decode := c.Decoder
+	if decode == nil {
+		decode = DecodeJSON
+	}
+	ev, err := decode(c.Router.Machine().Registry(), msg)
+	if err == nil {
+		if ev.ID == "" {
+			ev.ID = msg.ID
+		}
+		_, err = c.Router.Route(ctx, ev)
+		if errors.Is(err, io.EOF) || errors.Is(err, mova.ErrDuplicateEvent) {
+			err = nil
+		}
+		if err != nil && !errors.Is(err, mova.ErrNoCorrelationKey) {
+			// the event may succeed when redelivered
+			if c.OnError != nil {
+				c.OnError(msg, err)
+			}
+			if msg.Nack != nil {
+				if nerr := msg.Nack(); nerr != nil {
+					return fmt.Errorf("unable to nack message %s: %w", msg.ID, nerr)
+				}
+			}
+			return nil
+		}
+	}
+	if err != nil && c.OnError != nil {
+		c.OnError(msg, err)
+	}
+	if msg.Ack != nil {
+		if aerr := msg.Ack(); aerr != nil {
+			return fmt.Errorf("unable to ack message %s: %w", msg.ID, aerr)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,180 @@
+package adapters
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"reflect"
+	"time"
+
+	"github.com/friedelschoen/mova"
+)
+
+// CloudEvent is an event as specified by CloudEvents 1.0, in its JSON format.
+type CloudEvent struct {
+	SpecVersion     string          `json:"specversion"`
+	ID              string          `json:"id"`
+	Source          string          `json:"source"`
+	Type            string          `json:"type"` // name of the trigger
+	Subject         string          `json:"subject,omitempty"`
+	Time            time.Time       `json:"time,omitzero"`
+	DataContentType string          `json:"datacontenttype,omitempty"`
+	Data            json.RawMessage `json:"data,omitempty"`
+	DataBase64      []byte          `json:"data_base64,omitempty"` // binary data, e.g. a protobuf message
+}
+
+// Event decodes the data of ev into the event-data of the trigger named by its type. Binary data
+// is decoded by Registry.DecodeBytes. Source and ID identify the event for deduplication.
+func (ev CloudEvent) Event(reg *mova.Registry) (mova.Event, error) {
+	if ev.SpecVersion != "1.0" {
+		return mova.Event{}, fmt.Errorf("invalid cloudevent: unsupported specversion %q", ev.SpecVersion)
+	}
+	if ev.Type == "" {
+		return mova.Event{}, fmt.Errorf("invalid cloudevent: missing type")
+	}
+	var v any
+	var err error
+	if ev.DataBase64 != nil {
+		v, err = reg.DecodeBytes(ev.Type, ev.DataBase64)
+	} else {
+		v, err = reg.Decode(ev.Type, ev.Data)
+	}
+	if err != nil {
+		return mova.Event{}, err
+	}
+	id := ev.ID
+	if ev.Source != "" {
+		id = ev.Source + " " + ev.ID
+	}
+	return mova.Event{ID: id, Name: ev.Type, Data: v}, nil
+}
+
+// DecodeCloudEvent decodes messages encoded as CloudEvent in JSON format.
+func DecodeCloudEvent(reg *mova.Registry, msg Message) (mova.Event, error) {
+	var ev CloudEvent
+	if err := json.Unmarshal(msg.Data, &ev); err != nil {
+		return mova.Event{}, fmt.Errorf("invalid cloudevent: %w", err)
+	}
+	return ev.Event(reg)
+}
+
+// readCloudEvent reads a CloudEvent in structured or binary content mode from r.
+func readCloudEvent(r *http.Request, body []byte) (CloudEvent, error) {
+	typ, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if typ == "application/cloudevents+json" {
+		var ev CloudEvent
+		if err := json.Unmarshal(body, &ev); err != nil {
+			return CloudEvent{}, fmt.Errorf("invalid cloudevent: %w", err)
+		}
+		return ev, nil
+	}
+	ev := CloudEvent{
+		SpecVersion:     r.Header.Get("Ce-Specversion"),
+		ID:              r.Header.Get("Ce-Id"),
+		Source:          r.Header.Get("Ce-Source"),
+		Type:            r.Header.Get("Ce-Type"),
+		Subject:         r.Header.Get("Ce-Subject"),
+		DataContentType: typ,
+	}
+	if typ == "" || typ == "application/json" || typ == "text/json" {
+		ev.Data = body
+	} else {
+		ev.DataBase64 = body
+	}
+	return ev, nil
+}
+
+// CloudEventHandler accepts CloudEvents POSTed in structured or binary content mode,
+// it responds like Handler.
+func CloudEventHandler(reg *mova.Registry, sink Sink) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, MaxMessageSize))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		ce, err := readCloudEvent(r, body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		ev, err := ce.Event(reg)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		respond(w, sink.EmitContext(r.Context(), ev.Name, ev.Data))
+	})
+}
+
+var (
+	contextType = reflect.TypeFor[context.Context]()
+	machineType = reflect.TypeFor[*mova.StateMachine]()
+	errorType   = reflect.TypeFor[error]()
+)
+
+// NewCloudEventAction registers the action name, which publishes a CloudEvent of type typ using
+// send. The action takes the exported fields of the struct T as arguments, which are sent as
+// data. For example with
+//
+//	type Shipped struct{ Order, Carrier string }
+//
+// the action is called as `shipped(Order=id, Carrier="dhl")`. The subject of the event is the
+// ID of the instance.
+func NewCloudEventAction[T any](reg *mova.Registry, name, typ, source string, send func(context.Context, CloudEvent) error) {
+	data := reflect.TypeFor[T]()
+	if data.Kind() != reflect.Struct {
+		panic(fmt.Errorf("cloudevent data must be a struct, got %v", data))
+	}
+	ins := []reflect.Type{contextType, machineType}
+	var args []string
+	var fields []int
+	for i := range data.NumField() {
+		if f := data.Field(i); f.IsExported() {
+			ins = append(ins, f.Type)
+			args = append(args, f.Name)
+			fields = append(fields, i)
+		}
+	}
+	fnType := reflect.FuncOf(ins, []reflect.Type{errorType}, false)
+	fn := reflect.MakeFunc(fnType, func(in []reflect.Value) []reflect.Value {
+		ctx := in[0].Interface().(context.Context)
+		m := in[1].Interface().(*mova.StateMachine)
+		v := reflect.New(data).Elem()
+		for i, f := range fields {
+			v.Field(f).Set(in[2+i])
+		}
+		err := publish(ctx, m, typ, source, v.Interface(), send)
+		return []reflect.Value{reflect.ValueOf(&err).Elem()}
+	})
+	mova.NewAction(reg, name, args, fn.Interface())
+}
+
+func publish(ctx context.Context, m *mova.StateMachine, typ, source string, data any, send func(context.Context, CloudEvent) error) error {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	var id [16]byte
+	rand.Read(id[:])
+	return send(ctx, CloudEvent{
+		SpecVersion:     "1.0",
+		ID:              hex.EncodeToString(id[:]),
+		Source:          source,
+		Type:            typ,
+		Subject:         m.ID,
+		Time:            time.Now(),
+		DataContentType: "application/json",
+		Data:            raw,
+	})
+}
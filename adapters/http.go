@@ -0,0 +1,48 @@
+package adapters
+
+import (
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/friedelschoen/mova"
+)
+
+// MaxMessageSize limits the size of a single message received over HTTP or websockets.
+const MaxMessageSize = 1 << 20
+
+// Handler accepts events POSTed as JSON message. It responds with
+// 204 if the event was handled, 409 if no trigger matched the event,
+// 400 if the message is invalid and 500 if handling the event failed.
+func Handler(reg *mova.Registry, sink Sink) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, MaxMessageSize))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		name, v, err := decode(reg, body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		respond(w, sink.EmitContext(r.Context(), name, v))
+	})
+}
+
+// respond writes the status of handling an event, see Handler.
+func respond(w http.ResponseWriter, err error) {
+	switch {
+	case err == nil:
+		w.WriteHeader(http.StatusNoContent)
+	case errors.Is(err, io.EOF):
+		http.Error(w, "event not handled in current state", http.StatusConflict)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,32 @@
+package adapters
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/friedelschoen/mova"
+)
+
+// ReadJSONLines emits every line of r until r is exhausted or ctx is cancelled.
+// Empty lines and events not matching any trigger are skipped, any other error stops reading.
+func ReadJSONLines(ctx context.Context, r io.Reader, reg *mova.Registry, sink Sink) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 1<<20)
+	for linenr := 1; scanner.Scan(); linenr++ {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		if err := emit(ctx, reg, sink, line); err != nil && !errors.Is(err, io.EOF) {
+			return fmt.Errorf("line %d: %w", linenr, err)
+		}
+	}
+	return scanner.Err()
+}
@@ -0,0 +1,63 @@
+package adapters
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/coder/websocket"
+	"github.com/coder/websocket/wsjson"
+	"github.com/friedelschoen/mova"
+)
+
+type wsReply struct {
+	Event string `json:"event"`
+	Error string `json:"error,omitempty"`
+}
+
+// WebSocketHandler accepts websocket connections and emits every text message received as JSON message.
+// Each message is answered with {"event": name} or, if it failed, {"event": name, "error": reason}.
+// Events not matching any trigger are not considered failed.
+func WebSocketHandler(reg *mova.Registry, sink Sink, opts *websocket.AcceptOptions) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := websocket.Accept(w, r, opts)
+		if err != nil {
+			return // Accept already responded
+		}
+		defer conn.CloseNow()
+		conn.SetReadLimit(MaxMessageSize)
+		if err := serveWebSocket(r.Context(), conn, reg, sink); err != nil {
+			conn.Close(websocket.StatusInternalError, err.Error())
+			return
+		}
+		conn.Close(websocket.StatusNormalClosure, "")
+	})
+}
+
+func serveWebSocket(ctx context.Context, conn *websocket.Conn, reg *mova.Registry, sink Sink) error {
+	for {
+		typ, data, err := conn.Read(ctx)
+		if websocket.CloseStatus(err) == websocket.StatusNormalClosure || websocket.CloseStatus(err) == websocket.StatusGoingAway {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if typ != websocket.MessageText {
+			return errors.New("expected text message")
+		}
+		var reply wsReply
+		name, v, err := decode(reg, data)
+		if err == nil {
+			reply.Event = name
+			err = sink.EmitContext(ctx, name, v)
+		}
+		if err != nil && !errors.Is(err, io.EOF) {
+			reply.Error = err.Error()
+		}
+		if err := wsjson.Write(ctx, conn, reply); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,187 @@
+package mova
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+)
+
+// The analysis works on the transition graph of a machine: an edge leads from a state to every
+// destination of a `move` in its init actions or in one of its triggers. Moves in init actions are
+// taken on entering the state, without an event. Conditions on event-data are assumed to be
+// satisfiable and triggers without a move are ignored.
+
+// Step is a transition on a path through a machine.
+type Step struct {
+	Event string // trigger causing the transition, empty for moves in init actions
+	State string // state entered
+}
+
+type edge struct {
+	event string
+	dest  string
+}
+
+// edges returns the transitions out of state using events in alphabet, all events if alphabet is nil.
+func (cm *CompiledMachine) edges(state *CompiledState, alphabet []string) []edge {
+	var out []edge
+	if len(state.initMoves) > 0 {
+		// the state is left before any event can be handled
+		for _, dest := range state.initMoves {
+			out = append(out, edge{"", dest})
+		}
+		return out
+	}
+	for _, trg := range state.Triggers {
+		for _, cond := range trg.cond {
+			if alphabet != nil && !slices.Contains(alphabet, cond.TriggerName) {
+				continue
+			}
+			for _, dest := range trg.moves {
+				out = append(out, edge{cond.TriggerName, dest})
+			}
+		}
+	}
+	return out
+}
+
+func (cm *CompiledMachine) state(name string) (*CompiledState, error) {
+	st, ok := cm.states[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown state %q", name)
+	}
+	return st, nil
+}
+
+// Path returns a shortest path from one state to another using events in alphabet,
+// or all events if alphabet is nil. It reports false if to is not reachable.
+func (cm *CompiledMachine) Path(from, to string, alphabet []string) ([]Step, bool, error) {
+	if _, err := cm.state(from); err != nil {
+		return nil, false, err
+	}
+	if _, err := cm.state(to); err != nil {
+		return nil, false, err
+	}
+	if from == to {
+		return nil, true, nil
+	}
+	type visit struct {
+		prev string
+		step Step
+	}
+	visited := map[string]visit{from: {}}
+	queue := []string{from}
+	for len(queue) > 0 {
+		cur := queue[0]
+		queue = queue[1:]
+		for _, e := range cm.edges(cm.states[cur], alphabet) {
+			if _, ok := visited[e.dest]; ok {
+				continue
+			}
+			visited[e.dest] = visit{cur, Step{e.event, e.dest}}
+			if e.dest == to {
+				var path []Step
+				for s := to; s != from; s = visited[s].prev {
+					path = append(path, visited[s].step)
+				}
+				slices.Reverse(path)
+				return path, true, nil
+			}
+			queue = append(queue, e.dest)
+		}
+	}
+	return nil, false, nil
+}
+
+// Reachable reports whether state can be reached from the initial state.
+func (cm *CompiledMachine) Reachable(state string) (bool, error) {
+	_, ok, err := cm.Path(cm.order[0], state, nil)
+	return ok, err
+}
+
+// reachable returns all states reachable from the initial state.
+func (cm *CompiledMachine) reachable() map[string]bool {
+	seen := map[string]bool{cm.order[0]: true}
+	queue := []string{cm.order[0]}
+	for len(queue) > 0 {
+		cur := queue[0]
+		queue = queue[1:]
+		for _, e := range cm.edges(cm.states[cur], nil) {
+			if !seen[e.dest] {
+				seen[e.dest] = true
+				queue = append(queue, e.dest)
+			}
+		}
+	}
+	return seen
+}
+
+// Unreachable returns the states which cannot be reached from the initial state, sorted by name.
+func (cm *CompiledMachine) Unreachable() []string {
+	seen := cm.reachable()
+	var out []string
+	for _, name := range slices.Sorted(maps.Keys(cm.states)) {
+		if !seen[name] {
+			out = append(out, name)
+		}
+	}
+	return out
+}
+
+// Deadlocks returns the reachable states which cannot be left and are not marked `final`, sorted by name.
+func (cm *CompiledMachine) Deadlocks() []string {
+	var out []string
+	for _, name := range slices.Sorted(maps.Keys(cm.reachable())) {
+		st := cm.states[name]
+		if !st.Final && len(cm.edges(st, nil)) == 0 {
+			out = append(out, name)
+		}
+	}
+	return out
+}
+
+// Eventually reports whether every path from one state using events in alphabet, or all events if
+// alphabet is nil, reaches another. Otherwise it returns a counterexample, a path which ends in a
+// state that cannot be left or runs into a cycle avoiding to.
+func (cm *CompiledMachine) Eventually(from, to string, alphabet []string) (bool, []Step, error) {
+	if _, err := cm.state(from); err != nil {
+		return false, nil, err
+	}
+	if _, err := cm.state(to); err != nil {
+		return false, nil, err
+	}
+	// states from which every path reaches to, computed as fixpoint
+	good := map[string]bool{to: true}
+	for changed := true; changed; {
+		changed = false
+		for _, name := range cm.order {
+			if good[name] {
+				continue
+			}
+			edges := cm.edges(cm.states[name], alphabet)
+			if len(edges) > 0 && !slices.ContainsFunc(edges, func(e edge) bool { return !good[e.dest] }) {
+				good[name] = true
+				changed = true
+			}
+		}
+	}
+	if good[from] {
+		return true, nil, nil
+	}
+	// follow edges avoiding good states until a dead end or a cycle
+	var path []Step
+	visited := map[string]bool{from: true}
+	for cur := from; ; {
+		i := slices.IndexFunc(cm.edges(cm.states[cur], alphabet), func(e edge) bool { return !good[e.dest] })
+		if i == -1 {
+			return false, path, nil
+		}
+		e := cm.edges(cm.states[cur], alphabet)[i]
+		path = append(path, Step{e.event, e.dest})
+		if visited[e.dest] {
+			return false, path, nil
+		}
+		visited[e.dest] = true
+		cur = e.dest
+	}
+}
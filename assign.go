@@ -0,0 +1,238 @@
+package mova
+
+import (
+	"fmt"
+	"maps"
+	"reflect"
+	"strings"
+)
+
+// AssignStmt writes an instance variable, written as
+//
+//	set retries = 3
+//
+// Instance variables are declared by assigning them. They are visible to all states and read as
+// the zero value of their type until assigned, which is the type of the first assignment.
+// Variables declared by the state using `var` are set instead, see VarDecl.
+type AssignStmt struct {
+	Span  Span
+	Name  string
+	Value Value
+}
+
+func (as *AssignStmt) CheckType(ctx map[string]Value, m *CompiledMachine) error {
+	valuetype, err := as.Value.EvalType(ctx)
+	if err != nil {
+		return fmt.Errorf("cannot determine type of variable %q: %w", as.Name, err)
+	}
+	typ := m.vartypes[as.Name]
+	if v, ok := ctx[as.Name].(*localVar); ok {
+		typ = v.typ
+		m.locals[as] = typ
+	} else if typ == nil || ctx[as.Name] != m.constants[as.Name] {
+		return fmt.Errorf("cannot set %q: not an instance variable", as.Name)
+	}
+	if !coercible(valuetype, typ) {
+		return fmt.Errorf("type mismatch for variable %q: expected %v, got %s", as.Name, typ, typeString(valuetype))
+	}
+	return nil
+}
+
+func (as *AssignStmt) Execute(cm *CompiledMachine) Action {
+	typ, local := cm.locals[as]
+	if !local {
+		typ = cm.vartypes[as.Name]
+	}
+	return func(m *StateMachine, ctx map[string]Value) error {
+		eval, err := as.Value.EvalValue(ctx)
+		if err != nil {
+			return err
+		}
+		if m.vars == nil {
+			m.vars = make(map[string]any)
+		}
+		vars := m.vars
+		if local {
+			vars = m.locals
+		}
+		prev, existed := vars[as.Name]
+		if m.tx != nil {
+			m.tx.OnRollback(func() error {
+				if existed {
+					vars[as.Name] = prev
+				} else {
+					delete(vars, as.Name)
+				}
+				return nil
+			})
+		}
+		eval = coerce(eval, typ)
+		vars[as.Name] = eval
+		ctx[as.Name] = &ConstValue{eval} // visible to the following actions
+		return nil
+	}
+}
+
+func (as *AssignStmt) String() string {
+	return "set " + formatName(as.Name) + " = " + formatValue(as.Value)
+}
+
+// Vars returns the instance variables, unassigned ones with their zero value.
+func (m *StateMachine) Vars() map[string]any {
+	vars := make(map[string]any, len(m.vartypes))
+	for name, typ := range m.vartypes {
+		if v, ok := m.vars[name]; ok {
+			vars[name] = v
+		} else {
+			vars[name] = reflect.Zero(typ).Interface()
+		}
+	}
+	return vars
+}
+
+// declareVars determines the instance variables of the file and their types before type checking,
+// so states may read variables assigned by states later in the file.
+func (cm *CompiledMachine) declareVars() error {
+	scope := maps.Clone(cm.constants)
+	for _, entry := range cm.file.Entries {
+		if e, ok := entry.(*SetStmt); ok {
+			scope[e.Key] = e.Value
+		}
+	}
+	// instance variables declared using var, which may be initialized from the ones before
+	globals := maps.Clone(scope)
+	for _, entry := range cm.file.Entries {
+		decl, ok := entry.(*VarDecl)
+		if !ok {
+			continue
+		}
+		if _, ok := cm.vartypes[decl.Name]; ok {
+			return located(decl.Span, fmt.Errorf("duplicate variable %q", decl.Name))
+		}
+		if _, ok := scope[decl.Name]; ok || strings.HasPrefix(decl.Name, "self.") {
+			return located(decl.Span, fmt.Errorf("cannot declare variable %q: it is a constant", decl.Name))
+		}
+		v, err := decl.check(globals, cm)
+		if err != nil {
+			return located(decl.Span, err)
+		}
+		if cm.vartypes == nil {
+			cm.vartypes = make(map[string]reflect.Type)
+		}
+		cm.vartypes[decl.Name] = v.typ
+		cm.globals = append(cm.globals, v)
+		cm.constants[decl.Name] = &TypeDummyValue{v.typ}
+		globals[decl.Name] = cm.constants[decl.Name]
+	}
+	var assigns []*AssignStmt
+	contexts := make(map[*AssignStmt]map[string]Value)
+	// declared are the variables of the state, which are not instance variables unless shadowed
+	collect := func(stmts []Statement, local map[string]Value, declared map[string]bool) {
+		for _, stmt := range stmts {
+			Inspect(stmt, func(n Node) bool {
+				switch n := n.(type) {
+				case *AssignStmt:
+					if _, shadowed := local[n.Name].(*TypeDummyValue); declared[n.Name] && !shadowed {
+						break
+					}
+					assigns = append(assigns, n)
+					contexts[n] = local
+				case *CaptureStmt:
+					if spec, ok := cm.reg.action(n.Call.Name); ok {
+						if typ := resultType(spec.Function.Type()); typ != nil {
+							local[n.Name] = &TypeDummyValue{typ}
+						}
+					}
+				}
+				return true
+			})
+		}
+	}
+	for _, entry := range cm.file.Entries {
+		st, ok := entry.(*State)
+		if !ok {
+			continue
+		}
+		declared := make(map[string]bool)
+		locals := make(map[string]Value)
+		ctx := maps.Clone(globals)
+		for _, decl := range st.Vars {
+			declared[decl.Name] = true
+			if v, err := decl.check(ctx, cm); err == nil {
+				locals[decl.Name] = &localVar{TypeDummyValue{v.typ}}
+				ctx[decl.Name] = locals[decl.Name]
+			}
+		}
+		params := maps.Clone(locals)
+		for _, p := range st.Params {
+			if typ, ok := cm.reg.typeByName(p.Type); ok {
+				params[p.Name] = &TypeDummyValue{typ}
+			}
+		}
+		collect(st.Init, params, declared)
+		collect(st.Compensate, params, declared)
+		for _, trg := range st.Triggers {
+			bound := maps.Clone(locals)
+			for _, c := range trg.Cond {
+				typ, ok := cm.reg.trigger(c.Name)
+				if !ok {
+					continue
+				}
+				typ = dataType(typ)
+				for _, p := range c.Params {
+					if i := getTypeField(typ, p.Key); i != -1 {
+						bound[p.Key] = &TypeDummyValue{typ.Field(i).Type}
+					}
+				}
+			}
+			collect(trg.Actions, bound, declared)
+		}
+	}
+	for _, as := range assigns {
+		if _, ok := scope[as.Name]; ok || strings.HasPrefix(as.Name, "self.") {
+			return located(as.Span, fmt.Errorf("cannot set constant %q", as.Name))
+		}
+		if cm.vartypes == nil {
+			cm.vartypes = make(map[string]reflect.Type)
+		}
+		if _, ok := cm.vartypes[as.Name]; !ok {
+			cm.vartypes[as.Name] = nil // type determined below
+		}
+	}
+	// variables may be assigned from other variables, so types are inferred until nothing changes
+	untyped := make(map[string]*AssignStmt) // assigned nil, which has no type
+	for changed := true; changed; {
+		changed = false
+		for _, as := range assigns {
+			if cm.vartypes[as.Name] != nil {
+				continue
+			}
+			ctx := maps.Clone(scope)
+			for name, typ := range cm.vartypes {
+				if typ != nil {
+					ctx[name] = &TypeDummyValue{typ}
+				}
+			}
+			maps.Copy(ctx, contexts[as])
+			typ, err := as.Value.EvalType(ctx)
+			if err == nil && typ == nil {
+				untyped[as.Name] = as
+			} else if err == nil {
+				cm.vartypes[as.Name] = typ
+				changed = true
+			}
+		}
+	}
+	for _, as := range assigns {
+		if cm.vartypes[as.Name] == nil && untyped[as.Name] != nil {
+			return located(untyped[as.Name].Span, fmt.Errorf("cannot determine type of variable %q: untyped nil", as.Name))
+		}
+		if cm.vartypes[as.Name] == nil {
+			continue // the error is reported by type checking
+		}
+		if _, ok := cm.constants[as.Name]; !ok {
+			cm.constants[as.Name] = &TypeDummyValue{cm.vartypes[as.Name]}
+		}
+	}
+	return nil
+}
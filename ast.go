@@ -1,12 +1,14 @@
 package mova
 
 import (
+	"context"
 	"errors"
 	"fmt"
-	"log"
 	"maps"
 	"reflect"
 	"slices"
+	"strings"
+	"time"
 )
 
 type Action func(m *StateMachine, input map[string]Value) error
@@ -21,128 +23,414 @@ type Entry interface {
 }
 
 type File struct {
-	Entries []Entry
+	Filename string
+	Version  int // language version from the `mova <version>;` pragma, 0 if absent, see LanguageVersion
+	Entries  []Entry
+
+	src      []byte          // source of a file with directives, see Compile
+	features map[string]bool // features tested by its directives, whether they were enabled
 }
 
 type State struct {
-	Name     string
-	Init     []Statement
-	Triggers []Trigger
+	Span   Span
+	Final  bool
+	Name   string
+	Params []Param
+	Task   string // external task the state waits for, see Manager.Tasks
+	Vars   []*VarDecl
+	Init   []Statement
+	// Compensate undoes the effects of the state, see WithSaga. It is nil if the state has no compensate block.
+	Compensate []Statement
+	Triggers   []Trigger
+	Ignore     []string // events the state deliberately does not handle, see WithExhaustive
+}
+
+// CompileError is an error in a parsed file, located at the offending node.
+type CompileError struct {
+	Filename string
+	Span     Span
+	Err      error
+}
+
+func (e *CompileError) Error() string {
+	if e.Span.Start.Line == 0 {
+		// not parsed from source, e.g. built by MachineBuilder
+		return fmt.Sprintf("%s: %v", e.Filename, e.Err)
+	}
+	return fmt.Sprintf("%s:%d:%d: %v", e.Filename, e.Span.Start.Line, e.Span.Start.Column, e.Err)
+}
+
+func (e *CompileError) Unwrap() error {
+	return e.Err
+}
+
+// located attaches span to err unless it is already located more precisely.
+func located(span Span, err error) error {
+	var cerr *CompileError
+	if err == nil || errors.As(err, &cerr) {
+		return err
+	}
+	return &CompileError{Span: span, Err: err}
+}
+
+func inFile(filename string, err error) error {
+	var cerr *CompileError
+	if errors.As(err, &cerr) {
+		cerr.Filename = filename
+	}
+	return err
+}
+
+// statementSpan returns the source range of stmt, or def for statements without one.
+func statementSpan(stmt Statement, def Span) Span {
+	switch stmt := stmt.(type) {
+	case *MoveStmt:
+		return stmt.Span
+	case *Call:
+		return stmt.Span
+	case *ChoiceStmt:
+		return stmt.Span
+	case *ScheduleStmt:
+		return stmt.Span
+	case *AssignStmt:
+		return stmt.Span
+	case *CaptureStmt:
+		return stmt.Span
+	case *IfStmt:
+		return stmt.Span
+	case *ParallelStmt:
+		return stmt.Span
+	}
+	return def
+}
+
+type Param struct {
+	Name string
+	Type string
 }
 
-func (trg *Trigger) evalTrigger(state string, index int, m *CompiledMachine) (CompiledTrigger, error) {
+var typeNames = map[string]reflect.Type{
+	"int":      reflect.TypeFor[int64](),
+	"float":    reflect.TypeFor[float64](),
+	"string":   reflect.TypeFor[string](),
+	"bool":     reflect.TypeFor[bool](),
+	"duration": reflect.TypeFor[time.Duration](),
+}
+
+// evalTrigger compiles the trigger of state, which sees the variables in scope.
+func (trg *Trigger) evalTrigger(state string, index int, scope map[string]Value, m *CompiledMachine) (CompiledTrigger, error) {
 	var out CompiledTrigger
 
 	datatypes := make(map[string]reflect.Type)
-	local := maps.Clone(m.constants)
+	local := maps.Clone(scope)
+	mentions := make([]map[string]bool, len(trg.Cond)) // event-data mentioned by each condition
+	optional := make(map[string]bool)
 
 	for condidx, c := range trg.Cond {
-		spec, ok := m.reg.triggers[c.Name]
+		spec, ok := m.reg.trigger(c.Name)
 		if !ok {
 			return out, fmt.Errorf("in trigger %s#%d: unspecified trigger %q", state, index, c.Name)
 		}
+		spec = dataType(spec)
 
+		name, _ := m.reg.triggerName(c.Name)
 		var cond = Condition{
-			TriggerName: c.Name,
+			TriggerName: name,
 			Value:       make(map[string]any),
 		}
 
-		prevkeys := make(map[string]bool)
-		for name := range datatypes {
-			prevkeys[name] = false
+		// values compared to may refer to other event-data of the condition
+		condctx := maps.Clone(scope)
+		for _, param := range c.Params {
+			if i := getTypeField(spec, param.Key); i != -1 {
+				condctx[param.Key] = &TypeDummyValue{spec.Field(i).Type}
+			}
 		}
+
+		mentions[condidx] = make(map[string]bool)
 		for _, param := range c.Params {
+			cond.keys = append(cond.keys, param.Key)
 			i := getTypeField(spec, param.Key)
 			if i == -1 {
 				return out, fmt.Errorf("in trigger %s#%d: unspecified event-data %q for trigger %s", state, index, param.Key, c.Name)
 			}
 			argtype := spec.Field(i).Type
 			if param.Value != nil {
-				condtype, err := param.Value.EvalType(m.constants)
+				condtype, err := param.Value.EvalType(condctx)
 				if err != nil {
 					return out, fmt.Errorf("in trigger %s#%d: cannot determine type of variable for event-data %q: %w", state, index, param.Key, err)
 				}
-				if condtype != argtype {
-					return out, fmt.Errorf("in trigger %s#%d: type mismatch for event-data %q: expected %v, got %v", state, index, param.Key, argtype.Name(), condtype.Name())
+				if !coercible(condtype, argtype) {
+					got := "nil"
+					if condtype != nil {
+						got = condtype.Name()
+					}
+					return out, fmt.Errorf("in trigger %s#%d: type mismatch for event-data %q: expected %v, got %v", state, index, param.Key, argtype.Name(), got)
 				}
-				cond.Value[param.Key], err = param.Value.EvalValue(m.constants)
-				if err != nil {
+				condvalue, err := param.Value.EvalValue(condctx)
+				switch {
+				case errors.Is(err, ErrDummyNotEvaluable):
+					// refers to instance variables or event-data, compared per event
+					if cond.Deferred == nil {
+						cond.Deferred = make(map[string]Value)
+						cond.types = make(map[string]reflect.Type)
+					}
+					cond.Deferred[param.Key] = param.Value
+					cond.types[param.Key] = argtype
+				case err != nil:
 					return out, fmt.Errorf("in trigger %s#%d: cannot evaluate conditional value for event-data %q: %w", state, index, param.Key, err)
+				default:
+					condvalue = coerce(condvalue, argtype)
+					if prev, ok := cond.Value[param.Key]; ok {
+						if t := m.reg.typeFor(argtype); t != nil && t.Equal != nil {
+							cond.never = cond.never || !t.Equal(prev, condvalue)
+						} else {
+							cond.never = cond.never || prev != condvalue
+						}
+					}
+					cond.Value[param.Key] = condvalue
+				}
+				if t := m.reg.typeFor(argtype); t != nil && t.Equal != nil {
+					if cond.Equal == nil {
+						cond.Equal = make(map[string]func(a, b any) bool)
+					}
+					cond.Equal[param.Key] = t.Equal
 				}
 			}
-			prevkeys[param.Key] = true
+			mentions[condidx][param.Key] = true
+			if param.Optional {
+				optional[param.Key] = true
+			}
 			if prevtype, ok := datatypes[param.Key]; ok {
 				if prevtype != argtype {
-					return out, fmt.Errorf("in trigger %s#%d: type mismatch for event-data %q: unable to redefine to %v (previously %v)", state, index, param.Key, argtype, prevtype)
+					prevcond := slices.IndexFunc(mentions, func(mentioned map[string]bool) bool { return mentioned[param.Key] })
+					return out, fmt.Errorf("in trigger %s#%d: type mismatch for event-data %q: %v in condition #%d, but %v in condition #%d", state, index, param.Key, prevtype, prevcond, argtype, condidx)
 				}
 			} else {
 				datatypes[param.Key] = argtype
 				local[param.Key] = &TypeDummyValue{argtype}
 			}
 		}
-		for name, mentioned := range prevkeys {
-			if mentioned {
-				continue
+		out.cond = append(out.cond, cond)
+	}
+	// event-data is only bound if every condition mentions it, as any of them may fire the trigger
+	for _, name := range slices.Sorted(maps.Keys(datatypes)) {
+		condidx := slices.IndexFunc(mentions, func(mentioned map[string]bool) bool { return !mentioned[name] })
+		switch {
+		case condidx == -1:
+			continue
+		case optional[name]:
+			if out.optional == nil {
+				out.optional = make(map[string]reflect.Type)
 			}
-			log.Printf("in trigger %s#%d: dropping previous event-data %q: not mentioned in condition #%d\n", state, index, name, condidx)
-			delete(datatypes, name)
+			out.optional[name] = datatypes[name]
+		case m.bindingCheck:
+			return out, fmt.Errorf("in trigger %s#%d: event-data %q not mentioned in condition #%d, mark it as optional using %s?", state, index, name, condidx, name)
+		default:
+			m.warn(state, index, "unbound", fmt.Sprintf("dropping event-data %q: not mentioned in condition #%d", name, condidx))
 			delete(local, name)
 		}
-		out.cond = append(out.cond, cond)
+		delete(datatypes, name)
 	}
 	for _, stmt := range trg.Actions {
+		if len(statementMoves(stmt)) > 0 && slices.ContainsFunc(trg.Cond, func(c TriggerCond) bool { return c.Name == "exit" }) {
+			return out, fmt.Errorf("in trigger %s#%d: cannot move in exit actions", state, index)
+		}
 		if err := stmt.CheckType(local, m); err != nil {
-			return out, err
+			return out, located(statementSpan(stmt, trg.Span), err)
 		}
+		out.moves = append(out.moves, statementMoves(stmt)...)
+		Inspect(stmt, func(n Node) bool {
+			if c, ok := n.(*Call); ok {
+				name, _ := m.reg.actionName(c.Name)
+				out.calls = append(out.calls, name)
+			}
+			return true
+		})
 		out.actions = append(out.actions, stmt.Execute(m))
 	}
-	out.datatypes = slices.Collect(maps.Keys(datatypes))
+	out.datatypes = slices.Sorted(maps.Keys(datatypes))
 	return out, nil
 }
 
 func (st *State) EvalToplevel(m *CompiledMachine) error {
 	var outstate CompiledState
+	outstate.Name = st.Name
+	outstate.Params = make(map[string]reflect.Type)
+	local := maps.Clone(m.constants)
+	for _, param := range st.Params {
+		typ, ok := m.reg.typeByName(param.Type)
+		if !ok {
+			return fmt.Errorf("in state %s: unknown type %q for parameter %q", st.Name, param.Type, param.Name)
+		}
+		if _, ok := outstate.Params[param.Name]; ok {
+			return fmt.Errorf("in state %s: duplicate parameter %q", st.Name, param.Name)
+		}
+		outstate.Params[param.Name] = typ
+		local[param.Name] = &TypeDummyValue{typ}
+	}
+	// compensations run after the state was left, they do not see its variables
+	params := maps.Clone(local)
+	scope := maps.Clone(m.constants)
+	for _, decl := range st.Vars {
+		_, param := outstate.Params[decl.Name]
+		if _, dup := scope[decl.Name].(*localVar); param || dup {
+			return located(decl.Span, fmt.Errorf("in state %s: duplicate variable %q", st.Name, decl.Name))
+		}
+		v, err := decl.check(local, m)
+		if err != nil {
+			return located(decl.Span, fmt.Errorf("in state %s: %w", st.Name, err))
+		}
+		outstate.vars = append(outstate.vars, v)
+		local[decl.Name] = &localVar{TypeDummyValue{v.typ}}
+		scope[decl.Name] = local[decl.Name]
+	}
+	outstate.Final = st.Final
+	if st.Task != "" {
+		if !slices.ContainsFunc(st.Triggers, func(trg Trigger) bool {
+			return slices.ContainsFunc(trg.Cond, func(c TriggerCond) bool { return c.Name == st.Task })
+		}) {
+			return fmt.Errorf("in state %s: no trigger for awaited task %q", st.Name, st.Task)
+		}
+		outstate.Task = st.Task
+	}
+	for _, event := range st.Ignore {
+		if _, ok := builtinTriggers[event]; ok {
+			return fmt.Errorf("in state %s: cannot ignore builtin event %q", st.Name, event)
+		}
+		name, ok := m.reg.triggerName(event)
+		if !ok {
+			return fmt.Errorf("in state %s: unspecified event %q", st.Name, event)
+		}
+		outstate.ignore = append(outstate.ignore, name)
+	}
 	for _, stmt := range st.Init {
-		if err := stmt.CheckType(m.constants, m); err != nil {
-			return err
+		if err := stmt.CheckType(local, m); err != nil {
+			return located(statementSpan(stmt, st.Span), err)
 		}
+		outstate.initMoves = append(outstate.initMoves, statementMoves(stmt)...)
 		outstate.Init = append(outstate.Init, stmt.Execute(m))
 	}
+	if st.Compensate != nil {
+		outstate.Compensate = []Action{}
+	}
+	for _, stmt := range st.Compensate {
+		if len(statementMoves(stmt)) > 0 {
+			return located(statementSpan(stmt, st.Span), fmt.Errorf("in state %s: cannot move in compensate actions", st.Name))
+		}
+		if err := stmt.CheckType(params, m); err != nil {
+			return located(statementSpan(stmt, st.Span), err)
+		}
+		outstate.Compensate = append(outstate.Compensate, stmt.Execute(m))
+	}
 	for i, trg := range st.Triggers {
-		ctrg, err := trg.evalTrigger(st.Name, i, m)
+		ctrg, err := trg.evalTrigger(st.Name, i, scope, m)
 		if err != nil {
-			return err
+			return located(trg.Span, err)
 		}
 		outstate.Triggers = append(outstate.Triggers, ctrg)
 	}
 	m.states[st.Name] = &outstate
-	if m.firstState == "" {
-		m.firstState = st.Name
-	}
+	m.order = append(m.order, st.Name)
 	return nil
 }
 
+// SetStmt defines a constant, written as `name = value;`. Constants are defined once, a later
+// definition must be marked as `override name = value;`. Constants passed to BuildMachine cannot
+// be redefined.
 type SetStmt struct {
-	Key   string
-	Value Value
+	Span     Span
+	Key      string
+	Value    Value
+	Override bool // replaces an earlier definition
 }
 
 func (ss *SetStmt) EvalToplevel(m *CompiledMachine) error {
+	if strings.HasPrefix(ss.Key, "self.") {
+		return fmt.Errorf("cannot assign to reserved variable %q", ss.Key)
+	}
+	prev, defined := m.defined[ss.Key]
+	switch _, exists := m.constants[ss.Key]; {
+	case exists && !defined:
+		return fmt.Errorf("cannot redefine constant %q passed to BuildMachine", ss.Key)
+	case defined && !ss.Override:
+		return fmt.Errorf("constant %q is already defined at line %d, use `override %s = ...` to redefine it", ss.Key, prev.Start.Line, formatName(ss.Key))
+	case !defined && ss.Override:
+		return fmt.Errorf("cannot override constant %q: not defined before", ss.Key)
+	}
+	m.defined[ss.Key] = ss.Span
 	m.constants[ss.Key] = ss.Value
 	return nil
 }
 
 type MoveStmt struct {
+	Span Span
 	Dest string
+	Args map[string]Value
 }
 
-func (ms *MoveStmt) CheckType(_ map[string]Value, m *CompiledMachine) error {
+func (ms *MoveStmt) CheckType(ctx map[string]Value, m *CompiledMachine) error {
+	argtypes := make(map[string]reflect.Type)
+	for _, key := range slices.Sorted(maps.Keys(ms.Args)) {
+		typ, err := ms.Args[key].EvalType(ctx)
+		if err != nil {
+			return fmt.Errorf("cannot determine type of variable for entry argument %q: %w", key, err)
+		}
+		argtypes[key] = typ
+	}
+	folded, err := foldArgs(ms.Args, ctx)
+	if err != nil {
+		return err
+	}
+	m.folded[ms] = folded
+	// the destination may be declared later in the file
+	m.checks = append(m.checks, func() error {
+		return located(ms.Span, ms.checkDest(argtypes, m))
+	})
 	return nil
 }
 
-func (ms *MoveStmt) Execute(*CompiledMachine) Action {
-	return func(m *StateMachine, input map[string]Value) error {
-		return m.move(ms.Dest)
+func (ms *MoveStmt) checkDest(argtypes map[string]reflect.Type, m *CompiledMachine) error {
+	dest, ok := m.states[ms.Dest]
+	if !ok {
+		return fmt.Errorf("unknown state %q", ms.Dest)
+	}
+	for _, key := range slices.Sorted(maps.Keys(argtypes)) {
+		typ := argtypes[key]
+		partype, ok := dest.Params[key]
+		if !ok {
+			return fmt.Errorf("unspecified entry argument %q for state %s", key, ms.Dest)
+		}
+		if !coercible(typ, partype) {
+			return fmt.Errorf("type mismatch for entry argument %s.%s: expected %v, got %s", ms.Dest, key, partype, typeString(typ))
+		}
+	}
+	for _, key := range slices.Sorted(maps.Keys(dest.Params)) {
+		if _, ok := argtypes[key]; !ok {
+			return fmt.Errorf("missing entry argument %q for state %s", key, ms.Dest)
+		}
+	}
+	return nil
+}
+
+func (ms *MoveStmt) Execute(cm *CompiledMachine) Action {
+	margs := ms.Args
+	if folded, ok := cm.folded[ms]; ok {
+		margs = folded
+	}
+	return func(m *StateMachine, ctx map[string]Value) error {
+		args := make(map[string]Value, len(margs))
+		for key, value := range margs {
+			eval, err := value.EvalValue(ctx)
+			if err != nil {
+				return err
+			}
+			if dest, ok := m.states[ms.Dest]; ok {
+				eval = coerce(eval, dest.Params[key])
+			}
+			args[key] = &ConstValue{eval}
+		}
+		return m.move(ms.Dest, args)
 	}
 }
 
@@ -151,69 +439,198 @@ type TriggerCond struct {
 	Params []Arg
 }
 
+// Trigger runs its actions on an event matching any of its conditions. Conditions are alternatives,
+// a single event fires the trigger, so event-data mentioned by several conditions is not unified
+// between events: it is bound to the value in the event which fired, and must have the same type
+// in every condition.
 type Trigger struct {
+	Span    Span
 	Cond    []TriggerCond
 	Actions []Statement
 }
 
 type Call struct {
-	Name string
-	Args map[string]Value
+	Span   Span
+	Name   string
+	Args   map[string]Value
+	Policy Policy
 }
 
 func (c *Call) CheckType(ctx map[string]Value, m *CompiledMachine) error {
-	spec, ok := m.reg.actions[c.Name]
+	spec, ok := m.reg.action(c.Name)
 	if !ok {
 		return fmt.Errorf("unspecified action %q", c.Name)
 	}
-	for key, value := range c.Args {
-		i := slices.Index(spec.Inputs, key)
-		if i == -1 {
+	if spec.Invoke == nil && !reflectCall {
+		return fmt.Errorf("action %s is not callable without reflection, register it with NewAction0 to NewAction3", c.Name)
+	}
+	for _, key := range slices.Sorted(maps.Keys(c.Args)) {
+		argtype, ok := spec.arg(key)
+		if !ok {
 			return fmt.Errorf("unspecified argument %q for action %s", key, c.Name)
 		}
-		argtype := spec.Function.Type().In(i)
-		valuetype, err := value.EvalType(ctx)
+		valuetype, err := c.Args[key].EvalType(ctx)
 		if err != nil {
 			return fmt.Errorf("cannot determine type of variable for argument %q: %w", key, err)
 		}
-		if !valuetype.ConvertibleTo(argtype) && reflect.PointerTo(valuetype).ConvertibleTo(argtype) {
-			return fmt.Errorf("type mismatch for argument %s.%s: expected %v, got %v", c.Name, key, argtype, valuetype)
+		if !bindable(valuetype, argtype) {
+			return fmt.Errorf("type mismatch for argument %s.%s: expected %v, got %s", c.Name, key, argtype, typeString(valuetype))
+		}
+	}
+	folded, err := foldArgs(c.Args, ctx)
+	if err != nil {
+		return err
+	}
+	m.folded[c] = folded
+	// constant arguments are bound, and with missing arguments validated, once
+	for _, key := range slices.Sorted(maps.Keys(folded)) {
+		if cv, ok := folded[key].(*ConstValue); ok {
+			argtype, _ := spec.arg(key)
+			if _, err := bind(cv.Value, argtype); err != nil {
+				return fmt.Errorf("argument %s.%s: %w", c.Name, key, err)
+			}
+		}
+	}
+	for _, key := range slices.Sorted(maps.Keys(spec.Validators)) {
+		argtype, _ := spec.arg(key)
+		v := reflect.Zero(argtype)
+		if arg, ok := folded[key]; ok {
+			cv, isConst := arg.(*ConstValue)
+			if !isConst {
+				continue
+			}
+			if v, err = bind(cv.Value, argtype); err != nil {
+				return fmt.Errorf("argument %s.%s: %w", c.Name, key, err)
+			}
+		}
+		if err := spec.validate(c.Name, key, v); err != nil {
+			return err
 		}
 	}
 	return nil
 }
 
 func (c *Call) Execute(m *CompiledMachine) Action {
-	spec := m.reg.actions[c.Name]
+	call := c.execute(m)
 	return func(m *StateMachine, ctx map[string]Value) error {
+		_, err := call(m, ctx)
+		return err
+	}
+}
+
+// execute returns a function calling the action, which returns its result unless it is asynchronous.
+func (c *Call) execute(m *CompiledMachine) func(m *StateMachine, ctx map[string]Value) (reflect.Value, error) {
+	action, _ := m.reg.actionName(c.Name)
+	spec := m.reg.actions[action]
+	args, checked := m.folded[c]
+	if !checked {
+		args = c.Args
+	}
+	// value evaluates argument name of type argtype, the zero value if it is missing
+	value := func(ctx map[string]Value, name string, argtype reflect.Type) (reflect.Value, error) {
+		v, ok := args[name]
+		if !ok {
+			return reflect.Zero(argtype), nil
+		}
+		eval, err := v.EvalValue(ctx)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		in, err := bind(eval, argtype)
+		if err != nil {
+			return reflect.Value{}, fmt.Errorf("argument %s.%s: %w", action, name, err)
+		}
+		if _, isConst := v.(*ConstValue); !isConst || !checked {
+			if err := spec.validate(action, name, in); err != nil {
+				return reflect.Value{}, err
+			}
+		}
+		return in, nil
+	}
+	run := func(m *StateMachine, ctx map[string]Value) (reflect.Value, error) {
 		ins := make([]reflect.Value, len(spec.Inputs))
 		for i, name := range spec.Inputs {
 			argtype := spec.Function.Type().In(i)
-			v, ok := c.Args[name]
-			if ok {
-				eval, err := v.EvalValue(ctx)
-				if err != nil {
-					return err
-				}
-				if evt := reflect.ValueOf(eval); evt.CanConvert(argtype) {
-					ins[i] = evt.Convert(argtype)
-				} else if evt := reflect.ValueOf(&eval); evt.CanConvert(argtype) {
-					ins[i] = evt.Convert(argtype)
+			if argtype == machineType || argtype == machineIfaceType {
+				ins[i] = reflect.ValueOf(m)
+				continue
+			}
+			if argtype == contextType {
+				continue // set for every attempt
+			}
+			if argtype == txType {
+				if m.tx == nil {
+					ins[i] = reflect.ValueOf(&Tx{}) // not within a trigger
 				} else {
-					return fmt.Errorf("unable to convert argument %s.%s from %v to %v", c.Name, name, reflect.TypeOf(eval), argtype)
+					ins[i] = reflect.ValueOf(m.tx)
 				}
-			} else {
-				ins[i] = reflect.Zero(spec.Function.Type().In(i))
+				continue
+			}
+			if spec.params != nil && spec.params.index == i {
+				ins[i] = reflect.New(argtype).Elem()
+				for _, f := range spec.params.fields {
+					in, err := value(ctx, f.name, f.typ)
+					if err != nil {
+						return reflect.Value{}, err
+					}
+					ins[i].Field(f.index).Set(in)
+				}
+				continue
+			}
+			in, err := value(ctx, name, argtype)
+			if err != nil {
+				return reflect.Value{}, err
 			}
+			ins[i] = in
 		}
-		spec.Function.Call(ins)
-		return nil
+		policy := spec.Policy.merge(c.Policy)
+		base := m.ctx
+		if base == nil {
+			base = context.Background() // not within an event
+		}
+		state, trigger, start := m.Current(), m.trigger, m.clock.Now()
+		actx, endSpan := m.tracer.Start(base, "action "+action, map[string]any{
+			"mova.state":   state,
+			"mova.trigger": trigger,
+			"mova.action":  action,
+		})
+		end := func(err error) {
+			endSpan(err)
+			entry := JournalEntry{Kind: JournalAction, State: state, Action: action, Duration: m.clock.Now().Sub(start)}
+			if trigger != -1 {
+				entry.Trigger = &trigger
+			}
+			if err != nil {
+				entry.Error = err.Error()
+			}
+			m.record(entry)
+		}
+		if m.result != nil {
+			m.mu.Lock() // calls may run in parallel
+			m.result.Actions = append(m.result.Actions, action)
+			m.mu.Unlock()
+		}
+		if spec.Async {
+			m.runAsync(actx, action, spec, ins, policy, end)
+			return reflect.Value{}, nil
+		}
+		result, err := m.invoke(actx, action, spec, ins, policy, m.deadline)
+		end(err)
+		return result, err
+	}
+	return func(m *StateMachine, ctx map[string]Value) (result reflect.Value, err error) {
+		err = m.around(action, ctx, func(m *StateMachine, ctx map[string]Value) (err error) {
+			result, err = run(m, ctx)
+			return err
+		})
+		return result, err
 	}
 }
 
 type Arg struct {
-	Key   string
-	Value Value
+	Key      string
+	Value    Value
+	Optional bool // bound with the zero value if the event lacks it, `key?` in trigger conditions
 }
 
 type Value interface {
@@ -233,24 +650,82 @@ func (v *ConstValue) EvalType(ctx map[string]Value) (reflect.Type, error) {
 	return reflect.TypeOf(v.Value), nil
 }
 
+// ReferenceValue reads a variable. A reference `a.b.c` not naming a variable itself reads field c of
+// field b of the struct in variable a, the longest variable name wins.
 type ReferenceValue struct {
 	Ref string
 }
 
+// lookup returns the variable referenced by v and the path of fields to read from it.
+func (v *ReferenceValue) lookup(ctx map[string]Value) (Value, []string, error) {
+	if ref, ok := ctx[v.Ref]; ok {
+		return ref, nil, nil
+	}
+	for i := strings.LastIndex(v.Ref, "."); i != -1; i = strings.LastIndex(v.Ref[:i], ".") {
+		if ref, ok := ctx[v.Ref[:i]]; ok {
+			return ref, strings.Split(v.Ref[i+1:], "."), nil
+		}
+	}
+	return nil, nil, fmt.Errorf("undefined variable %q", v.Ref)
+}
+
+// fieldIndex returns the index of the exported field name of struct type typ.
+func fieldIndex(typ reflect.Type, name string) (int, error) {
+	if typ.Kind() != reflect.Struct {
+		return -1, fmt.Errorf("cannot read field %q of %v", name, typ)
+	}
+	i := getTypeField(typ, name)
+	if i == -1 || !typ.Field(i).IsExported() {
+		return -1, fmt.Errorf("unknown field %q of %v", name, typ)
+	}
+	return i, nil
+}
+
 func (v *ReferenceValue) EvalValue(ctx map[string]Value) (any, error) {
-	ref, ok := ctx[v.Ref]
-	if !ok {
-		return nil, fmt.Errorf("undefined variable %q", v.Ref)
+	ref, path, err := v.lookup(ctx)
+	if err != nil {
+		return nil, err
+	}
+	eval, err := ref.EvalValue(ctx)
+	if err != nil || len(path) == 0 {
+		return eval, err
+	}
+	val := reflect.ValueOf(eval)
+	for _, name := range path {
+		if !val.IsValid() {
+			return nil, fmt.Errorf("cannot read field %q of nil", name)
+		}
+		val = dataValue(val)
+		i, err := fieldIndex(val.Type(), name)
+		if err != nil {
+			return nil, fmt.Errorf("in %s: %w", v.Ref, err)
+		}
+		val = val.Field(i)
 	}
-	return ref.EvalValue(ctx)
+	return val.Interface(), nil
 }
 
 func (v *ReferenceValue) EvalType(ctx map[string]Value) (reflect.Type, error) {
-	ref, ok := ctx[v.Ref]
-	if !ok {
-		return nil, fmt.Errorf("undefined variable %q", v.Ref)
+	ref, path, err := v.lookup(ctx)
+	if err != nil {
+		return nil, err
+	}
+	typ, err := ref.EvalType(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if typ == nil && len(path) > 0 {
+		return nil, fmt.Errorf("in %s: cannot read field %q of nil", v.Ref, path[0])
+	}
+	for _, name := range path {
+		typ = dataType(typ)
+		i, err := fieldIndex(typ, name)
+		if err != nil {
+			return nil, fmt.Errorf("in %s: %w", v.Ref, err)
+		}
+		typ = typ.Field(i).Type
 	}
-	return ref.EvalType(ctx)
+	return typ, nil
 }
 
 var ErrDummyNotEvaluable = errors.New("Dummy Value not evaluable.")
@@ -0,0 +1,96 @@
+package mova
+
+import (
+	"context"
+	"errors"
+	"io"
+	"reflect"
+	"time"
+)
+
+// AsyncError is the event-data of the `<action>.error` event emitted when an asynchronous action failed.
+type AsyncError struct {
+	Message string `mova:"message"`
+}
+
+var errorType = reflect.TypeFor[error]()
+
+// NewAsyncAction registers fn like NewAction, but the runtime executes it on a separate goroutine.
+// When fn returns, either `<name>.done` or `<name>.error` is emitted into the machine.
+// If fn returns a value besides an error, it is available as `result` in `<name>.done`.
+func NewAsyncAction(r *Registry, name string, args []string, fn any) {
+	NewAction(r, name, args, fn)
+	spec := r.actions[name]
+	spec.Async = true
+	r.actions[name] = spec
+
+	var fields []reflect.StructField
+	if typ := resultType(spec.Function.Type()); typ != nil {
+		fields = append(fields, reflect.StructField{Name: "Result", Type: typ, Tag: `mova:"result"`})
+	}
+	if r.triggers == nil {
+		r.triggers = make(map[string]reflect.Type)
+	}
+	r.triggers[name+".done"] = reflect.StructOf(fields)
+	r.triggers[name+".error"] = reflect.TypeFor[AsyncError]()
+}
+
+func resultType(fn reflect.Type) reflect.Type {
+	for i := range fn.NumOut() {
+		if out := fn.Out(i); out != errorType {
+			return out
+		}
+	}
+	return nil
+}
+
+// actionResult splits the return values of an action into its result and error.
+func actionResult(out []reflect.Value) (result reflect.Value, err error) {
+	for _, v := range out {
+		if v.Type() == errorType {
+			if !v.IsNil() {
+				err = v.Interface().(error)
+			}
+		} else if !result.IsValid() {
+			result = v
+		}
+	}
+	return
+}
+
+func (m *StateMachine) runAsync(ctx context.Context, name string, spec ActionSpec, ins []reflect.Value, policy Policy, end func(error)) {
+	m.async.Add(1)
+	m.mu.Lock()
+	m.running++
+	m.idle = false
+	m.mu.Unlock()
+	go func() {
+		defer m.async.Done()
+		defer func() {
+			m.mu.Lock()
+			m.running--
+			m.mu.Unlock()
+			m.settled()
+		}()
+		result, err := m.invoke(ctx, name, spec, ins, policy, time.Time{})
+		end(err)
+		if err != nil {
+			m.emitAsync(ctx, name+".error", AsyncError{Message: err.Error()})
+			return
+		}
+		done := reflect.New(m.reg.triggers[name+".done"]).Elem()
+		if result.IsValid() {
+			done.Field(0).Set(result)
+		}
+		m.emitAsync(ctx, name+".done", done.Interface())
+	}()
+}
+
+// emitAsync emits the done or error event of an asynchronous action. Failures are passed to the
+// `error` trigger like for other events, those it does not handle are recorded in the journal as
+// there is no caller to return them to.
+func (m *StateMachine) emitAsync(ctx context.Context, name string, v any) {
+	if _, err := m.emit(ctx, name, v, false); err != nil && !errors.Is(err, io.EOF) {
+		m.record(JournalEntry{Kind: JournalAsyncError, State: m.Current(), Event: name, Error: err.Error()})
+	}
+}
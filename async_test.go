@@ -0,0 +1,79 @@
+package mova
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+)
+
+type memJournal struct {
+	mu      sync.Mutex
+	entries []JournalEntry
+}
+
+func (j *memJournal) Record(e JournalEntry) {
+	j.mu.Lock()
+	j.entries = append(j.entries, e)
+	j.mu.Unlock()
+}
+
+func (j *memJournal) kind(kind JournalKind) []JournalEntry {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	var out []JournalEntry
+	for _, e := range j.entries {
+		if e.Kind == kind {
+			out = append(out, e)
+		}
+	}
+	return out
+}
+
+// TestAsyncError checks that failures handling the completion of an asynchronous action reach the
+// error trigger, or else the journal.
+func TestAsyncError(t *testing.T) {
+	var reg Registry
+	NewTrigger[bindEvent](&reg, "press")
+	NewAsyncAction(&reg, "fetch", nil, func() (int, error) { return 1, nil })
+	NewAction(&reg, "fail", nil, func() error { return errors.New("broken") })
+	var handled []string
+	NewAction(&reg, "report", []string{"message"}, func(message string) { handled = append(handled, message) })
+	tests := []struct {
+		src      string
+		handled  int
+		recorded string
+	}{
+		{`state a { on press -> fetch; on fetch.done -> fail; };`, 0, "broken"},
+		{`state a { on press -> fetch; on fetch.done -> fail; on error(message) -> report(message=message); };`, 1, ""},
+		{`state a { on press -> fetch; };`, 0, ""}, // unhandled
+	}
+	for _, tt := range tests {
+		t.Run(tt.src, func(t *testing.T) {
+			handled = nil
+			cm, err := BuildMachine("test.mova", strings.NewReader(tt.src), &reg, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var j memJournal
+			m, _ := cm.New(WithJournal(&j))
+			if err := m.Emit("press", bindEvent{}); err != nil {
+				t.Fatal(err)
+			}
+			if err := m.WaitIdle(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+			if len(handled) != tt.handled {
+				t.Errorf("error trigger fired %d times, want %d", len(handled), tt.handled)
+			}
+			recorded := j.kind(JournalAsyncError)
+			switch {
+			case tt.recorded == "" && len(recorded) != 0:
+				t.Errorf("recorded %+v", recorded)
+			case tt.recorded != "" && (len(recorded) != 1 || recorded[0].Event != "fetch.done" || !strings.Contains(recorded[0].Error, tt.recorded)):
+				t.Errorf("recorded %+v, want an error in fetch.done containing %q", recorded, tt.recorded)
+			}
+		})
+	}
+}
@@ -0,0 +1,89 @@
+package mova
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// bindable reports whether a value of type from can be passed as an argument of type to, from is
+// nil for nil. A value is passed if it is assignable, e.g. an *os.File to an io.Writer, if it
+// converts without changing its meaning, see convertible, or as a pointer to a copy if to is a
+// pointer to such a type. Values of interface type are checked again when calling, as their
+// dynamic type is not known yet.
+func bindable(from, to reflect.Type) bool {
+	switch {
+	case from == nil:
+		return nilable(to)
+	case from.AssignableTo(to):
+		return true
+	case from.Kind() == reflect.Interface:
+		return true
+	case to.Kind() == reflect.Pointer && from.Kind() != reflect.Pointer:
+		return bindable(from, to.Elem())
+	}
+	return convertible(from, to)
+}
+
+// bind converts v to an argument of type to following bindable.
+func bind(v any, to reflect.Type) (reflect.Value, error) {
+	rv := reflect.ValueOf(v)
+	switch {
+	case !rv.IsValid():
+		if !nilable(to) {
+			return reflect.Value{}, fmt.Errorf("cannot use nil as %v", to)
+		}
+		return reflect.Zero(to), nil
+	case rv.Type().AssignableTo(to):
+		out := reflect.New(to).Elem()
+		out.Set(rv)
+		return out, nil
+	case to.Kind() == reflect.Pointer && rv.Kind() != reflect.Pointer:
+		elem, err := bind(v, to.Elem())
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		ptr := reflect.New(to.Elem())
+		ptr.Elem().Set(elem)
+		return ptr, nil
+	case convertible(rv.Type(), to):
+		if overflows(rv, to) {
+			return reflect.Value{}, fmt.Errorf("%v overflows %v", v, to)
+		}
+		return rv.Convert(to), nil
+	}
+	return reflect.Value{}, fmt.Errorf("cannot use %v as %v", rv.Type(), to)
+}
+
+// convertible reports whether from converts to to without changing the meaning of the value:
+// integers to integers or floats, floats to floats, or between types of the same kind. Others, like
+// a float to an int, need a cast.
+func convertible(from, to reflect.Type) bool {
+	if !from.ConvertibleTo(to) {
+		return false
+	}
+	switch {
+	case isInt(from.Kind()):
+		return isInt(to.Kind()) || isFloat(to.Kind())
+	case isFloat(from.Kind()):
+		return isFloat(to.Kind())
+	}
+	return from.Kind() == to.Kind()
+}
+
+// overflows reports whether number v cannot be represented by to.
+func overflows(v reflect.Value, to reflect.Type) bool {
+	zero := reflect.Zero(to)
+	switch {
+	case v.CanInt() && zero.CanInt():
+		return zero.OverflowInt(v.Int())
+	case v.CanInt() && zero.CanUint():
+		return v.Int() < 0 || zero.OverflowUint(uint64(v.Int()))
+	case v.CanUint() && zero.CanInt():
+		return v.Uint() > 1<<63-1 || zero.OverflowInt(int64(v.Uint()))
+	case v.CanUint() && zero.CanUint():
+		return zero.OverflowUint(v.Uint())
+	case v.CanFloat() && zero.CanFloat():
+		return zero.OverflowFloat(v.Float())
+	}
+	return false
+}
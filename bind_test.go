@@ -0,0 +1,231 @@
+package mova
+
+import (
+	"bytes"
+	"io"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type level int
+
+func TestBindable(t *testing.T) {
+	var (
+		intT     = reflect.TypeFor[int]()
+		int64T   = reflect.TypeFor[int64]()
+		uint8T   = reflect.TypeFor[uint8]()
+		float32T = reflect.TypeFor[float32]()
+		float64T = reflect.TypeFor[float64]()
+		stringT  = reflect.TypeFor[string]()
+		boolT    = reflect.TypeFor[bool]()
+		levelT   = reflect.TypeFor[level]()
+		ptrIntT  = reflect.TypeFor[*int]()
+		anyT     = reflect.TypeFor[any]()
+		writerT  = reflect.TypeFor[io.Writer]()
+		bufferT  = reflect.TypeFor[*bytes.Buffer]()
+		sliceT   = reflect.TypeFor[[]int]()
+		mapT     = reflect.TypeFor[map[string]int]()
+		durT     = reflect.TypeFor[time.Duration]()
+	)
+	tests := []struct {
+		from, to reflect.Type
+		want     bool
+	}{
+		{intT, intT, true},
+		{int64T, intT, true},
+		{int64T, uint8T, true},
+		{int64T, float64T, true},
+		{int64T, float32T, true},
+		{float64T, float32T, true},
+		{float64T, intT, false},
+		{float32T, int64T, false},
+		{int64T, stringT, false},
+		{stringT, intT, false},
+		{boolT, intT, false},
+		{int64T, levelT, true},
+		{levelT, intT, true},
+		{int64T, durT, true},
+		{int64T, ptrIntT, true},
+		{float64T, ptrIntT, false},
+		{ptrIntT, ptrIntT, true},
+		{ptrIntT, intT, false},
+		{bufferT, writerT, true},
+		{stringT, writerT, false},
+		{intT, anyT, true},
+		{anyT, intT, true}, // checked when calling
+		{writerT, bufferT, true},
+		{nil, ptrIntT, true},
+		{nil, anyT, true},
+		{nil, writerT, true},
+		{nil, sliceT, true},
+		{nil, mapT, true},
+		{nil, intT, false},
+		{nil, stringT, false},
+	}
+	for _, tt := range tests {
+		if got := bindable(tt.from, tt.to); got != tt.want {
+			t.Errorf("bindable(%s, %v) = %v, want %v", typeString(tt.from), tt.to, got, tt.want)
+		}
+	}
+}
+
+func TestBind(t *testing.T) {
+	var buf bytes.Buffer
+	tests := []struct {
+		name string
+		v    any
+		to   reflect.Type
+		want any // compared after dereferencing pointers, unused if err is set
+		err  string
+	}{
+		{"int", int64(5), reflect.TypeFor[int](), 5, ""},
+		{"uint8", int64(255), reflect.TypeFor[uint8](), uint8(255), ""},
+		{"uint8 overflow", int64(256), reflect.TypeFor[uint8](), nil, "256 overflows uint8"},
+		{"uint8 negative", int64(-1), reflect.TypeFor[uint8](), nil, "-1 overflows uint8"},
+		{"int8 overflow", int64(-129), reflect.TypeFor[int8](), nil, "overflows int8"},
+		{"uint64 to int64", uint64(math.MaxUint64), reflect.TypeFor[int64](), nil, "overflows int64"},
+		{"uint to uint16", uint(65535), reflect.TypeFor[uint16](), uint16(65535), ""},
+		{"int to float", int64(3), reflect.TypeFor[float64](), 3.0, ""},
+		{"float32", 1.5, reflect.TypeFor[float32](), float32(1.5), ""},
+		{"float32 overflow", 1e300, reflect.TypeFor[float32](), nil, "overflows float32"},
+		{"float to int", 3.7, reflect.TypeFor[int](), nil, "cannot use float64 as int"},
+		{"int to string", int64(65), reflect.TypeFor[string](), nil, "cannot use int64 as string"},
+		{"named", int64(2), reflect.TypeFor[level](), level(2), ""},
+		{"duration", int64(time.Second), reflect.TypeFor[time.Duration](), time.Second, ""},
+		{"pointer to copy", int64(7), reflect.TypeFor[*int](), 7, ""},
+		{"pointer overflow", int64(300), reflect.TypeFor[*uint8](), nil, "300 overflows uint8"},
+		{"interface", &buf, reflect.TypeFor[io.Writer](), &buf, ""},
+		{"not an interface", "x", reflect.TypeFor[io.Writer](), nil, "cannot use string as io.Writer"},
+		{"any", int64(1), reflect.TypeFor[any](), int64(1), ""},
+		{"nil pointer", nil, reflect.TypeFor[*int](), (*int)(nil), ""},
+		{"nil interface", nil, reflect.TypeFor[io.Writer](), nil, ""},
+		{"nil slice", nil, reflect.TypeFor[[]int](), []int(nil), ""},
+		{"nil int", nil, reflect.TypeFor[int](), nil, "cannot use nil as int"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := bind(tt.v, tt.to)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("got error %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Type() != tt.to {
+				t.Fatalf("got type %v, want %v", got.Type(), tt.to)
+			}
+			if got.Kind() == reflect.Pointer && !got.IsNil() && tt.want != nil && reflect.TypeOf(tt.want) != tt.to {
+				got = got.Elem()
+			}
+			if !reflect.DeepEqual(got.Interface(), tt.want) {
+				t.Fatalf("got %#v, want %#v", got.Interface(), tt.want)
+			}
+		})
+	}
+}
+
+type bindEvent struct {
+	ID  int
+	P   *int
+	Any any
+}
+
+// TestBuildNil checks that nil constants are reported as type errors wherever values are used,
+// instead of panicking.
+func TestBuildNil(t *testing.T) {
+	var reg Registry
+	NewTrigger[bindEvent](&reg, "press")
+	NewAction(&reg, "num", []string{"n"}, func(n int) {})
+	NewAction(&reg, "ptr", []string{"n"}, func(n *int) {})
+	NewAction(&reg, "write", []string{"w"}, func(w io.Writer) {})
+	constants := map[string]any{"nothing": nil, "out": &bytes.Buffer{}}
+	tests := []struct {
+		src, err string
+	}{
+		{`state a { on press -> ptr(n=nothing), write(w=nothing), write(w=out); };`, ""},
+		{`state a { on press(P=nothing) -> ptr(n=P); };`, ""},
+		{`state a { on press(P) -> set v = nothing, set v = P; };`, ""},
+		{`state a { on press -> num(n=nothing); };`, "expected int, got nil"},
+		{`state a { on press -> num(n=3.5); };`, "expected int, got float64"},
+		{`state a { on press -> num(n=int(3.5)); };`, ""},
+		{`state a { on press -> write(w="x"); };`, "expected io.Writer, got string"},
+		{`state a { on press(ID=nothing) -> num(n=1); };`, "got nil"},
+		{`state a { on press -> if nothing { num(n=1) }; };`, "condition must be bool, got nil"},
+		{`x = nothing; state a { on press -> num(n=len(x)); };`, "argument 1 is nil"},
+		{`state a { on press -> num(n=int(nothing)); };`, "cannot convert nil to int"},
+		{`state a { on press -> num(n=nothing.field); };`, "cannot read field"},
+		{`state a { on press -> set v = nothing; };`, "untyped nil"},
+		{`var v = nothing; state a { on press -> num(n=1); };`, "untyped nil"},
+		{`state a { var v = nothing; on press -> num(n=1); };`, "untyped nil"},
+		{`state a(p: int) { on press -> move a(p=nothing); };`, "got nil"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.src, func(t *testing.T) {
+			cm, err := BuildMachine("test.mova", strings.NewReader(tt.src), &reg, constants)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("got error %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			m, err := cm.New()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := m.Emit("press", bindEvent{}); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+// TestCallPointer checks the values actions receive for pointer, interface and nil arguments.
+func TestCallPointer(t *testing.T) {
+	var reg Registry
+	NewTrigger[bindEvent](&reg, "press")
+	var gotPtr *int
+	var gotWriter io.Writer = &bytes.Buffer{}
+	var gotAny any
+	NewAction(&reg, "ptr", []string{"n"}, func(n *int) { gotPtr = n })
+	NewAction(&reg, "write", []string{"w"}, func(w io.Writer) { gotWriter = w })
+	NewAction(&reg, "keep", []string{"v"}, func(v any) { gotAny = v })
+	NewAction(&reg, "num", []string{"n"}, func(n int) {})
+	var out bytes.Buffer
+	src := `state a {
+		on press(ID=1, Any) -> ptr(n=ID), write(w=out), keep(v=Any);
+		on press(ID=2) -> ptr(n=nothing), write(w=nothing);
+		on press(ID=3, Any) -> num(n=Any);
+	};`
+	cm, err := BuildMachine("test.mova", strings.NewReader(src), &reg, map[string]any{"nothing": nil, "out": &out})
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, _ := cm.New()
+	if err := m.Emit("press", bindEvent{ID: 1, Any: "x"}); err != nil {
+		t.Fatal(err)
+	}
+	if gotPtr == nil || *gotPtr != 1 || gotWriter != &out || gotAny != "x" {
+		t.Fatalf("got %v, %v, %v", gotPtr, gotWriter, gotAny)
+	}
+	if err := m.Emit("press", bindEvent{ID: 2}); err != nil {
+		t.Fatal(err)
+	}
+	if gotPtr != nil || gotWriter != nil {
+		t.Fatalf("got %v, %v, want nil", gotPtr, gotWriter)
+	}
+	if err := m.Emit("press", bindEvent{ID: 3, Any: 2.5}); err == nil || !strings.Contains(err.Error(), "cannot use float64 as int") {
+		t.Fatalf("got error %v, want float64 rejected", err)
+	}
+	if err := m.Emit("press", bindEvent{ID: 3, Any: 4}); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,133 @@
+package mova
+
+import (
+	"errors"
+	"sync"
+)
+
+var ErrNotPaused = errors.New("machine is not paused")
+
+// Break describes where a machine paused, see SetBreakpoint.
+type Break struct {
+	State    string         // current state
+	Action   int            // index of the next action in the init or trigger actions being executed
+	Bindings map[string]any // variables visible to the action
+}
+
+type debugger struct {
+	mu          sync.Mutex
+	breakpoints map[string]bool
+	stepping    bool
+	paused      *Break
+	breaks      chan Break
+	resume      chan bool // true to step
+}
+
+func (m *StateMachine) debugger() *debugger {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if d := m.debug.Load(); d != nil {
+		return d
+	}
+	d := &debugger{
+		breakpoints: make(map[string]bool),
+		breaks:      make(chan Break, 1),
+		resume:      make(chan bool),
+	}
+	m.debug.Store(d)
+	return d
+}
+
+// SetBreakpoint pauses the machine before it executes the init actions of state or the actions of
+// one of its triggers. While paused, the goroutine handling the event blocks until Step or Continue
+// is called, so events should be emitted on a separate goroutine. Pauses are reported on Breaks.
+func (m *StateMachine) SetBreakpoint(state string) {
+	d := m.debugger()
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.breakpoints[state] = true
+}
+
+func (m *StateMachine) ClearBreakpoint(state string) {
+	d := m.debugger()
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.breakpoints, state)
+}
+
+// Breaks returns the channel on which the machine reports pauses.
+func (m *StateMachine) Breaks() <-chan Break {
+	return m.debugger().breaks
+}
+
+// Paused returns where the machine is paused, if it is.
+func (m *StateMachine) Paused() (Break, bool) {
+	d := m.debug.Load()
+	if d == nil {
+		return Break{}, false
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.paused == nil {
+		return Break{}, false
+	}
+	return *d.paused, true
+}
+
+// Step executes the next action and pauses again before the action after it.
+func (m *StateMachine) Step() error {
+	return m.resume(true)
+}
+
+// Continue resumes a paused machine until it reaches the next breakpoint.
+func (m *StateMachine) Continue() error {
+	return m.resume(false)
+}
+
+func (m *StateMachine) resume(step bool) error {
+	d := m.debug.Load()
+	if d == nil {
+		return ErrNotPaused
+	}
+	d.mu.Lock()
+	if d.paused == nil {
+		d.mu.Unlock()
+		return ErrNotPaused
+	}
+	d.paused = nil
+	d.mu.Unlock()
+	d.resume <- step
+	return nil
+}
+
+// checkpoint pauses before action index of a batch if stepping or at a breakpoint.
+func (m *StateMachine) checkpoint(index int, ctx map[string]Value) {
+	d := m.debug.Load()
+	if d == nil {
+		return
+	}
+	state := m.Current()
+	d.mu.Lock()
+	if !d.stepping && (index != 0 || !d.breakpoints[state]) {
+		d.mu.Unlock()
+		return
+	}
+	brk := Break{State: state, Action: index, Bindings: make(map[string]any)}
+	for name, v := range ctx {
+		if val, err := v.EvalValue(ctx); err == nil {
+			brk.Bindings[name] = val
+		}
+	}
+	d.paused = &brk
+	d.mu.Unlock()
+
+	select {
+	case d.breaks <- brk:
+	default: // nobody listening, the break is still available using Paused
+	}
+	step := <-d.resume
+
+	d.mu.Lock()
+	d.stepping = step
+	d.mu.Unlock()
+}
@@ -0,0 +1,173 @@
+package mova
+
+import (
+	"errors"
+	"fmt"
+)
+
+// MachineBuilder constructs the AST of a machine in Go code:
+//
+//	cm, err := mova.NewMachineBuilder().
+//		State("idle").On("press", mova.Eq("id", 3)).Do("beep").MoveTo("active").
+//		State("active").On("release").MoveTo("idle").
+//		Build(&reg)
+//
+// Do and MoveTo add to the trigger started by the last On, or to the init actions of the
+// state if no trigger was started yet. Calls in the wrong order, like On before State, are
+// reported by Build.
+type MachineBuilder struct {
+	file    File
+	state   *State
+	trigger *Trigger
+	err     error // first misuse, returned by Build
+}
+
+func NewMachineBuilder() *MachineBuilder {
+	return &MachineBuilder{file: File{Filename: "builder"}}
+}
+
+// Eq is a condition on event-data, which is also bound as variable.
+func Eq(key string, value any) Arg {
+	return Arg{Key: key, Value: toValue(value)}
+}
+
+// Bind makes event-data available as variable without a condition.
+func Bind(key string) Arg {
+	return Arg{Key: key}
+}
+
+// BindOptional makes event-data available as variable, with the zero value if the event lacks it.
+// See Arg.Optional.
+func BindOptional(key string) Arg {
+	return Arg{Key: key, Optional: true}
+}
+
+// With is an argument of an action or move.
+func With(key string, value any) Arg {
+	return Arg{Key: key, Value: toValue(value)}
+}
+
+// Ref refers to a constant, event-data or state parameter by name, for use in Eq and With.
+func Ref(name string) Value {
+	return &ReferenceValue{Ref: name}
+}
+
+func toValue(v any) Value {
+	if val, ok := v.(Value); ok {
+		return val
+	}
+	return &ConstValue{v}
+}
+
+func argMap(args []Arg) map[string]Value {
+	out := make(map[string]Value)
+	for _, a := range args {
+		out[a.Key] = a.Value
+	}
+	return out
+}
+
+func (b *MachineBuilder) Const(name string, value any) *MachineBuilder {
+	b.file.Entries = append(b.file.Entries, &SetStmt{Key: name, Value: toValue(value)})
+	return b
+}
+
+// State starts a new state, the first state is the initial state.
+func (b *MachineBuilder) State(name string, params ...Param) *MachineBuilder {
+	b.state = &State{Name: name, Params: params}
+	b.trigger = nil
+	b.file.Entries = append(b.file.Entries, b.state)
+	return b
+}
+
+// Final marks the current state as final.
+func (b *MachineBuilder) Final() *MachineBuilder {
+	b.current("Final").Final = true
+	return b
+}
+
+// Awaiting makes the current state wait for the external task, see Manager.Tasks.
+func (b *MachineBuilder) Awaiting(task string) *MachineBuilder {
+	b.current("Awaiting").Task = task
+	return b
+}
+
+// On starts a trigger in the current state.
+func (b *MachineBuilder) On(event string, data ...Arg) *MachineBuilder {
+	st := b.current("On")
+	st.Triggers = append(st.Triggers, Trigger{Cond: []TriggerCond{{Name: event, Params: data}}})
+	b.trigger = &st.Triggers[len(st.Triggers)-1]
+	return b
+}
+
+// Or adds an alternative condition to the current trigger.
+func (b *MachineBuilder) Or(event string, data ...Arg) *MachineBuilder {
+	if b.trigger == nil {
+		b.fail(errors.New("Or without On"))
+		return b
+	}
+	b.trigger.Cond = append(b.trigger.Cond, TriggerCond{Name: event, Params: data})
+	return b
+}
+
+func (b *MachineBuilder) Do(action string, args ...Arg) *MachineBuilder {
+	b.add("Do", &Call{Name: action, Args: argMap(args)})
+	return b
+}
+
+// DoWith calls an action with a timeout and retry policy.
+func (b *MachineBuilder) DoWith(action string, policy Policy, args ...Arg) *MachineBuilder {
+	b.add("DoWith", &Call{Name: action, Args: argMap(args), Policy: policy})
+	return b
+}
+
+// Compensate adds a call to the compensate block of the current state, see WithSaga.
+func (b *MachineBuilder) Compensate(action string, args ...Arg) *MachineBuilder {
+	st := b.current("Compensate")
+	st.Compensate = append(st.Compensate, &Call{Name: action, Args: argMap(args)})
+	return b
+}
+
+func (b *MachineBuilder) MoveTo(state string, args ...Arg) *MachineBuilder {
+	b.add("MoveTo", &MoveStmt{Dest: state, Args: argMap(args)})
+	return b
+}
+
+// current returns the state started last. Without one, the misuse by method is recorded and a
+// detached state is returned, so the following calls have no effect.
+func (b *MachineBuilder) current(method string) *State {
+	if b.state == nil {
+		b.fail(fmt.Errorf("%s before State", method))
+		return &State{}
+	}
+	return b.state
+}
+
+func (b *MachineBuilder) add(method string, stmt Statement) {
+	if b.trigger != nil {
+		b.trigger.Actions = append(b.trigger.Actions, stmt)
+	} else {
+		st := b.current(method)
+		st.Init = append(st.Init, stmt)
+	}
+}
+
+// fail records err unless an earlier misuse was recorded.
+func (b *MachineBuilder) fail(err error) {
+	if b.err == nil {
+		b.err = err
+	}
+}
+
+// File returns the constructed AST.
+func (b *MachineBuilder) File() *File {
+	return &b.file
+}
+
+// Build compiles the constructed machine, see File.Compile.
+func (b *MachineBuilder) Build(reg *Registry, opts ...BuildOption) (*CompiledMachine, error) {
+	if b.err != nil {
+		return nil, b.err
+	}
+	return b.file.Compile(reg, nil, opts...)
+}
@@ -0,0 +1,38 @@
+package mova
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestBuilderMisuse(t *testing.T) {
+	var reg Registry
+	NewTrigger[bindEvent](&reg, "press")
+	NewAction(&reg, "beep", nil, func() {})
+	tests := []struct {
+		name  string
+		build func(b *MachineBuilder)
+		err   string
+	}{
+		{"valid", func(b *MachineBuilder) {
+			b.State("a").Do("beep").On("press", Eq("ID", 1)).Or("press", Eq("ID", 2)).MoveTo("b").State("b").Final()
+		}, ""},
+		{"on", func(b *MachineBuilder) { b.On("press").State("a") }, "On before State"},
+		{"or", func(b *MachineBuilder) { b.State("a").Or("press") }, "Or without On"},
+		{"do", func(b *MachineBuilder) { b.Do("beep").State("a") }, "Do before State"},
+		{"move", func(b *MachineBuilder) { b.MoveTo("a").State("a") }, "MoveTo before State"},
+		{"final", func(b *MachineBuilder) { b.Final().State("a") }, "Final before State"},
+		{"first", func(b *MachineBuilder) { b.Compensate("beep").Awaiting("task").State("a") }, "Compensate before State"},
+		{"compile", func(b *MachineBuilder) { b.State("a").On("press").MoveTo("c") }, `unknown state "c"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := NewMachineBuilder()
+			tt.build(b)
+			_, err := b.Build(&reg)
+			if tt.err == "" && err != nil || tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)) {
+				t.Fatalf("got error %v, want %q", err, tt.err)
+			}
+		})
+	}
+}
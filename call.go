@@ -0,0 +1,21 @@
+//go:build !tinygo && !mova_noreflect
+
+package mova
+
+import "reflect"
+
+// reflectCall reports whether actions registered with NewAction are callable in this build.
+const reflectCall = true
+
+// callFunc calls a function registered with NewFunc.
+func callFunc(fn reflect.Value, ins []reflect.Value) (reflect.Value, error) {
+	return actionResult(fn.Call(ins))
+}
+
+// call executes the action with its inputs.
+func (spec ActionSpec) call(ins []reflect.Value) (reflect.Value, error) {
+	if spec.Invoke != nil {
+		return spec.Invoke(ins)
+	}
+	return actionResult(spec.Function.Call(ins))
+}
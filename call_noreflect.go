@@ -0,0 +1,26 @@
+//go:build tinygo || mova_noreflect
+
+package mova
+
+import (
+	"errors"
+	"reflect"
+)
+
+// reflectCall reports whether actions registered with NewAction are callable in this build.
+// TinyGo and WASM builds lack reflect.Value.Call, so only actions registered with NewAction0
+// to NewAction3 are available, see Call.CheckType.
+const reflectCall = false
+
+// callFunc calls a function registered with NewFunc, which is rejected by FuncValue.EvalType.
+func callFunc(fn reflect.Value, ins []reflect.Value) (reflect.Value, error) {
+	return reflect.Value{}, errors.New("function is not callable without reflection")
+}
+
+// call executes the action with its inputs.
+func (spec ActionSpec) call(ins []reflect.Value) (reflect.Value, error) {
+	if spec.Invoke == nil {
+		return reflect.Value{}, errors.New("action is not callable without reflection")
+	}
+	return spec.Invoke(ins)
+}
@@ -0,0 +1,131 @@
+package mova
+
+import (
+	"fmt"
+	"maps"
+	"reflect"
+	"strings"
+)
+
+// CaptureStmt calls an action and binds its result to a variable visible to the following
+// actions of the trigger, written as
+//
+//	result = validate(payload)
+//
+// Fields of a struct result are read as `result.ok`. Asynchronous actions cannot be captured,
+// their result arrives as event-data of `<action>.done`.
+type CaptureStmt struct {
+	Span Span
+	Name string
+	Call *Call
+}
+
+func (cs *CaptureStmt) CheckType(ctx map[string]Value, m *CompiledMachine) error {
+	if strings.Contains(cs.Name, ".") {
+		return fmt.Errorf("cannot capture into %q: not a plain name", cs.Name)
+	}
+	if err := cs.Call.CheckType(ctx, m); err != nil {
+		return err
+	}
+	spec, _ := m.reg.action(cs.Call.Name)
+	if spec.Async {
+		return fmt.Errorf("cannot capture result of asynchronous action %s, use its %s.done event", cs.Call.Name, cs.Call.Name)
+	}
+	typ := resultType(spec.Function.Type())
+	if typ == nil {
+		return fmt.Errorf("cannot capture result of action %s: it has none", cs.Call.Name)
+	}
+	ctx[cs.Name] = &TypeDummyValue{typ}
+	return nil
+}
+
+func (cs *CaptureStmt) Execute(cm *CompiledMachine) Action {
+	call := cs.Call.execute(cm)
+	spec, _ := cm.reg.action(cs.Call.Name)
+	typ := resultType(spec.Function.Type())
+	return func(m *StateMachine, ctx map[string]Value) error {
+		result, err := call(m, ctx)
+		if err != nil {
+			return err
+		}
+		if !result.IsValid() {
+			result = reflect.Zero(typ) // skipped by middleware
+		}
+		ctx[cs.Name] = &ConstValue{result.Interface()} // visible to the following actions
+		return nil
+	}
+}
+
+func (cs *CaptureStmt) String() string {
+	return formatName(cs.Name) + " = " + formatStatement(cs.Call)
+}
+
+// IfStmt runs one of two lists of actions depending on a condition, written as
+//
+//	if result.ok { move Accepted } else { move Rejected }
+//
+// The condition is a bool value, the else branch is optional. Variables captured in a branch are
+// not visible after it.
+type IfStmt struct {
+	Span Span
+	Cond Value
+	Then []Statement
+	Else []Statement
+}
+
+func (is *IfStmt) CheckType(ctx map[string]Value, m *CompiledMachine) error {
+	typ, err := is.Cond.EvalType(ctx)
+	if err != nil {
+		return fmt.Errorf("cannot determine type of condition: %w", err)
+	}
+	if typ == nil || typ.Kind() != reflect.Bool {
+		return fmt.Errorf("condition must be bool, got %s", typeString(typ))
+	}
+	for _, branch := range [][]Statement{is.Then, is.Else} {
+		local := maps.Clone(ctx)
+		for _, stmt := range branch {
+			// branches share the variables of the trigger when executed
+			if cs, ok := stmt.(*CaptureStmt); ok && local[cs.Name] != nil {
+				return located(cs.Span, fmt.Errorf("cannot capture into %q within if: already defined", cs.Name))
+			}
+			if err := stmt.CheckType(local, m); err != nil {
+				return located(statementSpan(stmt, is.Span), err)
+			}
+		}
+	}
+	return nil
+}
+
+func (is *IfStmt) Execute(cm *CompiledMachine) Action {
+	var then, els []Action
+	for _, stmt := range is.Then {
+		then = append(then, stmt.Execute(cm))
+	}
+	for _, stmt := range is.Else {
+		els = append(els, stmt.Execute(cm))
+	}
+	return func(m *StateMachine, ctx map[string]Value) error {
+		cond, err := is.Cond.EvalValue(ctx)
+		if err != nil {
+			return err
+		}
+		branch := els
+		if reflect.ValueOf(cond).Bool() {
+			branch = then
+		}
+		for _, action := range branch {
+			if err := action(m, ctx); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+func (is *IfStmt) String() string {
+	text := "if " + formatValue(is.Cond) + " { " + formatStatements(is.Then) + " }"
+	if len(is.Else) > 0 {
+		text += " else { " + formatStatements(is.Else) + " }"
+	}
+	return text
+}
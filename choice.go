@@ -0,0 +1,89 @@
+package mova
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// ChoiceStmt moves to one of several states at random, written as
+//
+//	move choice { 70% idle; 30% busy(load=1); }
+//
+// The weights are percentages and must add up to 100.
+type ChoiceStmt struct {
+	Span     Span
+	Branches []ChoiceBranch
+}
+
+type ChoiceBranch struct {
+	Weight float64
+	Move   *MoveStmt
+}
+
+func (cs *ChoiceStmt) CheckType(ctx map[string]Value, m *CompiledMachine) error {
+	total := 0.0
+	for _, b := range cs.Branches {
+		if b.Weight <= 0 {
+			return fmt.Errorf("weight of choice %s must be positive", b.Move.Dest)
+		}
+		total += b.Weight
+		if err := b.Move.CheckType(ctx, m); err != nil {
+			return err
+		}
+	}
+	if total < 99.999 || total > 100.001 {
+		return fmt.Errorf("weights of choice add up to %v%%, expected 100%%", total)
+	}
+	return nil
+}
+
+func (cs *ChoiceStmt) Execute(m *CompiledMachine) Action {
+	moves := make([]Action, len(cs.Branches))
+	for i, b := range cs.Branches {
+		moves[i] = b.Move.Execute(m)
+	}
+	return func(m *StateMachine, ctx map[string]Value) error {
+		r := m.Random() * 100
+		for i, b := range cs.Branches {
+			if r < b.Weight || i == len(cs.Branches)-1 {
+				return moves[i](m, ctx)
+			}
+			r -= b.Weight
+		}
+		return nil
+	}
+}
+
+func (cs *ChoiceStmt) String() string {
+	var sb strings.Builder
+	sb.WriteString("move choice {")
+	for _, b := range cs.Branches {
+		sb.WriteString(" " + strconv.FormatFloat(b.Weight, 'f', -1, 64) + "% ")
+		sb.WriteString(strings.TrimPrefix(formatStatement(b.Move), "move ") + ";")
+	}
+	sb.WriteString(" }")
+	return sb.String()
+}
+
+// statementMoves returns the destinations stmt may move to.
+func statementMoves(stmt Statement) []string {
+	switch s := stmt.(type) {
+	case *MoveStmt:
+		return []string{s.Dest}
+	case *ChoiceStmt:
+		var out []string
+		for _, b := range s.Branches {
+			out = append(out, b.Move.Dest)
+		}
+		return out
+	case *IfStmt:
+		var out []string
+		for _, stmt := range slices.Concat(s.Then, s.Else) {
+			out = append(out, statementMoves(stmt)...)
+		}
+		return out
+	}
+	return nil
+}
@@ -0,0 +1,438 @@
+// Command mova-lsp is a language server for mova machine files, speaking LSP over stdio.
+//
+// It reports syntax errors and, given a manifest of the registry, type errors. It also resolves
+// states and constants for go-to-definition, shows action and trigger signatures on hover,
+// completes names and provides semantic tokens for highlighting. Write the manifest from the
+// application using the registry:
+//
+//	json.NewEncoder(f).Encode(reg.Manifest())
+//
+// and start the server with `mova-lsp -manifest mova.json`.
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"github.com/friedelschoen/mova"
+)
+
+type document struct {
+	text string
+	file *mova.File // last parsed file, partial if the text has syntax errors, see mova.Parse
+}
+
+type server struct {
+	conn     *conn
+	manifest *mova.Manifest
+	reg      *mova.Registry
+	docs     map[string]*document
+}
+
+func main() {
+	manifestPath := flag.String("manifest", "", "JSON manifest of the registry, enables type checking")
+	flag.Parse()
+	log.SetPrefix("mova-lsp: ")
+
+	s := &server{
+		conn:     newConn(os.Stdin, os.Stdout),
+		manifest: &mova.Manifest{},
+		reg:      &mova.Registry{},
+		docs:     make(map[string]*document),
+	}
+	if *manifestPath != "" {
+		if err := s.loadManifest(*manifestPath); err != nil {
+			log.Fatal(err)
+		}
+	}
+	for {
+		msg, err := s.conn.read()
+		if errors.Is(err, io.EOF) {
+			return
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+		if err := s.handle(msg); err != nil {
+			log.Print(err)
+		}
+	}
+}
+
+func (s *server) loadManifest(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	var mf mova.Manifest
+	if err := json.NewDecoder(f).Decode(&mf); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	reg, err := mf.Registry()
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	s.manifest, s.reg = &mf, reg
+	return nil
+}
+
+func (s *server) handle(msg *message) error {
+	switch msg.Method {
+	case "initialize":
+		return s.conn.reply(msg.ID, map[string]any{
+			"capabilities": map[string]any{
+				"textDocumentSync":   1, // full
+				"definitionProvider": true,
+				"hoverProvider":      true,
+				"completionProvider": map[string]any{},
+				"semanticTokensProvider": map[string]any{
+					"legend": map[string]any{"tokenTypes": semanticTypes, "tokenModifiers": []string{}},
+					"full":   true,
+				},
+			},
+			"serverInfo": map[string]any{"name": "mova-lsp"},
+		})
+	case "initialized":
+		return nil
+	case "shutdown":
+		return s.conn.reply(msg.ID, nil)
+	case "exit":
+		os.Exit(0)
+	case "textDocument/didOpen":
+		var params didOpenParams
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			return err
+		}
+		return s.update(params.TextDocument.URI, params.TextDocument.Text)
+	case "textDocument/didChange":
+		var params didChangeParams
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			return err
+		}
+		if len(params.ContentChanges) == 0 {
+			return nil
+		}
+		return s.update(params.TextDocument.URI, params.ContentChanges[len(params.ContentChanges)-1].Text)
+	case "textDocument/didClose":
+		var params textDocumentParams
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			return err
+		}
+		delete(s.docs, params.TextDocument.URI)
+		return s.conn.notify("textDocument/publishDiagnostics", publishDiagnosticsParams{URI: params.TextDocument.URI, Diagnostics: []diagnostic{}})
+	case "textDocument/semanticTokens/full":
+		var params textDocumentParams
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			return s.conn.fail(msg.ID, codeInvalidParams, err.Error())
+		}
+		doc, ok := s.docs[params.TextDocument.URI]
+		if !ok {
+			return s.conn.reply(msg.ID, nil)
+		}
+		return s.conn.reply(msg.ID, map[string]any{"data": s.semanticTokens(doc)})
+	case "textDocument/definition", "textDocument/hover", "textDocument/completion":
+		var params positionParams
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			return s.conn.fail(msg.ID, codeInvalidParams, err.Error())
+		}
+		doc, ok := s.docs[params.TextDocument.URI]
+		if !ok {
+			return s.conn.reply(msg.ID, nil)
+		}
+		switch msg.Method {
+		case "textDocument/definition":
+			return s.conn.reply(msg.ID, s.definition(params.TextDocument.URI, doc, params.Position))
+		case "textDocument/hover":
+			return s.conn.reply(msg.ID, s.hover(doc, params.Position))
+		default:
+			return s.conn.reply(msg.ID, s.completion(doc))
+		}
+	default:
+		if msg.ID != nil {
+			return s.conn.fail(msg.ID, codeMethodNotFound, "method not found: "+msg.Method)
+		}
+	}
+	return nil
+}
+
+func filename(uri string) string {
+	if u, err := url.Parse(uri); err == nil && u.Path != "" {
+		return u.Path
+	}
+	return uri
+}
+
+// update parses and checks a changed document and publishes its diagnostics.
+func (s *server) update(uri, text string) error {
+	doc, ok := s.docs[uri]
+	if !ok {
+		doc = &document{}
+		s.docs[uri] = doc
+	}
+	doc.text = text
+
+	diags := []diagnostic{}
+	file, err := mova.Parse(filename(uri), strings.NewReader(text), s.reg)
+	doc.file = file // partial if there are syntax errors
+	var warnings []mova.Diagnostic
+	if err == nil {
+		if s.manifest.Triggers != nil {
+			var cm *mova.CompiledMachine
+			cm, err = mova.BuildMachine(filename(uri), strings.NewReader(text), s.reg, nil, mova.WithMissingCheck())
+			if err == nil {
+				warnings = cm.Warnings()
+			}
+		}
+	}
+	for _, d := range append(mova.Diagnostics(err), warnings...) {
+		diag := diagnostic{Severity: severityError, Source: "mova", Message: d.Message}
+		if d.Severity == "warning" {
+			diag.Severity = severityWarning
+		}
+		if d.Range.Start.Line > 0 {
+			diag.Range = lspRange{doc.position(d.Range.Start), doc.position(d.Range.End)}
+		}
+		diags = append(diags, diag)
+	}
+	return s.conn.notify("textDocument/publishDiagnostics", publishDiagnosticsParams{URI: uri, Diagnostics: diags})
+}
+
+// position converts a byte position to an LSP position, which counts UTF-16 code units.
+func (doc *document) position(pos mova.Position) position {
+	start := min(max(pos.Offset-pos.Column, 0), len(doc.text))
+	end := min(max(pos.Offset, start), len(doc.text))
+	return position{Line: pos.Line - 1, Character: len(utf16.Encode([]rune(doc.text[start:end])))}
+}
+
+// offset converts an LSP position to a byte offset in the document.
+func (doc *document) offset(pos position) int {
+	off := 0
+	for range pos.Line {
+		i := strings.IndexByte(doc.text[off:], '\n')
+		if i == -1 {
+			return len(doc.text)
+		}
+		off += i + 1
+	}
+	for units := 0; units < pos.Character && off < len(doc.text) && doc.text[off] != '\n'; {
+		r, size := utf8.DecodeRuneInString(doc.text[off:])
+		units += utf16.RuneLen(r)
+		off += size
+	}
+	return off
+}
+
+// tokenAt returns the token at pos. If pos is between two tokens, e.g. in `id=x)`, the identifier
+// is preferred.
+func (s *server) tokenAt(doc *document, pos position) (mova.Token, bool) {
+	off := doc.offset(pos)
+	var at mova.Token
+	found := false
+	for _, tok := range s.reg.Tokenize([]byte(doc.text)) {
+		if tok.Span.Start.Offset > off {
+			break
+		}
+		if off <= tok.Span.End.Offset && (!found || tok.Kind == "identifier") {
+			at, found = tok, true
+		}
+	}
+	return at, found
+}
+
+// word returns the identifier at pos, quoted identifiers are unquoted.
+func (s *server) word(doc *document, pos position) string {
+	tok, ok := s.tokenAt(doc, pos)
+	if !ok || tok.Kind != "identifier" {
+		return ""
+	}
+	return name(tok)
+}
+
+// name returns the name an identifier token refers to.
+func name(tok mova.Token) string {
+	if tok.Value[0] == '\'' {
+		return strings.NewReplacer(`\'`, "'", `\\`, `\`).Replace(tok.Value[1 : len(tok.Value)-1])
+	}
+	return tok.Value
+}
+
+func (s *server) definition(uri string, doc *document, pos position) *location {
+	word := s.word(doc, pos)
+	if word == "" || doc.file == nil {
+		return nil
+	}
+	for _, entry := range doc.file.Entries {
+		var span mova.Span
+		switch e := entry.(type) {
+		case *mova.State:
+			if e.Name != word {
+				continue
+			}
+			span = e.Span
+		case *mova.SetStmt:
+			if e.Key != word {
+				continue
+			}
+			span = e.Span
+		case *mova.VarDecl:
+			if e.Name != word {
+				continue
+			}
+			span = e.Span
+		default:
+			continue
+		}
+		return &location{URI: uri, Range: lspRange{doc.position(span.Start), doc.position(span.End)}}
+	}
+	return nil
+}
+
+func signature(fields []mova.ManifestField) string {
+	var args []string
+	for _, f := range fields {
+		args = append(args, f.Name+" "+f.Type)
+	}
+	return "(" + strings.Join(args, ", ") + ")"
+}
+
+func (s *server) hover(doc *document, pos position) *hover {
+	word := s.word(doc, pos)
+	var text string
+	if action, ok := s.manifest.Actions[word]; ok {
+		text = "action " + word + signature(action.Args)
+		if action.Async {
+			text += " async"
+		}
+	} else if fields, ok := s.manifest.Triggers[word]; ok {
+		text = "trigger " + word + signature(fields)
+	} else if doc.file != nil {
+		for _, entry := range doc.file.Entries {
+			if st, ok := entry.(*mova.State); ok && st.Name == word {
+				var params []string
+				for _, p := range st.Params {
+					params = append(params, p.Name+": "+p.Type)
+				}
+				text = "state " + word
+				if len(params) > 0 {
+					text += "(" + strings.Join(params, ", ") + ")"
+				}
+				break
+			}
+		}
+	}
+	if text == "" {
+		return nil
+	}
+	return &hover{Contents: markupContent{Kind: "markdown", Value: "```\n" + text + "\n```"}}
+}
+
+func (s *server) completion(doc *document) []completionItem {
+	items := []completionItem{}
+	for name, action := range s.manifest.Actions {
+		items = append(items, completionItem{Label: name, Kind: kindFunction, Detail: name + signature(action.Args)})
+	}
+	for name, fields := range s.manifest.Triggers {
+		items = append(items, completionItem{Label: name, Kind: kindEvent, Detail: name + signature(fields)})
+	}
+	if doc.file != nil {
+		for _, entry := range doc.file.Entries {
+			switch e := entry.(type) {
+			case *mova.State:
+				items = append(items, completionItem{Label: e.Name, Kind: kindClass, Detail: "state"})
+			case *mova.SetStmt:
+				items = append(items, completionItem{Label: e.Key, Kind: kindConstant, Detail: "constant"})
+			case *mova.VarDecl:
+				items = append(items, completionItem{Label: e.Name, Kind: kindVariable, Detail: "variable"})
+			}
+		}
+	}
+	slices.SortFunc(items, func(a, b completionItem) int { return strings.Compare(a.Label, b.Label) })
+	return items
+}
+
+var semanticTypes = []string{"keyword", "variable", "string", "number", "comment", "operator", "function", "event", "class", "type", "macro"}
+
+// semanticType classifies a token as an index into semanticTypes, -1 for tokens left to the editor.
+func (s *server) semanticType(doc *document, tok mova.Token) int {
+	switch tok.Kind {
+	case "keyword", "bool":
+		return 0
+	case "string":
+		return 2
+	case "int", "float", "duration":
+		return 3
+	case "comment":
+		return 4
+	case "directive":
+		return 10
+	case "arrow":
+		return 5
+	case "identifier":
+		name := name(tok)
+		if _, ok := s.manifest.Actions[name]; ok {
+			return 6
+		}
+		if _, ok := s.manifest.Triggers[name]; ok {
+			return 7
+		}
+		if slices.ContainsFunc(s.manifest.Types, func(t mova.ManifestType) bool { return t.Name == name }) {
+			return 9
+		}
+		switch name {
+		case "int", "float", "string", "bool", "duration":
+			return 9
+		}
+		if doc.file != nil && slices.ContainsFunc(doc.file.Entries, func(e mova.Entry) bool {
+			st, ok := e.(*mova.State)
+			return ok && st.Name == name
+		}) {
+			return 8
+		}
+		return 1
+	}
+	if strings.HasPrefix(tok.Kind, "literal ") {
+		return 3
+	}
+	return -1
+}
+
+// semanticTokens encodes the tokens of doc relative to each other, tokens spanning lines are split.
+func (s *server) semanticTokens(doc *document) []int {
+	data := []int{}
+	var prev position
+	for _, tok := range s.reg.Tokenize([]byte(doc.text)) {
+		typ := s.semanticType(doc, tok)
+		if typ == -1 {
+			continue
+		}
+		start := doc.position(tok.Span.Start)
+		for i, line := range strings.Split(tok.Value, "\n") {
+			pos := position{Line: start.Line + i}
+			if i == 0 {
+				pos.Character = start.Character
+			}
+			length := len(utf16.Encode([]rune(line)))
+			if length == 0 {
+				continue
+			}
+			delta := pos.Character
+			if pos.Line == prev.Line {
+				delta -= prev.Character
+			}
+			data = append(data, pos.Line-prev.Line, delta, length, typ, 0)
+			prev = pos
+		}
+	}
+	return data
+}
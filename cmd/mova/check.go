@@ -0,0 +1,147 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/friedelschoen/mova"
+)
+
+// checkCommand builds machine files and reports their errors, e.g. in CI.
+func checkCommand(args []string) error {
+	flags := flag.NewFlagSet("check", flag.ExitOnError)
+	manifestPath := flags.String("manifest", "", "JSON manifest of the registry")
+	strict := flags.Bool("strict", false, "reject shadowed and unused declarations and dead ends, see mova.WithStrict")
+	exhaustive := flags.Bool("exhaustive", false, "reject states which do not handle every event, see mova.WithExhaustive")
+	format := flags.String("format", "text", "output format, text, json or sarif")
+	flags.Parse(args)
+	if flags.NArg() == 0 || *manifestPath == "" || !slices.Contains([]string{"text", "json", "sarif"}, *format) {
+		return fmt.Errorf("usage: mova check -manifest mova.json [-strict] [-exhaustive] [-format text|json|sarif] file.mova...")
+	}
+	mf, _, err := loadManifest(*manifestPath)
+	if err != nil {
+		return err
+	}
+	reg, err := mf.Stub(nil)
+	if err != nil {
+		return err
+	}
+	opts := []mova.BuildOption{mova.WithMissingCheck()}
+	if *strict {
+		opts = append(opts, mova.WithStrict())
+	}
+	if *exhaustive {
+		opts = append(opts, mova.WithExhaustive())
+	}
+	var diags []mova.Diagnostic
+	sources := make(map[string][]byte)
+	failed := false
+	for _, path := range flags.Args() {
+		src, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		sources[path] = src
+		cm, err := mova.BuildMachine(path, bytes.NewReader(src), reg, nil, opts...)
+		if err != nil {
+			failed = true
+			if *format == "text" {
+				fmt.Fprintln(os.Stderr, err)
+			}
+		}
+		found := mova.Diagnostics(err)
+		if cm != nil {
+			found = append(found, cm.Warnings()...)
+			if *format == "text" {
+				// like errors, warnings start with their location
+				for _, d := range cm.Warnings() {
+					fmt.Fprintf(os.Stderr, "%s:%d:%d: %s\n", d.File, d.Range.Start.Line, d.Range.Start.Column, d.Message)
+				}
+			}
+		}
+		for _, d := range found {
+			if d.File == "" {
+				d.File = path
+			}
+			diags = append(diags, d)
+		}
+	}
+	switch *format {
+	case "json":
+		err = writeRDJSON(os.Stdout, diags)
+	case "sarif":
+		err = writeSARIF(os.Stdout, diags, sources)
+	}
+	if err != nil {
+		return err
+	}
+	if failed {
+		return fmt.Errorf("check failed")
+	}
+	return nil
+}
+
+// rdjson is the diagnostic format of reviewdog, see https://github.com/reviewdog/reviewdog/tree/master/proto/rdf.
+type rdjson struct {
+	Source      rdjsonSource       `json:"source"`
+	Diagnostics []rdjsonDiagnostic `json:"diagnostics"`
+}
+
+type rdjsonSource struct {
+	Name string `json:"name"`
+}
+
+type rdjsonDiagnostic struct {
+	Message  string         `json:"message"`
+	Location rdjsonLocation `json:"location"`
+	Severity string         `json:"severity"`
+	Code     struct {
+		Value string `json:"value"`
+	} `json:"code"`
+}
+
+type rdjsonLocation struct {
+	Path  string       `json:"path"`
+	Range *rdjsonRange `json:"range,omitempty"`
+}
+
+type rdjsonRange struct {
+	Start rdjsonPosition `json:"start"`
+	End   rdjsonPosition `json:"end"`
+}
+
+// rdjsonPosition counts lines and columns from 1, columns in bytes.
+type rdjsonPosition struct {
+	Line   int `json:"line"`
+	Column int `json:"column"`
+}
+
+// writeRDJSON writes diags in the rdjson format of reviewdog, which includes file, range,
+// severity, code and message of each diagnostic.
+func writeRDJSON(w io.Writer, diags []mova.Diagnostic) error {
+	out := rdjson{Source: rdjsonSource{Name: "mova"}, Diagnostics: []rdjsonDiagnostic{}}
+	for _, d := range diags {
+		rd := rdjsonDiagnostic{
+			Message:  d.Message,
+			Location: rdjsonLocation{Path: d.File},
+			Severity: strings.ToUpper(d.Severity),
+		}
+		rd.Code.Value = d.Code
+		if d.Range.Start.Line > 0 {
+			rd.Location.Range = &rdjsonRange{
+				Start: rdjsonPosition{d.Range.Start.Line, d.Range.Start.Column + 1},
+				End:   rdjsonPosition{d.Range.End.Line, d.Range.End.Column + 1},
+			}
+		}
+		out.Diagnostics = append(out.Diagnostics, rd)
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(out)
+}
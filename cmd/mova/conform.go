@@ -0,0 +1,58 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/friedelschoen/mova"
+)
+
+// conformCommand replays recorded traces on a machine with stubbed actions and reports where it
+// diverges, e.g. to check a new version of a machine against production journals.
+func conformCommand(args []string) error {
+	flags := flag.NewFlagSet("conform", flag.ExitOnError)
+	manifestPath := flags.String("manifest", "", "JSON manifest of the registry")
+	allowErrors := flags.Bool("allow-errors", false, "do not report events failing with an error")
+	flags.Parse(args)
+	if flags.NArg() < 2 || *manifestPath == "" {
+		return fmt.Errorf("usage: mova conform -manifest mova.json [-allow-errors] file.mova trace.jsonl...")
+	}
+	mf, _, err := loadManifest(*manifestPath)
+	if err != nil {
+		return err
+	}
+	reg, err := mf.Stub(nil)
+	if err != nil {
+		return err
+	}
+	src, err := os.ReadFile(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	cm, err := mova.BuildMachine(flags.Arg(0), bytes.NewReader(src), reg, nil)
+	if err != nil {
+		return err
+	}
+	var traces []*mova.Trace
+	for _, path := range flags.Args()[1:] {
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		found, err := mova.ReadTraces(path, f)
+		f.Close()
+		if err != nil {
+			return err
+		}
+		traces = append(traces, found...)
+	}
+	report := cm.Conform(mova.ConformanceOptions{AllowErrors: *allowErrors}, traces...)
+	fmt.Println(report)
+	if !report.OK() {
+		return errors.New("machine does not conform")
+	}
+	return nil
+}
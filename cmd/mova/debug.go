@@ -0,0 +1,261 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/friedelschoen/mova"
+)
+
+type debugger struct {
+	in     *bufio.Scanner
+	out    io.Writer
+	color  bool
+	mf     *mova.Manifest
+	reg    *mova.Registry
+	states map[string]*mova.State
+	m      *mova.StateMachine
+}
+
+func debugCommand(args []string) error {
+	flags := flag.NewFlagSet("debug", flag.ExitOnError)
+	manifestPath := flags.String("manifest", "", "JSON manifest of the registry")
+	flags.Parse(args)
+	if flags.NArg() != 1 || *manifestPath == "" {
+		return fmt.Errorf("usage: mova debug -manifest mova.json file.mova")
+	}
+	mf, _, err := loadManifest(*manifestPath)
+	if err != nil {
+		return err
+	}
+	d := &debugger{
+		in:     bufio.NewScanner(os.Stdin),
+		out:    os.Stdout,
+		color:  isTerminal(os.Stdout),
+		mf:     mf,
+		states: make(map[string]*mova.State),
+	}
+	d.reg, err = mf.Stub(d.call)
+	if err != nil {
+		return err
+	}
+	path := flags.Arg(0)
+	f, err := parseFile(path, d.reg)
+	if err != nil {
+		return err
+	}
+	for _, entry := range f.Entries {
+		if st, ok := entry.(*mova.State); ok {
+			d.states[st.Name] = st
+		}
+	}
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	cm, err := mova.BuildMachine(path, src, d.reg, nil)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(d.out, "type a number or event name to emit it, 'break <state>' or 'clear <state>' to pause")
+	fmt.Fprintln(d.out, "in a state, 'reset' to restart, 'help' or 'quit'")
+	d.m, err = cm.New(mova.WithTransitionHook(d.transition))
+	if err != nil {
+		return err
+	}
+	return d.run()
+}
+
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// style wraps s in an ANSI escape sequence when writing to a terminal.
+func (d *debugger) style(code, s string) string {
+	if !d.color {
+		return s
+	}
+	return "\033[" + code + "m" + s + "\033[0m"
+}
+
+func show(v any) string {
+	if s, ok := v.(string); ok {
+		return strconv.Quote(s)
+	}
+	return fmt.Sprint(v)
+}
+
+func (d *debugger) call(action string, args map[string]any) {
+	var parts []string
+	for _, arg := range d.mf.Actions[action].Args {
+		parts = append(parts, arg.Name+"="+show(args[arg.Name]))
+	}
+	fmt.Fprintf(d.out, "  %s %s(%s)\n", d.style("2", "call"), action, strings.Join(parts, ", "))
+}
+
+func (d *debugger) transition(m *mova.StateMachine, from, to string) {
+	fmt.Fprintf(d.out, "  %s %s\n", d.style("2", "move"), d.style("1", to))
+}
+
+func formatCondition(c mova.TriggerCond) string {
+	if len(c.Params) == 0 {
+		return c.Name
+	}
+	var params []string
+	for _, p := range c.Params {
+		if p.Value == nil {
+			params = append(params, p.Key)
+		} else {
+			params = append(params, p.Key+"="+formatValue(p.Value))
+		}
+	}
+	return c.Name + "(" + strings.Join(params, ", ") + ")"
+}
+
+// available lists the triggers handled by the current state.
+func (d *debugger) available() []mova.TriggerCond {
+	var conds []mova.TriggerCond
+	if st, ok := d.states[d.m.Current()]; ok {
+		for _, trg := range st.Triggers {
+			conds = append(conds, trg.Cond...)
+		}
+	}
+	return conds
+}
+
+func (d *debugger) prompt(text string) (string, bool) {
+	fmt.Fprint(d.out, text)
+	if !d.in.Scan() {
+		fmt.Fprintln(d.out)
+		return "", false
+	}
+	return strings.TrimSpace(d.in.Text()), true
+}
+
+func (d *debugger) run() error {
+	for {
+		conds := d.available()
+		fmt.Fprintf(d.out, "\nstate %s\n", d.style("1", d.m.Current()))
+		for i, c := range conds {
+			fmt.Fprintf(d.out, "  %d) %s\n", i+1, formatCondition(c))
+		}
+		line, ok := d.prompt("> ")
+		if !ok {
+			return d.in.Err()
+		}
+		switch line {
+		case "":
+			continue
+		case "quit", "q":
+			return nil
+		case "help", "?":
+			fmt.Fprintln(d.out, "events:", strings.Join(slices.Sorted(maps.Keys(d.mf.Triggers)), ", "))
+			continue
+		case "reset":
+			if err := d.paused(d.m.Reset); err != nil {
+				fmt.Fprintln(d.out, d.style("31", err.Error()))
+			}
+			continue
+		}
+		if state, ok := strings.CutPrefix(line, "break "); ok {
+			d.m.SetBreakpoint(strings.TrimSpace(state))
+			continue
+		}
+		if state, ok := strings.CutPrefix(line, "clear "); ok {
+			d.m.ClearBreakpoint(strings.TrimSpace(state))
+			continue
+		}
+		name := line
+		if i, err := strconv.Atoi(line); err == nil {
+			if i < 1 || i > len(conds) {
+				fmt.Fprintln(d.out, d.style("31", "no such trigger"))
+				continue
+			}
+			name = conds[i-1].Name
+		}
+		data, err := d.payload(name)
+		if err == io.EOF {
+			return d.in.Err()
+		}
+		var v any
+		if err == nil {
+			v, err = d.reg.Decode(name, data)
+		}
+		if err != nil {
+			fmt.Fprintln(d.out, d.style("31", err.Error()))
+			continue
+		}
+		if err := d.paused(func() error { return d.m.Emit(name, v) }); errors.Is(err, io.EOF) {
+			fmt.Fprintln(d.out, d.style("2", "  not handled"))
+		} else if err != nil {
+			fmt.Fprintln(d.out, d.style("31", err.Error()))
+		}
+	}
+}
+
+// payload prompts for the event-data of event name and encodes it as JSON,
+// it returns io.EOF if the input ended.
+func (d *debugger) payload(name string) ([]byte, error) {
+	fields := make(map[string]any)
+	for _, f := range d.mf.Triggers[name] {
+		text, ok := d.prompt(fmt.Sprintf("  %s (%s): ", f.Name, f.Type))
+		if !ok {
+			return nil, io.EOF
+		}
+		switch {
+		case text == "":
+			// zero value
+		case f.Type == "string":
+			fields[f.Name] = text
+		case f.Type == "duration":
+			dur, err := time.ParseDuration(text)
+			if err != nil {
+				return nil, err
+			}
+			fields[f.Name] = int64(dur)
+		case json.Valid([]byte(text)):
+			fields[f.Name] = json.RawMessage(text)
+		default:
+			return nil, fmt.Errorf("invalid value for %s: %s", f.Name, text)
+		}
+	}
+	return json.Marshal(fields)
+}
+
+// paused runs fn, which may hit a breakpoint, on a separate goroutine. At every break it shows the
+// bindings and lets the user step through the actions.
+func (d *debugger) paused(fn func() error) error {
+	done := make(chan error, 1)
+	go func() {
+		done <- fn()
+	}()
+	for {
+		select {
+		case err := <-done:
+			return err
+		case brk := <-d.m.Breaks():
+			fmt.Fprintf(d.out, "  %s %s, action #%d\n", d.style("33", "break"), brk.State, brk.Action)
+			for _, name := range slices.Sorted(maps.Keys(brk.Bindings)) {
+				fmt.Fprintf(d.out, "    %s = %s\n", name, show(brk.Bindings[name]))
+			}
+			line, ok := d.prompt("  step or continue? ")
+			if ok && (line == "s" || line == "step") {
+				d.m.Step()
+			} else {
+				d.m.Continue()
+			}
+		}
+	}
+}
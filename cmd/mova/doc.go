@@ -0,0 +1,439 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	htmltemplate "html/template"
+	"io"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	texttemplate "text/template"
+	"time"
+
+	"github.com/friedelschoen/mova"
+)
+
+type docMachine struct {
+	Name      string
+	Constants []docConstant
+	Variables []docVariable
+	States    []docState
+	Actions   []docAction
+	Diagram   string
+}
+
+type docConstant struct {
+	Name, Value string
+}
+
+// docVariable is an instance variable, Type or Value are empty if omitted.
+type docVariable struct {
+	Name, Type, Value string
+}
+
+type docState struct {
+	Name       string
+	Params     string
+	Initial    bool
+	Task       string
+	Vars       []string
+	Ignore     []string
+	Init       []string
+	Compensate []string
+	Triggers   []docTrigger
+}
+
+type docTrigger struct {
+	When string
+	Then []string
+}
+
+type docAction struct {
+	Name      string
+	Signature string
+	Async     bool
+}
+
+func docCommand(args []string) error {
+	flags := flag.NewFlagSet("doc", flag.ExitOnError)
+	manifestPath := flags.String("manifest", "", "JSON manifest of the registry, for action signatures")
+	format := flags.String("format", "markdown", "output format, markdown or html")
+	l := layoutFlags(flags)
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		return fmt.Errorf("usage: mova doc [-manifest mova.json] [-format markdown|html] [-group name=state,...] file.mova")
+	}
+	mf, reg, err := loadManifest(*manifestPath)
+	if err != nil {
+		return err
+	}
+	path := flags.Arg(0)
+	f, err := parseFile(path, reg)
+	if err != nil {
+		return err
+	}
+	if err := l.check(fileStates(f)); err != nil {
+		return err
+	}
+	doc := describe(strings.TrimSuffix(filepath.Base(path), filepath.Ext(path)), f, mf, l)
+	switch *format {
+	case "markdown", "md":
+		return writeMarkdown(os.Stdout, doc)
+	case "html":
+		return writeHTML(os.Stdout, doc)
+	}
+	return fmt.Errorf("unknown format %q", *format)
+}
+
+func formatValue(v mova.Value) string {
+	switch v := v.(type) {
+	case *mova.ConstValue:
+		switch c := v.Value.(type) {
+		case string:
+			return strconv.Quote(c)
+		case time.Duration:
+			return c.String()
+		}
+		return fmt.Sprint(v.Value)
+	case *mova.ReferenceValue:
+		return v.Ref
+	case *mova.CastValue:
+		return v.Type + "(" + formatValue(v.Value) + ")"
+	case *mova.FuncValue:
+		args := make([]string, len(v.Args))
+		for i, arg := range v.Args {
+			args[i] = formatValue(arg)
+		}
+		return v.Name + "(" + strings.Join(args, ", ") + ")"
+	}
+	return fmt.Sprint(v)
+}
+
+func formatArgs(args map[string]mova.Value) string {
+	var parts []string
+	for _, key := range slices.Sorted(maps.Keys(args)) {
+		parts = append(parts, key+" = "+formatValue(args[key]))
+	}
+	return strings.Join(parts, ", ")
+}
+
+type move struct {
+	dest string
+	note string // appended to the label
+}
+
+// moves returns the transitions a statement may take.
+func moves(stmt mova.Statement) []move {
+	switch s := stmt.(type) {
+	case *mova.MoveStmt:
+		return []move{{dest: s.Dest}}
+	case *mova.ChoiceStmt:
+		var out []move
+		for _, b := range s.Branches {
+			out = append(out, move{b.Move.Dest, fmt.Sprintf(" (%v%%)", b.Weight)})
+		}
+		return out
+	case *mova.IfStmt:
+		var out []move
+		for _, stmt := range s.Then {
+			for _, m := range moves(stmt) {
+				out = append(out, move{m.dest, " if " + formatValue(s.Cond) + m.note})
+			}
+		}
+		for _, stmt := range s.Else {
+			for _, m := range moves(stmt) {
+				out = append(out, move{m.dest, " unless " + formatValue(s.Cond) + m.note})
+			}
+		}
+		return out
+	}
+	return nil
+}
+
+// describeStatement renders an action in prose.
+func describeStatement(stmt mova.Statement) string {
+	switch s := stmt.(type) {
+	case *mova.MoveStmt:
+		if len(s.Args) > 0 {
+			return fmt.Sprintf("go to **%s** with %s", s.Dest, formatArgs(s.Args))
+		}
+		return fmt.Sprintf("go to **%s**", s.Dest)
+	case *mova.ChoiceStmt:
+		var parts []string
+		for _, b := range s.Branches {
+			parts = append(parts, fmt.Sprintf("%v%% %s", b.Weight, strings.TrimPrefix(describeStatement(b.Move), "go to ")))
+		}
+		return "go at random to " + strings.Join(parts, " or ")
+	case *mova.Call:
+		text := "call `" + s.Name + "`"
+		if len(s.Args) > 0 {
+			text += " with " + formatArgs(s.Args)
+		}
+		if s.Policy.Timeout > 0 {
+			text += fmt.Sprintf(", giving up after %v", s.Policy.Timeout)
+		}
+		if s.Policy.Retries > 0 {
+			text += fmt.Sprintf(", retrying up to %d times", s.Policy.Retries)
+		}
+		return text
+	case *mova.AssignStmt:
+		return "set `" + s.Name + "` to " + formatValue(s.Value)
+	case *mova.CaptureStmt:
+		return describeStatement(s.Call) + ", keeping its result as `" + s.Name + "`"
+	case *mova.IfStmt:
+		text := "if " + formatValue(s.Cond) + ": " + describeStatements(s.Then)
+		if len(s.Else) > 0 {
+			text += "; otherwise " + describeStatements(s.Else)
+		}
+		return text
+	case *mova.ParallelStmt:
+		var parts []string
+		for _, stmt := range s.Actions {
+			parts = append(parts, describeStatement(stmt))
+		}
+		return "at the same time " + strings.Join(parts, " and ")
+	case *mova.ScheduleStmt:
+		text := "emit `" + s.Event + "`"
+		if len(s.Args) > 0 {
+			text += " with " + formatArgs(s.Args)
+		}
+		return text + fmt.Sprintf(" after %v", s.After)
+	}
+	return fmt.Sprintf("%T", stmt)
+}
+
+// describeStatements renders a list of actions in prose.
+func describeStatements(stmts []mova.Statement) string {
+	if len(stmts) == 0 {
+		return "nothing"
+	}
+	var parts []string
+	for _, stmt := range stmts {
+		parts = append(parts, describeStatement(stmt))
+	}
+	return strings.Join(parts, ", then ")
+}
+
+// describeCondition renders a trigger condition in prose.
+func describeCondition(c mova.TriggerCond) string {
+	text := "**" + c.Name + "**"
+	var conds, binds []string
+	for _, p := range c.Params {
+		if p.Value == nil {
+			binds = append(binds, p.Key)
+		} else {
+			conds = append(conds, p.Key+" = "+formatValue(p.Value))
+		}
+	}
+	if len(conds) > 0 {
+		text += " where " + strings.Join(conds, " and ")
+	}
+	if len(binds) > 0 {
+		text += " (using " + strings.Join(binds, ", ") + ")"
+	}
+	return text
+}
+
+func describe(name string, f *mova.File, mf *mova.Manifest, l *layout) *docMachine {
+	doc := &docMachine{Name: name}
+	used := make(map[string]bool)
+	var states []*mova.State
+	for _, entry := range f.Entries {
+		switch e := entry.(type) {
+		case *mova.SetStmt:
+			doc.Constants = append(doc.Constants, docConstant{e.Key, formatValue(e.Value)})
+		case *mova.VarDecl:
+			v := docVariable{Name: e.Name, Type: e.Type}
+			if e.Value != nil {
+				v.Value = formatValue(e.Value)
+			}
+			doc.Variables = append(doc.Variables, v)
+		case *mova.State:
+			states = append(states, e)
+		}
+	}
+	for i, st := range states {
+		ds := docState{Name: st.Name, Initial: i == 0}
+		var params []string
+		for _, p := range st.Params {
+			params = append(params, p.Name+": "+p.Type)
+		}
+		ds.Params = strings.Join(params, ", ")
+		collect := func(stmts []mova.Statement) []string {
+			var out []string
+			for _, stmt := range stmts {
+				mova.Inspect(stmt, func(n mova.Node) bool {
+					if call, ok := n.(*mova.Call); ok {
+						used[call.Name] = true
+					}
+					return true
+				})
+				out = append(out, describeStatement(stmt))
+			}
+			return out
+		}
+		for _, decl := range st.Vars {
+			ds.Vars = append(ds.Vars, strings.TrimSuffix(strings.TrimPrefix(decl.String(), "var "), ";"))
+		}
+		ds.Ignore = st.Ignore
+		ds.Init = collect(st.Init)
+		ds.Compensate = collect(st.Compensate)
+		ds.Task = st.Task
+		for _, trg := range st.Triggers {
+			var when []string
+			for _, c := range trg.Cond {
+				when = append(when, describeCondition(c))
+			}
+			ds.Triggers = append(ds.Triggers, docTrigger{When: strings.Join(when, " or "), Then: collect(trg.Actions)})
+		}
+		doc.States = append(doc.States, ds)
+	}
+	for _, name := range slices.Sorted(maps.Keys(used)) {
+		da := docAction{Name: name}
+		if action, ok := mf.Actions[name]; ok {
+			var args []string
+			for _, arg := range action.Args {
+				args = append(args, arg.Name+" "+arg.Type)
+			}
+			da.Signature = "(" + strings.Join(args, ", ") + ")"
+			da.Async = action.Async
+		}
+		doc.Actions = append(doc.Actions, da)
+	}
+	doc.Diagram = mermaid(states, l)
+	return doc
+}
+
+const markdownTemplate = `# {{.Name}}
+
+` + "```mermaid\n{{.Diagram}}```" + `
+{{if .Constants}}
+## Constants
+
+| Name | Value |
+| ---- | ----- |
+{{range .Constants}}| {{.Name}} | ` + "`{{.Value}}`" + ` |
+{{end}}{{end}}{{if .Variables}}
+## Variables
+
+| Name | Type | Initial value |
+| ---- | ---- | ------------- |
+{{range .Variables}}| {{.Name}} | {{.Type}} | {{if .Value}}` + "`{{.Value}}`" + `{{end}} |
+{{end}}{{end}}
+## States
+{{range .States}}
+### {{.Name}}{{if .Params}}({{.Params}}){{end}}
+{{if .Initial}}
+The machine starts in this state.
+{{end}}{{if .Task}}
+The machine waits for the external task **{{.Task}}**.
+{{end}}{{if .Vars}}
+Variables, reset on entering: ` + "`{{join .Vars \"`, `\"}}`" + `.
+{{end}}{{if .Init}}
+On entering: {{join .Init "; "}}.
+{{end}}{{if .Compensate}}
+To compensate: {{join .Compensate "; "}}.
+{{end}}{{range .Triggers}}
+* When {{.When}}: {{join .Then "; "}}.{{end}}{{if .Ignore}}
+* Ignores {{join .Ignore ", "}}.{{end}}
+{{end}}{{if .Actions}}
+## Actions
+
+| Action | Arguments |
+| ------ | --------- |
+{{range .Actions}}| ` + "`{{.Name}}`" + ` | {{if .Signature}}` + "`{{.Signature}}`" + `{{end}}{{if .Async}} asynchronous{{end}} |
+{{end}}{{end}}`
+
+func writeMarkdown(w io.Writer, doc *docMachine) error {
+	tmpl := texttemplate.Must(texttemplate.New("doc").Funcs(texttemplate.FuncMap{"join": strings.Join}).Parse(markdownTemplate))
+	return tmpl.Execute(w, doc)
+}
+
+const htmlTemplate = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Name}}</title>
+<script type="module">
+import mermaid from "https://cdn.jsdelivr.net/npm/mermaid@11/dist/mermaid.esm.min.mjs";
+mermaid.initialize({ startOnLoad: true });
+</script>
+<style>
+body { font-family: sans-serif; max-width: 60em; margin: auto; }
+table { border-collapse: collapse; }
+td, th { border: 1px solid #ccc; padding: 0.2em 0.6em; text-align: left; }
+</style>
+</head>
+<body>
+<h1>{{.Name}}</h1>
+<pre class="mermaid">
+{{.Diagram}}</pre>
+{{if .Constants}}
+<h2>Constants</h2>
+<table>
+<tr><th>Name</th><th>Value</th></tr>
+{{range .Constants}}<tr><td>{{.Name}}</td><td><code>{{.Value}}</code></td></tr>
+{{end}}</table>
+{{end}}
+{{if .Variables}}
+<h2>Variables</h2>
+<table>
+<tr><th>Name</th><th>Type</th><th>Initial value</th></tr>
+{{range .Variables}}<tr><td>{{.Name}}</td><td>{{.Type}}</td><td>{{if .Value}}<code>{{.Value}}</code>{{end}}</td></tr>
+{{end}}</table>
+{{end}}
+<h2>States</h2>
+{{range .States}}
+<h3>{{.Name}}{{if .Params}}({{.Params}}){{end}}</h3>
+{{if .Initial}}<p>The machine starts in this state.</p>{{end}}
+{{if .Task}}<p>The machine waits for the external task <strong>{{.Task}}</strong>.</p>{{end}}
+{{if .Vars}}<p>Variables, reset on entering: {{range $i, $v := .Vars}}{{if $i}}, {{end}}<code>{{$v}}</code>{{end}}.</p>{{end}}
+{{if .Init}}<p>On entering: {{prose (join .Init "; ")}}.</p>{{end}}
+{{if .Compensate}}<p>To compensate: {{prose (join .Compensate "; ")}}.</p>{{end}}
+{{if .Triggers}}<ul>
+{{range .Triggers}}<li>When {{prose .When}}: {{prose (join .Then "; ")}}.</li>
+{{end}}</ul>{{end}}
+{{if .Ignore}}<p>Ignores {{join .Ignore ", "}}.</p>{{end}}
+{{end}}
+{{if .Actions}}
+<h2>Actions</h2>
+<table>
+<tr><th>Action</th><th>Arguments</th></tr>
+{{range .Actions}}<tr><td><code>{{.Name}}</code></td><td>{{if .Signature}}<code>{{.Signature}}</code>{{end}}{{if .Async}} asynchronous{{end}}</td></tr>
+{{end}}</table>
+{{end}}
+</body>
+</html>
+`
+
+// prose converts the **bold** and `code` markup of descriptions to HTML.
+func prose(s string) htmltemplate.HTML {
+	s = htmltemplate.HTMLEscapeString(s)
+	for _, m := range []struct{ mark, open, close string }{{"**", "<b>", "</b>"}, {"`", "<code>", "</code>"}} {
+		parts := strings.Split(s, m.mark)
+		var b strings.Builder
+		for i, part := range parts {
+			switch {
+			case i == 0:
+			case i%2 == 1 && i == len(parts)-1: // unmatched
+				b.WriteString(m.mark)
+			case i%2 == 1:
+				b.WriteString(m.open)
+			default:
+				b.WriteString(m.close)
+			}
+			b.WriteString(part)
+		}
+		s = b.String()
+	}
+	return htmltemplate.HTML(s)
+}
+
+func writeHTML(w io.Writer, doc *docMachine) error {
+	tmpl := htmltemplate.Must(htmltemplate.New("doc").Funcs(htmltemplate.FuncMap{"join": strings.Join, "prose": prose}).Parse(htmlTemplate))
+	return tmpl.Execute(w, doc)
+}
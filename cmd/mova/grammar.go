@@ -0,0 +1,35 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/friedelschoen/mova"
+)
+
+// grammarCommand writes the TextMate grammar and language configuration for editor extensions.
+func grammarCommand(args []string) error {
+	flags := flag.NewFlagSet("grammar", flag.ExitOnError)
+	manifestPath := flags.String("manifest", "", "JSON manifest of the registry, for literals of custom types")
+	dir := flags.String("o", ".", "directory to write the files to")
+	flags.Parse(args)
+	if flags.NArg() != 0 {
+		return fmt.Errorf("usage: mova grammar [-manifest mova.json] [-o dir]")
+	}
+	_, reg, err := loadManifest(*manifestPath)
+	if err != nil {
+		return err
+	}
+	files := map[string][]byte{
+		"mova.tmLanguage.json":        reg.TextMateGrammar(),
+		"language-configuration.json": mova.LanguageConfiguration(),
+	}
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(*dir, name), data, 0o644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
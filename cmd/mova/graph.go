@@ -0,0 +1,241 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/friedelschoen/mova"
+)
+
+// layout holds hints for rendering diagrams, so diagrams of the same machine stay stable between
+// builds.
+type layout struct {
+	groups []stateGroup
+	rank   bool // place states in rows following their order in the file, only used by dot
+}
+
+// stateGroup draws states together in a box.
+type stateGroup struct {
+	name   string
+	states []string
+}
+
+// layoutFlags registers the flags for layout hints.
+func layoutFlags(flags *flag.FlagSet) *layout {
+	var l layout
+	flags.Func("group", "draw states together, as name=state,state,... (repeatable)", func(s string) error {
+		name, states, ok := strings.Cut(s, "=")
+		if !ok || name == "" || states == "" {
+			return fmt.Errorf("expected name=state,state,..., got %q", s)
+		}
+		l.groups = append(l.groups, stateGroup{name, strings.Split(states, ",")})
+		return nil
+	})
+	flags.BoolVar(&l.rank, "rank", false, "rank states in the order they are declared")
+	return &l
+}
+
+// check verifies that groups only name states of the machine, each at most once.
+func (l *layout) check(states []*mova.State) error {
+	seen := make(map[string]string)
+	for _, g := range l.groups {
+		for _, name := range g.states {
+			if !slices.ContainsFunc(states, func(st *mova.State) bool { return st.Name == name }) {
+				return fmt.Errorf("group %s: unknown state %q", g.name, name)
+			}
+			if other, ok := seen[name]; ok {
+				return fmt.Errorf("group %s: state %q already in group %s", g.name, name, other)
+			}
+			seen[name] = g.name
+		}
+	}
+	return nil
+}
+
+// group returns the index of the group of state, or -1.
+func (l *layout) group(state string) int {
+	return slices.IndexFunc(l.groups, func(g stateGroup) bool { return slices.Contains(g.states, state) })
+}
+
+type edge struct {
+	from, to, label string
+}
+
+// edges lists the transitions between states in file order.
+func edges(states []*mova.State) []edge {
+	var out []edge
+	for _, st := range states {
+		for _, trg := range st.Triggers {
+			var names []string
+			for _, c := range trg.Cond {
+				names = append(names, c.Name)
+			}
+			for _, stmt := range trg.Actions {
+				for _, e := range moves(stmt) {
+					out = append(out, edge{st.Name, e.dest, strings.Join(names, " or ") + e.note})
+				}
+			}
+		}
+		for _, stmt := range st.Init {
+			for _, e := range moves(stmt) {
+				out = append(out, edge{st.Name, e.dest, strings.TrimSpace(e.note)})
+			}
+		}
+	}
+	return out
+}
+
+// stateIDs numbers states in file order, so identifiers do not depend on the transitions.
+func stateIDs(states []*mova.State) map[string]string {
+	ids := make(map[string]string)
+	for _, st := range states {
+		if _, ok := ids[st.Name]; !ok {
+			ids[st.Name] = fmt.Sprintf("s%d", len(ids))
+		}
+	}
+	return ids
+}
+
+// mermaid renders the transitions between states as a Mermaid state diagram. Mermaid does not
+// support ranks, states are declared in file order instead.
+func mermaid(states []*mova.State, l *layout) string {
+	var b strings.Builder
+	b.WriteString("stateDiagram-v2\n")
+	ids := stateIDs(states)
+	declare := func(indent, name string) {
+		fmt.Fprintf(&b, "%sstate %q as %s\n", indent, name, ids[name])
+	}
+	for i, g := range l.groups {
+		fmt.Fprintf(&b, "    state %q as g%d {\n", g.name, i)
+		for _, st := range states {
+			if slices.Contains(g.states, st.Name) {
+				declare("        ", st.Name)
+			}
+		}
+		b.WriteString("    }\n")
+	}
+	for _, st := range states {
+		if l.group(st.Name) == -1 {
+			declare("    ", st.Name)
+		}
+	}
+	if len(states) > 0 {
+		fmt.Fprintf(&b, "    [*] --> %s\n", ids[states[0].Name])
+	}
+	label := func(s string) string {
+		return strings.NewReplacer("\n", " ", ";", ",", "\"", "'").Replace(s)
+	}
+	for _, e := range edges(states) {
+		if _, ok := ids[e.to]; !ok {
+			ids[e.to] = fmt.Sprintf("s%d", len(ids))
+			declare("    ", e.to)
+		}
+		if e.label != "" {
+			fmt.Fprintf(&b, "    %s --> %s : %s\n", ids[e.from], ids[e.to], label(e.label))
+		} else {
+			fmt.Fprintf(&b, "    %s --> %s\n", ids[e.from], ids[e.to])
+		}
+	}
+	for _, st := range states {
+		if st.Final {
+			fmt.Fprintf(&b, "    %s --> [*]\n", ids[st.Name])
+		}
+	}
+	return b.String()
+}
+
+// dot renders the transitions between states as a Graphviz digraph.
+func dot(states []*mova.State, l *layout) string {
+	var b strings.Builder
+	b.WriteString("digraph {\n    node [shape=box, style=rounded];\n")
+	ids := stateIDs(states)
+	declare := func(indent string, st *mova.State) {
+		fmt.Fprintf(&b, "%s%s [label=%q", indent, ids[st.Name], st.Name)
+		if st.Final {
+			b.WriteString(", peripheries=2")
+		}
+		b.WriteString("];\n")
+	}
+	for i, g := range l.groups {
+		fmt.Fprintf(&b, "    subgraph cluster_%d {\n        label=%q;\n", i, g.name)
+		for _, st := range states {
+			if slices.Contains(g.states, st.Name) {
+				declare("        ", st)
+			}
+		}
+		b.WriteString("    }\n")
+	}
+	for _, st := range states {
+		if l.group(st.Name) == -1 {
+			declare("    ", st)
+		}
+	}
+	if len(states) > 0 {
+		fmt.Fprintf(&b, "    start [shape=point];\n    start -> %s;\n", ids[states[0].Name])
+	}
+	if l.rank {
+		for i := 1; i < len(states); i++ {
+			fmt.Fprintf(&b, "    %s -> %s [style=invis];\n", ids[states[i-1].Name], ids[states[i].Name])
+		}
+	}
+	for _, e := range edges(states) {
+		if _, ok := ids[e.to]; !ok {
+			ids[e.to] = fmt.Sprintf("s%d", len(ids))
+			fmt.Fprintf(&b, "    %s [label=%q, style=dashed];\n", ids[e.to], e.to)
+		}
+		if e.label != "" {
+			fmt.Fprintf(&b, "    %s -> %s [label=%q];\n", ids[e.from], ids[e.to], e.label)
+		} else {
+			fmt.Fprintf(&b, "    %s -> %s;\n", ids[e.from], ids[e.to])
+		}
+	}
+	b.WriteString("}\n")
+	return b.String()
+}
+
+// graphCommand prints the diagram of a machine on its own, e.g. to commit it next to the file.
+func graphCommand(args []string) error {
+	flags := flag.NewFlagSet("graph", flag.ExitOnError)
+	manifestPath := flags.String("manifest", "", "JSON manifest of the registry, for custom types and tokens")
+	format := flags.String("format", "dot", "output format, dot or mermaid")
+	l := layoutFlags(flags)
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		return fmt.Errorf("usage: mova graph [-manifest mova.json] [-format dot|mermaid] [-group name=state,...] [-rank] file.mova")
+	}
+	_, reg, err := loadManifest(*manifestPath)
+	if err != nil {
+		return err
+	}
+	f, err := parseFile(flags.Arg(0), reg)
+	if err != nil {
+		return err
+	}
+	states := fileStates(f)
+	if err := l.check(states); err != nil {
+		return err
+	}
+	switch *format {
+	case "dot":
+		_, err = os.Stdout.WriteString(dot(states, l))
+	case "mermaid":
+		_, err = os.Stdout.WriteString(mermaid(states, l))
+	default:
+		err = fmt.Errorf("unknown format %q", *format)
+	}
+	return err
+}
+
+// fileStates returns the states of f in file order.
+func fileStates(f *mova.File) []*mova.State {
+	var states []*mova.State
+	for _, entry := range f.Entries {
+		if st, ok := entry.(*mova.State); ok {
+			states = append(states, st)
+		}
+	}
+	return states
+}
@@ -0,0 +1,80 @@
+// Command mova provides tools for working with machine files.
+//
+//	mova doc [-manifest mova.json] [-format markdown|html] [-group name=state,...] file.mova
+//	mova graph [-manifest mova.json] [-format dot|mermaid] [-group name=state,...] [-rank] file.mova
+//	mova debug -manifest mova.json file.mova
+//	mova check -manifest mova.json [-strict] [-exhaustive] file.mova...
+//	mova conform -manifest mova.json [-allow-errors] file.mova trace.jsonl...
+//	mova scaffold -manifest mova.json [-state start] > file.mova
+//	mova grammar [-manifest mova.json] [-o dir]
+//
+// A manifest, written by the application using json.NewEncoder(f).Encode(reg.Manifest()),
+// describes the triggers, actions and types of the registry the machine runs with.
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/friedelschoen/mova"
+)
+
+var commands = map[string]func(args []string) error{
+	"doc":      docCommand,
+	"graph":    graphCommand,
+	"debug":    debugCommand,
+	"check":    checkCommand,
+	"conform":  conformCommand,
+	"scaffold": scaffoldCommand,
+	"grammar":  grammarCommand,
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: mova <command> [arguments]")
+	fmt.Fprintln(os.Stderr, "commands: doc, graph, debug, check, conform, scaffold, grammar")
+	os.Exit(2)
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+	}
+	cmd, ok := commands[os.Args[1]]
+	if !ok {
+		usage()
+	}
+	if err := cmd(os.Args[2:]); err != nil {
+		fmt.Fprintln(os.Stderr, "mova:", err)
+		os.Exit(1)
+	}
+}
+
+// loadManifest reads a manifest, an empty path results in an empty manifest.
+func loadManifest(path string) (*mova.Manifest, *mova.Registry, error) {
+	mf := &mova.Manifest{}
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, nil, err
+		}
+		if err := json.Unmarshal(data, mf); err != nil {
+			return nil, nil, fmt.Errorf("%s: %w", path, err)
+		}
+	}
+	reg, err := mf.Registry()
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return mf, reg, nil
+}
+
+// parseFile parses the machine file at path.
+func parseFile(path string, reg *mova.Registry) (*mova.File, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return mova.Parse(path, f, reg)
+}
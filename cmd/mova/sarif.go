@@ -0,0 +1,122 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"path/filepath"
+	"unicode/utf16"
+
+	"github.com/friedelschoen/mova"
+)
+
+// The subset of SARIF 2.1.0 used by GitHub code scanning, see
+// https://docs.oasis-open.org/sarif/sarif/v2.1.0/sarif-v2.1.0.html.
+
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID               string       `json:"id"`
+	ShortDescription sarifMessage `json:"shortDescription"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleID    string          `json:"ruleId"`
+	Level     string          `json:"level"`
+	Message   sarifMessage    `json:"message"`
+	Locations []sarifLocation `json:"locations"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation struct {
+		ArtifactLocation struct {
+			URI string `json:"uri"`
+		} `json:"artifactLocation"`
+		Region *sarifRegion `json:"region,omitempty"`
+	} `json:"physicalLocation"`
+}
+
+// sarifRegion counts lines and columns from 1, columns in UTF-16 code units.
+type sarifRegion struct {
+	StartLine   int `json:"startLine"`
+	StartColumn int `json:"startColumn"`
+	EndLine     int `json:"endLine"`
+	EndColumn   int `json:"endColumn"`
+}
+
+// sarifRules describes the codes of mova.Diagnostic.
+var sarifRules = []sarifRule{
+	{"syntax", sarifMessage{"Syntax error"}},
+	{"missing-action", sarifMessage{"Action not specified by the registry"}},
+	{"missing-trigger", sarifMessage{"Trigger not specified by the registry"}},
+	{"compile", sarifMessage{"Invalid machine definition"}},
+	{"deprecated", sarifMessage{"Deprecated trigger or action name"}},
+}
+
+// sarifColumn converts the byte column of pos in src to a SARIF column.
+func sarifColumn(src []byte, pos mova.Position) int {
+	start := min(max(pos.Offset-pos.Column, 0), len(src))
+	end := min(max(pos.Offset, start), len(src))
+	return len(utf16.Encode([]rune(string(src[start:end])))) + 1
+}
+
+// writeSARIF writes diags as SARIF log, sources holds the contents of the checked files.
+func writeSARIF(w io.Writer, diags []mova.Diagnostic, sources map[string][]byte) error {
+	run := sarifRun{
+		Tool: sarifTool{Driver: sarifDriver{
+			Name:           "mova",
+			InformationURI: "https://github.com/friedelschoen/mova",
+			Rules:          sarifRules,
+		}},
+		Results: []sarifResult{},
+	}
+	for _, d := range diags {
+		res := sarifResult{
+			RuleID:    d.Code,
+			Level:     d.Severity,
+			Message:   sarifMessage{d.Message},
+			Locations: make([]sarifLocation, 1),
+		}
+		loc := &res.Locations[0].PhysicalLocation
+		loc.ArtifactLocation.URI = filepath.ToSlash(d.File)
+		if d.Range.Start.Line > 0 {
+			src := sources[d.File]
+			loc.Region = &sarifRegion{
+				StartLine:   d.Range.Start.Line,
+				StartColumn: sarifColumn(src, d.Range.Start),
+				EndLine:     d.Range.End.Line,
+				EndColumn:   sarifColumn(src, d.Range.End),
+			}
+		}
+		run.Results = append(run.Results, res)
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(sarifLog{
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Version: "2.1.0",
+		Runs:    []sarifRun{run},
+	})
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/friedelschoen/mova"
+)
+
+var plainName = regexp.MustCompile(`^[\pL_][\pL\pN_]*(\.[\pL_][\pL\pN_]*)*$`)
+
+// quoteName returns name as identifier of a machine file, quoted if necessary.
+func quoteName(name string) string {
+	switch name {
+	case "state", "on", "move", "true", "false":
+	default:
+		if plainName.MatchString(name) {
+			return name
+		}
+	}
+	return "'" + strings.NewReplacer(`\`, `\\`, "'", `\'`).Replace(name) + "'"
+}
+
+// signature renders fields as Go-like parameter list.
+func signature(fields []mova.ManifestField) string {
+	var parts []string
+	for _, f := range fields {
+		parts = append(parts, quoteName(f.Name)+" "+f.Type)
+	}
+	return "(" + strings.Join(parts, ", ") + ")"
+}
+
+// scaffoldCommand writes a skeleton machine file listing the events and actions of the manifest.
+func scaffoldCommand(args []string) error {
+	flags := flag.NewFlagSet("scaffold", flag.ExitOnError)
+	manifestPath := flags.String("manifest", "", "JSON manifest of the registry")
+	state := flags.String("state", "start", "name of the initial state")
+	flags.Parse(args)
+	if flags.NArg() != 0 || *manifestPath == "" {
+		return fmt.Errorf("usage: mova scaffold -manifest mova.json [-state start] > file.mova")
+	}
+	mf, _, err := loadManifest(*manifestPath)
+	if err != nil {
+		return err
+	}
+	return scaffold(os.Stdout, mf, *state)
+}
+
+func scaffold(w io.Writer, mf *mova.Manifest, state string) error {
+	var sb strings.Builder
+	sb.WriteString("# Skeleton generated by mova scaffold, uncomment and adapt the triggers.\n")
+	if len(mf.Triggers) > 0 {
+		sb.WriteString("#\n# Events, with their event-data:\n#\n")
+		for _, name := range slices.Sorted(maps.Keys(mf.Triggers)) {
+			fmt.Fprintf(&sb, "#   %s%s\n", quoteName(name), signature(mf.Triggers[name]))
+		}
+	}
+	if len(mf.Actions) > 0 {
+		sb.WriteString("#\n# Actions, with their arguments:\n#\n")
+		for _, name := range slices.Sorted(maps.Keys(mf.Actions)) {
+			action := mf.Actions[name]
+			fmt.Fprintf(&sb, "#   %s%s", quoteName(name), signature(action.Args))
+			if action.Async {
+				fmt.Fprintf(&sb, ", asynchronous, completes with %s", quoteName(name+".done"))
+			}
+			sb.WriteString("\n")
+		}
+	}
+	fmt.Fprintf(&sb, "\nstate %s {\n", quoteName(state))
+	for _, name := range slices.Sorted(maps.Keys(mf.Triggers)) {
+		var params []string
+		for _, f := range mf.Triggers[name] {
+			params = append(params, quoteName(f.Name))
+		}
+		cond := quoteName(name)
+		if len(params) > 0 {
+			cond += "(" + strings.Join(params, ", ") + ")"
+		}
+		fmt.Fprintf(&sb, "    # on %s -> move %s;\n", cond, quoteName(state))
+	}
+	sb.WriteString("};\n")
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
@@ -0,0 +1,170 @@
+package mova
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+)
+
+func isInt(k reflect.Kind) bool {
+	return k >= reflect.Int && k <= reflect.Int64 || k >= reflect.Uint && k <= reflect.Uintptr
+}
+
+func isFloat(k reflect.Kind) bool {
+	return k == reflect.Float32 || k == reflect.Float64
+}
+
+// nilable reports whether nil is a value of typ.
+func nilable(typ reflect.Type) bool {
+	switch typ.Kind() {
+	case reflect.Pointer, reflect.Interface, reflect.Slice, reflect.Map, reflect.Func, reflect.Chan:
+		return true
+	}
+	return false
+}
+
+// typeString names typ in errors, from is nil for nil.
+func typeString(typ reflect.Type) string {
+	if typ == nil {
+		return "nil"
+	}
+	return typ.String()
+}
+
+// coercible reports whether a value of type from may be used where to is expected, without a cast.
+// Besides identical types, integers may be used as any integer or float and floats as any float.
+// Named types, such as time.Duration, are never coerced. nil, of which from is nil, may be used as
+// pointer, interface, map, slice, func or chan.
+func coercible(from, to reflect.Type) bool {
+	if from == nil {
+		return nilable(to)
+	}
+	if from == to {
+		return true
+	}
+	if from.PkgPath() != "" || to.PkgPath() != "" {
+		return false
+	}
+	switch {
+	case isInt(from.Kind()):
+		return isInt(to.Kind()) || isFloat(to.Kind())
+	case isFloat(from.Kind()):
+		return isFloat(to.Kind())
+	}
+	return false
+}
+
+// coerce converts v to type to, which must be coercible from the type of v.
+func coerce(v any, to reflect.Type) any {
+	if v == nil {
+		return reflect.Zero(to).Interface() // a typed nil, equal to nil event-data
+	}
+	rv := reflect.ValueOf(v)
+	if rv.Type() == to {
+		return v
+	}
+	return rv.Convert(to).Interface()
+}
+
+// castable reports whether a value of type from can be cast to type to using `type(value)`.
+func castable(from, to reflect.Type) bool {
+	numeric := func(k reflect.Kind) bool {
+		return isInt(k) || isFloat(k)
+	}
+	switch {
+	case from == nil:
+		return false
+	case from == to, to.Kind() == reflect.String:
+		return true
+	case numeric(from.Kind()) && numeric(to.Kind()):
+		return true
+	case from.Kind() == reflect.String:
+		return numeric(to.Kind()) || to.Kind() == reflect.Bool
+	}
+	return false
+}
+
+func cast(v any, to reflect.Type) (any, error) {
+	rv := reflect.ValueOf(v)
+	if rv.Type() == to {
+		return v, nil
+	}
+	if to.Kind() == reflect.String {
+		if rv.Kind() == reflect.String {
+			return rv.Convert(to).Interface(), nil
+		}
+		return reflect.ValueOf(fmt.Sprint(v)).Convert(to).Interface(), nil
+	}
+	if rv.Kind() != reflect.String {
+		return rv.Convert(to).Interface(), nil
+	}
+	var parsed any
+	var err error
+	switch s := rv.String(); {
+	case to.Kind() == reflect.Bool:
+		parsed, err = strconv.ParseBool(s)
+	case isInt(to.Kind()):
+		parsed, err = strconv.ParseInt(s, 0, 64)
+	default:
+		parsed, err = strconv.ParseFloat(s, 64)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cannot convert %q to %v: %w", rv.String(), to, err)
+	}
+	return reflect.ValueOf(parsed).Convert(to).Interface(), nil
+}
+
+// CastValue converts a value to another type, written as `type(value)`.
+type CastValue struct {
+	Type  string
+	Value Value
+
+	custom *TypeSpec // set if Type is a custom type
+}
+
+func (v *CastValue) EvalValue(ctx map[string]Value) (any, error) {
+	if v.custom != nil {
+		eval, err := v.Value.EvalValue(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if s, ok := eval.(string); ok {
+			return v.custom.Parse(s)
+		}
+		return eval, nil
+	}
+	typ, ok := typeNames[v.Type]
+	if !ok {
+		return nil, fmt.Errorf("unknown type %q", v.Type)
+	}
+	eval, err := v.Value.EvalValue(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return cast(eval, typ)
+}
+
+func (v *CastValue) EvalType(ctx map[string]Value) (reflect.Type, error) {
+	if v.custom != nil {
+		from, err := v.Value.EvalType(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if from != v.custom.Type && (from == nil || from.Kind() != reflect.String) {
+			return nil, fmt.Errorf("cannot convert %s to %s", typeString(from), v.Type)
+		}
+		return v.custom.Type, nil
+	}
+	typ, ok := typeNames[v.Type]
+	if !ok {
+		return nil, fmt.Errorf("unknown type %q", v.Type)
+	}
+	from, err := v.Value.EvalType(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !castable(from, typ) {
+		return nil, fmt.Errorf("cannot convert %s to %s", typeString(from), v.Type)
+	}
+	return typ, nil
+}
@@ -0,0 +1,50 @@
+package mova
+
+import (
+	"strings"
+	"sync"
+	"testing"
+)
+
+// TestCompileShared checks that a parsed file compiles concurrently with different constants, and
+// that each machine keeps the arguments folded for it.
+func TestCompileShared(t *testing.T) {
+	var reg Registry
+	NewTrigger[bindEvent](&reg, "press")
+	var mu sync.Mutex
+	got := make(map[int]bool)
+	NewAction(&reg, "num", []string{"n"}, func(n int) {
+		mu.Lock()
+		got[n] = true
+		mu.Unlock()
+	})
+	src := `state a(p: int) { var v: int; on press -> set v = n, num(n=n), move a(p=n); };`
+	f, err := Parse("test.mova", strings.NewReader(src), &reg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var wg sync.WaitGroup
+	for i := range 8 {
+		wg.Go(func() {
+			cm, err := f.Compile(&reg, map[string]any{"n": i * 10})
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			m, err := cm.New()
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if err := m.Emit("press", bindEvent{}); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+	wg.Wait()
+	for i := range 8 {
+		if !got[i*10] {
+			t.Errorf("machine %d did not call num(n=%d), got %v", i, i*10, got)
+		}
+	}
+}
@@ -0,0 +1,172 @@
+package mova
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Trace is a recorded sequence of events of one instance, see ReadTraces and Conform.
+type Trace struct {
+	Name   string // used in the report, e.g. the file name
+	Events []TraceEvent
+	Expect string // state the instance is expected to end in, not checked if empty
+}
+
+// TraceEvent is an event of a trace. State is the state the instance was in when the event was
+// recorded, not checked if empty.
+type TraceEvent struct {
+	Line  int
+	Name  string
+	Data  json.RawMessage
+	State string
+}
+
+// traceLine is a line of a trace, either an event as read by adapters.ReadJSONLines or a
+// JournalEntry written by JSONJournal.
+type traceLine struct {
+	Kind    JournalKind     `json:"kind"`
+	Machine string          `json:"machine"`
+	Event   string          `json:"event"`
+	Data    json.RawMessage `json:"data"`
+	State   string          `json:"state"`
+	To      string          `json:"to"`
+	Expect  string          `json:"expect"`
+}
+
+// ReadTraces reads traces from JSON lines. A line is either an event like {"event": "A",
+// "data": {...}}, or an entry of a journal written by JSONJournal. Of journals, events are
+// replayed from the state they were recorded in and the instance is expected to end in the state
+// of the last transition, other entries are skipped. A line {"expect": "done"} sets the expected
+// final state. Lines of different machines in a journal are split into one trace per machine,
+// named name#machine.
+func ReadTraces(name string, r io.Reader) ([]*Trace, error) {
+	var traces []*Trace
+	byMachine := make(map[string]*Trace)
+	scan := bufio.NewScanner(r)
+	scan.Buffer(nil, 1<<20)
+	for linenr := 1; scan.Scan(); linenr++ {
+		text := strings.TrimSpace(scan.Text())
+		if text == "" {
+			continue
+		}
+		var line traceLine
+		if err := json.Unmarshal([]byte(text), &line); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", name, linenr, err)
+		}
+		trace, ok := byMachine[line.Machine]
+		if !ok {
+			trace = &Trace{Name: name}
+			if line.Machine != "" {
+				trace.Name += "#" + line.Machine
+			}
+			byMachine[line.Machine] = trace
+			traces = append(traces, trace)
+		}
+		switch {
+		case line.Expect != "":
+			trace.Expect = line.Expect
+		case line.Kind == JournalTransition:
+			trace.Expect = line.To
+		case line.Kind == "" || line.Kind == JournalEvent:
+			if line.Event == "" {
+				return nil, fmt.Errorf("%s:%d: missing event", name, linenr)
+			}
+			trace.Events = append(trace.Events, TraceEvent{linenr, line.Event, line.Data, line.State})
+		}
+	}
+	if err := scan.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	return traces, nil
+}
+
+type ConformanceOptions struct {
+	Options     []InstanceOption // passed to New for every trace
+	Setup       func()           // called before every trace, e.g. to reset state shared with actions
+	AllowErrors bool             // do not report events failing with an error other than io.EOF
+}
+
+// Divergence is a point where a machine did not behave as recorded in a trace.
+type Divergence struct {
+	Trace string
+	Line  int    // line of the event in the trace, 0 for the final state
+	Event string // empty for the final state
+	State string // state of the instance
+	Err   error
+}
+
+func (d Divergence) String() string {
+	if d.Line == 0 {
+		return fmt.Sprintf("%s: in state %s: %v", d.Trace, d.State, d.Err)
+	}
+	return fmt.Sprintf("%s:%d: event %s in state %s: %v", d.Trace, d.Line, d.Event, d.State, d.Err)
+}
+
+// ConformanceReport is the result of Conform.
+type ConformanceReport struct {
+	Traces      int
+	Events      int
+	Divergences []Divergence
+}
+
+// OK reports whether the machine followed every trace.
+func (r *ConformanceReport) OK() bool {
+	return len(r.Divergences) == 0
+}
+
+func (r *ConformanceReport) String() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%d traces, %d events, %d divergences", r.Traces, r.Events, len(r.Divergences))
+	for _, d := range r.Divergences {
+		sb.WriteString("\n\t" + d.String())
+	}
+	return sb.String()
+}
+
+// Conform replays every trace on a new instance and reports where the machine diverges: events
+// which are unhandled or fail, events recorded in another state than the instance is in, and
+// instances not ending in the expected state. A trace continues after a divergence.
+func (cm *CompiledMachine) Conform(opts ConformanceOptions, traces ...*Trace) *ConformanceReport {
+	report := &ConformanceReport{Traces: len(traces)}
+	for _, trace := range traces {
+		if opts.Setup != nil {
+			opts.Setup()
+		}
+		diverge := func(ev TraceEvent, state string, err error) {
+			report.Divergences = append(report.Divergences, Divergence{trace.Name, ev.Line, ev.Name, state, err})
+		}
+		m, err := cm.New(opts.Options...)
+		if err != nil {
+			diverge(TraceEvent{}, m.Current(), err)
+			continue
+		}
+		for _, ev := range trace.Events {
+			report.Events++
+			state := m.Current()
+			if ev.State != "" && ev.State != state {
+				diverge(ev, state, fmt.Errorf("recorded in state %s", ev.State))
+			}
+			v, err := cm.reg.Decode(ev.Name, ev.Data)
+			if err != nil {
+				diverge(ev, state, err)
+				continue
+			}
+			err = m.Emit(ev.Name, v)
+			switch {
+			case errors.Is(err, io.EOF):
+				diverge(ev, state, errors.New("unhandled event"))
+			case err != nil && !opts.AllowErrors:
+				diverge(ev, state, err)
+			}
+		}
+		m.async.Wait()
+		if trace.Expect != "" && m.Current() != trace.Expect {
+			diverge(TraceEvent{}, m.Current(), fmt.Errorf("expected to end in state %s", trace.Expect))
+		}
+	}
+	return report
+}
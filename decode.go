@@ -0,0 +1,129 @@
+package mova
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"reflect"
+	"slices"
+	"strings"
+)
+
+// FieldError is an invalid event-data value, see DecodeError.
+type FieldError struct {
+	Path string // event-data name, followed by nested fields, e.g. address.zip
+	Err  error
+}
+
+func (e FieldError) Error() string {
+	return fmt.Sprintf("%s: %v", e.Path, e.Err)
+}
+
+func (e FieldError) Unwrap() error {
+	return e.Err
+}
+
+// DecodeError lists every invalid event-data value of an event, sorted by path.
+type DecodeError struct {
+	Event  string
+	Fields []FieldError
+}
+
+func (e *DecodeError) Error() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "invalid event-data for event %s: ", e.Event)
+	for i, f := range e.Fields {
+		if i > 0 {
+			sb.WriteString("; ")
+		}
+		sb.WriteString(f.Error())
+	}
+	return sb.String()
+}
+
+// fieldError describes an error of json.Unmarshal on the event-data key.
+func fieldError(key string, err error) FieldError {
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) {
+		if typeErr.Field != "" {
+			key += "." + typeErr.Field
+		}
+		return FieldError{key, fmt.Errorf("expected %s, got %s", typeErr.Type, typeErr.Value)}
+	}
+	return FieldError{key, err}
+}
+
+// Decode creates the event-data of the event name from a JSON object.
+// Keys are matched against the event-data names as used in machine files.
+// Invalid values are reported together as *DecodeError.
+func (r *Registry) Decode(name string, data []byte) (any, error) {
+	typ, ok := r.trigger(name)
+	if !ok {
+		return nil, fmt.Errorf("unspecified event %q", name)
+	}
+	out := reflect.New(dataType(typ)).Elem()
+	result := func() any {
+		if typ.Kind() == reflect.Pointer {
+			return out.Addr().Interface()
+		}
+		return out.Interface()
+	}
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 || bytes.Equal(data, []byte("null")) {
+		return result(), nil
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, fmt.Errorf("invalid event-data for event %s: %w", name, err)
+	}
+	derr := &DecodeError{Event: name}
+	for _, key := range slices.Sorted(maps.Keys(fields)) {
+		i := getTypeField(out.Type(), key)
+		if i == -1 || !out.Type().Field(i).IsExported() {
+			derr.Fields = append(derr.Fields, FieldError{key, errors.New("unspecified event-data")})
+			continue
+		}
+		if err := json.Unmarshal(fields[key], out.Field(i).Addr().Interface()); err != nil {
+			derr.Fields = append(derr.Fields, fieldError(key, err))
+		}
+	}
+	if len(derr.Fields) > 0 {
+		return nil, derr
+	}
+	return result(), nil
+}
+
+// DecodeBytes creates the event-data of the event name from raw bytes, using the decoder registered
+// with NewTriggerDecoder. Events without decoder are decoded from a JSON object, as by Decode.
+func (r *Registry) DecodeBytes(name string, data []byte) (any, error) {
+	decode, ok := r.decoders[name]
+	if !ok {
+		return r.Decode(name, data)
+	}
+	v, err := decode(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid event-data for event %s: %w", name, err)
+	}
+	return v, nil
+}
+
+// EmitBytes decodes the event-data of event name using Registry.DecodeBytes and emits it.
+func (m *StateMachine) EmitBytes(ctx context.Context, name string, data []byte) error {
+	v, err := m.reg.DecodeBytes(name, data)
+	if err != nil {
+		return err
+	}
+	return m.EmitContext(ctx, name, v)
+}
+
+// EmitJSON decodes the event-data of event name from a JSON object using Registry.Decode and emits it.
+func (m *StateMachine) EmitJSON(name string, data []byte) error {
+	v, err := m.reg.Decode(name, data)
+	if err != nil {
+		return err
+	}
+	return m.Emit(name, v)
+}
@@ -0,0 +1,94 @@
+package mova
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+var ErrDuplicateEvent = errors.New("duplicate event")
+
+// DedupStore remembers the IDs of events handled by instances, see WithDedup.
+// Implementations backed by a shared database deduplicate across processes.
+type DedupStore interface {
+	// Mark records the event id for instance until the given time. It reports false if the id
+	// is already recorded and not yet expired.
+	Mark(ctx context.Context, instance, id string, until time.Time) (bool, error)
+	// Forget removes the record of the event id, so a redelivery is handled again.
+	Forget(ctx context.Context, instance, id string) error
+}
+
+// WithDedup drops events passed to EmitEvent whose ID was seen in the last window,
+// as redelivered by message brokers with at-least-once delivery. Events failing with an error
+// other than io.EOF are forgotten, so their redelivery is handled again.
+func WithDedup(store DedupStore, window time.Duration) InstanceOption {
+	return func(m *StateMachine) {
+		m.dedup = store
+		m.dedupWindow = window
+	}
+}
+
+// EmitEvent is EmitContext for ev. If the machine deduplicates events and ev has an ID which
+// was seen before, it returns ErrDuplicateEvent without handling ev.
+func (m *StateMachine) EmitEvent(ctx context.Context, ev Event) error {
+	if m.dedup == nil || ev.ID == "" {
+		return m.EmitContext(ctx, ev.Name, ev.Data)
+	}
+	first, err := m.dedup.Mark(ctx, m.ID, ev.ID, m.clock.Now().Add(m.dedupWindow))
+	if err != nil {
+		return fmt.Errorf("unable to deduplicate event %s: %w", ev.Name, err)
+	}
+	if !first {
+		m.record(JournalEntry{Kind: JournalDuplicate, State: m.Current(), Event: ev.Name})
+		return fmt.Errorf("%w: %s %q", ErrDuplicateEvent, ev.Name, ev.ID)
+	}
+	err = m.EmitContext(ctx, ev.Name, ev.Data)
+	if err != nil && !errors.Is(err, io.EOF) {
+		if ferr := m.dedup.Forget(ctx, m.ID, ev.ID); ferr != nil {
+			err = errors.Join(err, ferr)
+		}
+	}
+	return err
+}
+
+// MemoryDedup is a DedupStore in memory. Its zero value uses the system clock to expire records.
+type MemoryDedup struct {
+	Clock Clock // the system clock if nil
+
+	mu   sync.Mutex
+	seen map[[2]string]time.Time
+}
+
+func (md *MemoryDedup) Mark(ctx context.Context, instance, id string, until time.Time) (bool, error) {
+	md.mu.Lock()
+	defer md.mu.Unlock()
+	clock := md.Clock
+	if clock == nil {
+		clock = realClock{}
+	}
+	now := clock.Now()
+	for key, exp := range md.seen {
+		if !exp.After(now) {
+			delete(md.seen, key)
+		}
+	}
+	key := [2]string{instance, id}
+	if _, ok := md.seen[key]; ok {
+		return false, nil
+	}
+	if md.seen == nil {
+		md.seen = make(map[[2]string]time.Time)
+	}
+	md.seen[key] = until
+	return true, nil
+}
+
+func (md *MemoryDedup) Forget(ctx context.Context, instance, id string) error {
+	md.mu.Lock()
+	defer md.mu.Unlock()
+	delete(md.seen, [2]string{instance, id})
+	return nil
+}
@@ -0,0 +1,52 @@
+package mova
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Diagnostic is a problem in a machine file, as reported by Parse or BuildMachine,
+// in a form suitable for editors and CI tools. It encodes to JSON.
+type Diagnostic struct {
+	File     string `json:"file"`
+	Range    Span   `json:"range"`    // zero if the location is unknown
+	Severity string `json:"severity"` // error or warning
+	Code     string `json:"code"`     // syntax, missing-action, missing-trigger, compile, deprecated, ambiguous, unreachable or unbound
+	Message  string `json:"message"`  // without location
+}
+
+// Diagnostics splits an error of Parse or BuildMachine into diagnostics, one per error joined
+// into err and per use of an unspecified action or trigger. Other errors give a single
+// diagnostic without location.
+func Diagnostics(err error) []Diagnostic {
+	if err == nil {
+		return nil
+	}
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		var out []Diagnostic
+		for _, err := range joined.Unwrap() {
+			out = append(out, Diagnostics(err)...)
+		}
+		return out
+	}
+	var perr *ParseError
+	var merr *MissingError
+	var cerr *CompileError
+	switch {
+	case errors.As(err, &perr):
+		start := Position{Offset: perr.Pos, Line: perr.Line, Column: perr.Offset}
+		end := Position{Offset: perr.Pos + perr.Length, Line: perr.Line, Column: perr.Offset + perr.Length}
+		return []Diagnostic{{perr.Filename, Span{start, end}, "error", "syntax", perr.message()}}
+	case errors.As(err, &merr):
+		var out []Diagnostic
+		for _, m := range merr.Missing {
+			for _, span := range m.Uses {
+				out = append(out, Diagnostic{merr.Filename, span, "error", "missing-" + m.Kind, fmt.Sprintf("unspecified %s %q", m.Kind, m.Name)})
+			}
+		}
+		return out
+	case errors.As(err, &cerr):
+		return []Diagnostic{{cerr.Filename, cerr.Span, "error", "compile", cerr.Err.Error()}}
+	}
+	return []Diagnostic{{Severity: "error", Code: "compile", Message: err.Error()}}
+}
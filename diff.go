@@ -0,0 +1,211 @@
+package mova
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+)
+
+type ChangeKind string
+
+const (
+	ConstantAdded     ChangeKind = "constant added"
+	ConstantRemoved   ChangeKind = "constant removed"
+	ConstantChanged   ChangeKind = "constant changed"
+	VariableAdded     ChangeKind = "variable added"
+	VariableRemoved   ChangeKind = "variable removed"
+	VariableChanged   ChangeKind = "variable changed"
+	InitialChanged    ChangeKind = "initial state changed"
+	StateAdded        ChangeKind = "state added"
+	StateRemoved      ChangeKind = "state removed"
+	ParamsChanged     ChangeKind = "parameters changed"
+	FinalChanged      ChangeKind = "final changed"
+	TaskChanged       ChangeKind = "awaited task changed"
+	VarsChanged       ChangeKind = "state variables changed"
+	IgnoreChanged     ChangeKind = "ignored events changed"
+	InitChanged       ChangeKind = "init actions changed"
+	CompensateChanged ChangeKind = "compensate actions changed"
+	TriggerAdded      ChangeKind = "trigger added"
+	TriggerRemoved    ChangeKind = "trigger removed"
+	TriggerChanged    ChangeKind = "trigger actions changed"
+	TriggerReordered  ChangeKind = "triggers reordered"
+)
+
+// Change is a difference between two machines, see Diff. Old and New hold the affected source,
+// empty if not applicable.
+type Change struct {
+	Kind    ChangeKind
+	Name    string // constant, variable or state
+	Trigger string // conditions of the trigger, e.g. `A(event=press)`
+	Old     string
+	New     string
+}
+
+func (c Change) String() string {
+	s := string(c.Kind) + " " + c.Name
+	if c.Trigger != "" {
+		s += " on " + c.Trigger
+	}
+	orNone := func(s string) string {
+		if s == "" {
+			return "(none)"
+		}
+		return s
+	}
+	switch c.Kind {
+	case ConstantAdded, VariableAdded, StateAdded, TriggerAdded:
+		if c.New != "" {
+			s += ": " + c.New
+		}
+	case ConstantRemoved, VariableRemoved, StateRemoved, TriggerRemoved:
+		if c.Old != "" {
+			s += ": " + c.Old
+		}
+	case TriggerReordered:
+	default:
+		s += fmt.Sprintf(": %s -> %s", orNone(c.Old), orNone(c.New))
+	}
+	return s
+}
+
+// Diff compares the sources of two machines and lists what changed from a to b. Constants passed to
+// BuildMachine are not compared. Triggers are matched by their conditions.
+func Diff(a, b *CompiledMachine) []Change {
+	var changes []Change
+	consts := func(cm *CompiledMachine) map[string]string {
+		out := make(map[string]string)
+		for _, entry := range cm.file.Entries {
+			if set, ok := entry.(*SetStmt); ok {
+				out[set.Key] = formatValue(set.Value)
+			}
+		}
+		return out
+	}
+	vars := func(cm *CompiledMachine) map[string]string {
+		out := make(map[string]string)
+		for _, entry := range cm.file.Entries {
+			if decl, ok := entry.(*VarDecl); ok {
+				out[decl.Name] = decl.String()
+			}
+		}
+		return out
+	}
+	states := func(cm *CompiledMachine) map[string]*State {
+		out := make(map[string]*State)
+		for _, entry := range cm.file.Entries {
+			if st, ok := entry.(*State); ok {
+				out[st.Name] = st
+			}
+		}
+		return out
+	}
+
+	changes = append(changes, diffNamed(consts(a), consts(b), ConstantAdded, ConstantRemoved, ConstantChanged)...)
+	changes = append(changes, diffNamed(vars(a), vars(b), VariableAdded, VariableRemoved, VariableChanged)...)
+
+	if a.order[0] != b.order[0] {
+		changes = append(changes, Change{Kind: InitialChanged, Old: a.order[0], New: b.order[0]})
+	}
+	sa, sb := states(a), states(b)
+	for _, name := range slices.Sorted(maps.Keys(sa)) {
+		if _, ok := sb[name]; !ok {
+			changes = append(changes, Change{Kind: StateRemoved, Name: name})
+		}
+	}
+	for _, name := range slices.Sorted(maps.Keys(sb)) {
+		old, ok := sa[name]
+		if !ok {
+			changes = append(changes, Change{Kind: StateAdded, Name: name})
+			continue
+		}
+		changes = append(changes, diffState(old, sb[name])...)
+	}
+	return changes
+}
+
+// diffNamed compares definitions by name, such as constants, and reports them with the given kinds.
+func diffNamed(a, b map[string]string, added, removed, changed ChangeKind) []Change {
+	var changes []Change
+	for _, name := range slices.Sorted(maps.Keys(a)) {
+		if nv, ok := b[name]; !ok {
+			changes = append(changes, Change{Kind: removed, Name: name, Old: a[name]})
+		} else if nv != a[name] {
+			changes = append(changes, Change{Kind: changed, Name: name, Old: a[name], New: nv})
+		}
+	}
+	for _, name := range slices.Sorted(maps.Keys(b)) {
+		if _, ok := a[name]; !ok {
+			changes = append(changes, Change{Kind: added, Name: name, New: b[name]})
+		}
+	}
+	return changes
+}
+
+func diffState(a, b *State) []Change {
+	var changes []Change
+	if pa, pb := formatParams(a.Params), formatParams(b.Params); pa != pb {
+		changes = append(changes, Change{Kind: ParamsChanged, Name: a.Name, Old: pa, New: pb})
+	}
+	if a.Final != b.Final {
+		changes = append(changes, Change{Kind: FinalChanged, Name: a.Name, Old: fmt.Sprint(a.Final), New: fmt.Sprint(b.Final)})
+	}
+	if a.Task != b.Task {
+		changes = append(changes, Change{Kind: TaskChanged, Name: a.Name, Old: a.Task, New: b.Task})
+	}
+	decls := func(st *State) string {
+		var parts []string
+		for _, decl := range st.Vars {
+			parts = append(parts, decl.String())
+		}
+		return strings.Join(parts, " ")
+	}
+	if va, vb := decls(a), decls(b); va != vb {
+		changes = append(changes, Change{Kind: VarsChanged, Name: a.Name, Old: va, New: vb})
+	}
+	if ia, ib := strings.Join(a.Ignore, ", "), strings.Join(b.Ignore, ", "); ia != ib {
+		changes = append(changes, Change{Kind: IgnoreChanged, Name: a.Name, Old: ia, New: ib})
+	}
+	if ia, ib := formatStatements(a.Init), formatStatements(b.Init); ia != ib {
+		changes = append(changes, Change{Kind: InitChanged, Name: a.Name, Old: ia, New: ib})
+	}
+	if ca, cb := formatCompensate(a.Compensate), formatCompensate(b.Compensate); ca != cb {
+		changes = append(changes, Change{Kind: CompensateChanged, Name: a.Name, Old: ca, New: cb})
+	}
+
+	triggers := func(st *State) (order []string, actions map[string]string) {
+		actions = make(map[string]string)
+		for _, trg := range st.Triggers {
+			conds := formatConds(trg.Cond)
+			order = append(order, conds)
+			actions[conds] = formatStatements(trg.Actions)
+		}
+		return
+	}
+	oa, ta := triggers(a)
+	ob, tb := triggers(b)
+	for _, conds := range oa {
+		if _, ok := tb[conds]; !ok {
+			changes = append(changes, Change{Kind: TriggerRemoved, Name: a.Name, Trigger: conds, Old: ta[conds]})
+		}
+	}
+	for _, conds := range ob {
+		old, ok := ta[conds]
+		if !ok {
+			changes = append(changes, Change{Kind: TriggerAdded, Name: a.Name, Trigger: conds, New: tb[conds]})
+		} else if old != tb[conds] {
+			changes = append(changes, Change{Kind: TriggerChanged, Name: a.Name, Trigger: conds, Old: old, New: tb[conds]})
+		}
+	}
+	// the first matching trigger wins, so the order of common triggers matters
+	common := func(order []string, other map[string]string) []string {
+		return slices.DeleteFunc(slices.Clone(order), func(conds string) bool {
+			_, ok := other[conds]
+			return !ok
+		})
+	}
+	if !slices.Equal(common(oa, tb), common(ob, ta)) {
+		changes = append(changes, Change{Kind: TriggerReordered, Name: a.Name})
+	}
+	return changes
+}
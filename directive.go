@@ -0,0 +1,100 @@
+package mova
+
+import (
+	"errors"
+	"strings"
+)
+
+// WithFeatures enables features for `@if FEATURE`, `@if !FEATURE`, `@else` and `@endif` lines
+// in the source, all other features are disabled.
+func WithFeatures(features ...string) BuildOption {
+	return func(c *buildConfig) {
+		if c.features == nil {
+			c.features = make(map[string]bool)
+		}
+		for _, f := range features {
+			c.features[f] = true
+		}
+	}
+}
+
+type branch struct {
+	active   bool // tokens are kept
+	taken    bool // the condition of @if matched
+	parent   bool // the enclosing branch is active
+	seenElse bool
+	span     Span // of the @if
+}
+
+// skipDirectives evaluates the directives at the current token and skips the tokens of disabled
+// branches. The end of the previous token is kept, so spans do not cover skipped lines.
+func (p *parser) skipDirectives() {
+	prevEnd := p.prevEnd
+	for p.Token != "EOF" && p.Token != "ERROR" {
+		if p.Token == "directive" {
+			p.directive()
+		} else if p.active() {
+			break
+		}
+		p.lexer.Next()
+	}
+	p.prevEnd = prevEnd
+}
+
+func (p *parser) active() bool {
+	return len(p.branches) == 0 || p.branches[len(p.branches)-1].active
+}
+
+// directive evaluates the directive at the current token.
+func (p *parser) directive() {
+	start := p.position()
+	end := start
+	end.Offset += p.Length
+	end.Column += p.Length
+	span := Span{start, end}
+	line, _, _ := strings.Cut(p.Value, "#")
+	fields := strings.Fields(line)
+	switch fields[0] {
+	case "@if":
+		if len(fields) != 2 {
+			p.directiveError(span, "expected @if FEATURE")
+			return
+		}
+		feature, negate := strings.CutPrefix(fields[1], "!")
+		if p.tested == nil {
+			p.tested = make(map[string]bool)
+		}
+		p.tested[feature] = p.features[feature]
+		taken := p.features[feature] != negate
+		parent := p.active()
+		p.branches = append(p.branches, branch{active: parent && taken, taken: taken, parent: parent, span: span})
+	case "@else":
+		if len(p.branches) == 0 || p.branches[len(p.branches)-1].seenElse {
+			p.directiveError(span, "@else without @if")
+			return
+		}
+		top := &p.branches[len(p.branches)-1]
+		top.active = top.parent && !top.taken
+		top.seenElse = true
+	case "@endif":
+		if len(p.branches) == 0 {
+			p.directiveError(span, "@endif without @if")
+			return
+		}
+		p.branches = p.branches[:len(p.branches)-1]
+	}
+}
+
+func (p *parser) directiveError(span Span, msg string) {
+	p.errs = append(p.errs, &CompileError{p.filename, span, errors.New(msg)})
+}
+
+// selects reports whether the directives of f selected the same branches as features would.
+func (f *File) selects(features map[string]bool) bool {
+	for feature, enabled := range f.features {
+		if features[feature] != enabled {
+			return false
+		}
+	}
+	return true
+}
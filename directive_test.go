@@ -0,0 +1,125 @@
+package mova
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDirectives(t *testing.T) {
+	var reg Registry
+	NewTrigger[bindEvent](&reg, "press")
+	var got string
+	NewAction(&reg, "say", []string{"s"}, func(s string) { got = s })
+	src := "x = \"basic\";\n" +
+		"@if pro\n" +
+		"y = \"pro\";\n" +
+		"@else\n" +
+		"y = \"basic\";\n" +
+		"@endif\n" +
+		"raw = `\n@endif\n`;\n" +
+		"long = \"\"\"\n@else\n\"\"\";\n" +
+		"state a {\n" +
+		"@if !pro\n" +
+		"    on press -> say(s=x);\n" +
+		"@else\n" +
+		"    on press -> say(s=y);\n" +
+		"@endif\n" +
+		"};\n"
+	tests := []struct {
+		features []string
+		want     string
+	}{
+		{nil, "basic"},
+		{[]string{"pro"}, "pro"},
+		{[]string{"other"}, "basic"},
+	}
+	for _, tt := range tests {
+		t.Run(strings.Join(tt.features, ","), func(t *testing.T) {
+			check := func(cm *CompiledMachine, err error) {
+				t.Helper()
+				if err != nil {
+					t.Fatal(err)
+				}
+				m, _ := cm.New()
+				got = ""
+				if err := m.Emit("press", bindEvent{}); err != nil {
+					t.Fatal(err)
+				}
+				if got != tt.want {
+					t.Fatalf("got %q, want %q", got, tt.want)
+				}
+			}
+			check(BuildMachine("test.mova", strings.NewReader(src), &reg, nil, WithFeatures(tt.features...)))
+
+			f, err := Parse("test.mova", strings.NewReader(src), &reg, WithFeatures(tt.features...))
+			if err != nil {
+				t.Fatal(err)
+			}
+			check(f.Compile(&reg, nil))
+
+			// parsed without features, compiled with them
+			f, err = Parse("test.mova", strings.NewReader(src), &reg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			check(f.Compile(&reg, nil, WithFeatures(tt.features...)))
+		})
+	}
+}
+
+// TestDirectiveSpans checks that skipped lines do not shift positions or widen spans.
+func TestDirectiveSpans(t *testing.T) {
+	var reg Registry
+	src := "a = 1;\n@if pro\nb = 2;\n@endif\nc = 3;\n"
+	f, err := Parse("test.mova", strings.NewReader(src), &reg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(f.Entries) != 2 {
+		t.Fatalf("got %d entries, want 2", len(f.Entries))
+	}
+	a, c := f.Entries[0].(*SetStmt), f.Entries[1].(*SetStmt)
+	if a.Span.End.Line != 1 || a.Span.End.Offset != 6 {
+		t.Errorf("a ends at %+v, want line 1 offset 6", a.Span.End)
+	}
+	if c.Key != "c" || c.Span.Start.Line != 5 || c.Span.Start.Offset != strings.Index(src, "c =") {
+		t.Errorf("c starts at %+v, want line 5", c.Span.Start)
+	}
+}
+
+// TestDirectiveCustomToken checks that directives are not taken by custom tokens of the same length.
+func TestDirectiveCustomToken(t *testing.T) {
+	var reg Registry
+	NewToken(&reg, "tag", `@[a-z]+`)
+	f, err := Parse("test.mova", strings.NewReader("@if pro\na = 1;\n@else\na = 2;\n@endif\n"), &reg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(f.Entries) != 1 || f.Entries[0].(*SetStmt).Span.Start.Line != 4 {
+		t.Fatalf("got %s, want the @else branch", f)
+	}
+}
+
+func TestDirectiveErrors(t *testing.T) {
+	var reg Registry
+	tests := []struct {
+		src, err string
+	}{
+		{"@if\n", "test.mova:1:0: expected @if FEATURE"},
+		{"@if a b\n", "expected @if FEATURE"},
+		{"a = 1;\n@else\n", "test.mova:2:0: @else without @if"},
+		{"@if a\n@else\n@else\n@endif\n", "test.mova:3:0: @else without @if"},
+		{"@endif\n", "@endif without @if"},
+		{"a = 1;\n@if a\nb = 2;\n", "test.mova:2:0: missing @endif"},
+		{"@if a\n@if b\n@endif\n", "test.mova:1:0: missing @endif"},
+		{"a = \"\"\"\n@if a\n\"\"\";\n@endif\n", "test.mova:4:0: @endif without @if"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.src, func(t *testing.T) {
+			_, err := Parse("test.mova", strings.NewReader(tt.src), &reg)
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Fatalf("got error %v, want %q", err, tt.err)
+			}
+		})
+	}
+}
@@ -0,0 +1,48 @@
+package mova
+
+import (
+	"maps"
+	"time"
+)
+
+// StateTimeHook is called when the machine leaves a state with the time it spent in it.
+type StateTimeHook func(m *StateMachine, state string, d time.Duration)
+
+// WithStateTimeHook calls hook whenever the machine leaves a state.
+func WithStateTimeHook(hook StateTimeHook) InstanceOption {
+	return func(m *StateMachine) {
+		m.timeHooks = append(m.timeHooks, hook)
+	}
+}
+
+// entered records the time of a transition, it returns the time spent in the previous state.
+func (m *StateMachine) entered(from string) time.Duration {
+	now := m.clock.Now()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var d time.Duration
+	if from != "" {
+		d = now.Sub(m.since)
+		if m.durations == nil {
+			m.durations = make(map[string]time.Duration)
+		}
+		m.durations[from] += d
+	}
+	m.since = now
+	return d
+}
+
+// StateDurations returns the total time the machine spent in each state, including the time
+// in the current state so far.
+func (m *StateMachine) StateDurations() map[string]time.Duration {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := maps.Clone(m.durations)
+	if out == nil {
+		out = make(map[string]time.Duration)
+	}
+	if cur := m.current.Load(); cur != nil {
+		out[cur.Name] += m.clock.Now().Sub(m.since)
+	}
+	return out
+}
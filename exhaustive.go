@@ -0,0 +1,80 @@
+package mova
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// WithExhaustive makes BuildMachine reject states which do not handle every event of the
+// registry, e.g. for protocol implementations where an unhandled message is a bug. A state
+// handles an event if a trigger matches it whatever its event-data, or if it ignores the event
+// with `ignore event;`. Final states and the builtin events are exempt. All gaps are returned at
+// once, one *CompileError per state.
+func WithExhaustive() BuildOption {
+	return func(c *buildConfig) {
+		c.exhaustive = true
+	}
+}
+
+// Unhandled returns the events of the registry each state does not handle, by state, see
+// WithExhaustive. Events handled only for some event-data are included, final states are not.
+func (cm *CompiledMachine) Unhandled() map[string][]string {
+	out := make(map[string][]string)
+	events := slices.Sorted(maps.Keys(cm.reg.triggers))
+	for _, name := range cm.order {
+		st := cm.states[name]
+		if st.Final {
+			continue
+		}
+		for _, event := range events {
+			if !st.handles(event) {
+				out[name] = append(out[name], event)
+			}
+		}
+	}
+	return out
+}
+
+// handles reports whether every event named event fires a trigger or is ignored.
+func (st *CompiledState) handles(event string) bool {
+	return slices.Contains(st.ignore, event) || slices.ContainsFunc(st.matches, func(mt match) bool {
+		return mt.cond.TriggerName == event && mt.cond.specificity() == 0
+	})
+}
+
+func (cm *CompiledMachine) exhaustive() error {
+	unhandled := cm.Unhandled()
+	var errs []error
+	for _, name := range cm.order {
+		events := unhandled[name]
+		if len(events) == 0 {
+			continue
+		}
+		var parts []string
+		for _, event := range events {
+			if slices.ContainsFunc(cm.states[name].matches, func(mt match) bool { return mt.cond.TriggerName == event }) {
+				event += " (only for some event-data)"
+			}
+			parts = append(parts, event)
+		}
+		var span Span
+		if st := cm.source(name); st != nil {
+			span = st.Span
+		}
+		errs = append(errs, &CompileError{Filename: cm.file.Filename, Span: span, Err: fmt.Errorf("state %s does not handle %s, add triggers or `ignore` them", name, strings.Join(parts, ", "))})
+	}
+	return errors.Join(errs...)
+}
+
+// source returns the declaration of the named state.
+func (cm *CompiledMachine) source(name string) *State {
+	for _, entry := range cm.file.Entries {
+		if st, ok := entry.(*State); ok && st.Name == name {
+			return st
+		}
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+package mova
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// Parser gives statement parsers access to the token stream.
+// Its methods panic on syntax errors, which are recovered and returned by BuildMachine.
+type Parser struct {
+	p *parser
+}
+
+// StatementParser parses a statement whose keyword has already been consumed.
+type StatementParser func(p *Parser) Statement
+
+// NewStatement registers a statement starting with keyword, which may appear wherever actions are allowed.
+// The keyword takes precedence over actions of the same name.
+func NewStatement(r *Registry, keyword string, parse StatementParser) {
+	if r.statements == nil {
+		r.statements = make(map[string]StatementParser)
+	}
+	r.statements[keyword] = parse
+}
+
+// NewToken adds a token kind to the lexer, matched by pattern before all builtin tokens.
+func NewToken(r *Registry, name, pattern string) {
+	r.tokens = append(r.tokens, rule{name, regexp.MustCompile(`^(` + pattern + `)`)})
+}
+
+// Token returns the kind and text of the current token.
+func (x *Parser) Token() (kind, value string) {
+	return x.p.Token, x.p.Value
+}
+
+// Next advances to the next token.
+func (x *Parser) Next() {
+	x.p.Next()
+}
+
+// Expect consumes a token of the given kind and returns its text.
+func (x *Parser) Expect(kind string) string {
+	return x.p.expect(kind)
+}
+
+// ExpectValue consumes a token with the given text.
+func (x *Parser) ExpectValue(value string) {
+	x.p.expectValue(value)
+}
+
+// ParseValue parses a literal, variable or cast.
+func (x *Parser) ParseValue() Value {
+	return x.p.parseValue()
+}
+
+// ParseArgs parses an optional argument list `(key=value, ...)`.
+func (x *Parser) ParseArgs() map[string]Value {
+	return x.p.parseArgs()
+}
+
+// ParseAction parses a nested action, such as a call or move.
+func (x *Parser) ParseAction() Statement {
+	return x.p.parseAction()
+}
+
+// Fail reports a syntax error at the current token.
+func (x *Parser) Fail(expected ...string) {
+	x.p.errUnexpected(expected...)
+}
+
+// Errorf reports an error at the current line.
+func (x *Parser) Errorf(format string, args ...any) {
+	panic(fmt.Errorf("%s:%d: %s", x.p.filename, x.p.Linenr, fmt.Sprintf(format, args...)))
+}
@@ -0,0 +1,202 @@
+package mova
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+)
+
+// fold evaluates v at compile time if it only depends on constants, so casts are done and
+// constants are looked up once instead of on every execution.
+func fold(v Value, ctx map[string]Value, depth int) (Value, error) {
+	if depth > 100 {
+		return v, nil // cyclic constants, reported when evaluated
+	}
+	switch v := v.(type) {
+	case *ReferenceValue:
+		if c, ok := ctx[v.Ref]; ok {
+			if folded, err := fold(c, ctx, depth+1); err == nil {
+				if _, ok := folded.(*ConstValue); ok {
+					return folded, nil
+				}
+			}
+		}
+	case *CastValue:
+		inner, err := fold(v.Value, ctx, depth+1)
+		if err != nil {
+			return v, err
+		}
+		if _, ok := inner.(*ConstValue); ok {
+			val, err := v.EvalValue(ctx)
+			if err != nil {
+				return v, err
+			}
+			return &ConstValue{val}, nil
+		}
+	case *FuncValue:
+		// functions are pure, so they are evaluated if all arguments are constant
+		folded := &FuncValue{Name: v.Name, Args: make([]Value, len(v.Args)), fn: v.fn}
+		constant := true
+		for i, arg := range v.Args {
+			inner, err := fold(arg, ctx, depth+1)
+			if err != nil {
+				return v, err
+			}
+			_, ok := inner.(*ConstValue)
+			constant = constant && ok
+			folded.Args[i] = inner
+		}
+		if constant {
+			val, err := folded.EvalValue(ctx)
+			if err != nil {
+				return v, err
+			}
+			return &ConstValue{val}, nil
+		}
+		return folded, nil
+	}
+	return v, nil
+}
+
+func foldArgs(args map[string]Value, ctx map[string]Value) (map[string]Value, error) {
+	out := make(map[string]Value, len(args))
+	for _, key := range slices.Sorted(maps.Keys(args)) {
+		folded, err := fold(args[key], ctx, 0)
+		if err != nil {
+			return nil, fmt.Errorf("argument %q: %w", key, err)
+		}
+		out[key] = folded
+	}
+	return out, nil
+}
+
+// subsumes reports whether cond matches every event other matches.
+func (cond Condition) subsumes(other Condition) bool {
+	if cond.TriggerName != other.TriggerName || len(cond.Deferred) > 0 {
+		return false // deferred comparisons may reject events other matches
+	}
+	for key, want := range cond.Value {
+		got, ok := other.Value[key]
+		if !ok {
+			return false
+		}
+		if eq, ok := cond.Equal[key]; ok {
+			if !eq(want, got) {
+				return false
+			}
+		} else if want != got {
+			return false
+		}
+	}
+	return true
+}
+
+// specificity is the number of event-data the condition compares, conditions comparing more
+// event-data are tried first.
+func (cond Condition) specificity() int {
+	return len(cond.Value) + len(cond.Deferred)
+}
+
+// disjoint reports whether no event matches both conditions, because they require different
+// values for the same event-data.
+func (cond Condition) disjoint(other Condition) bool {
+	if cond.TriggerName != other.TriggerName {
+		return true
+	}
+	for key, want := range cond.Value {
+		got, ok := other.Value[key]
+		if !ok {
+			continue
+		}
+		if eq, ok := cond.Equal[key]; ok {
+			if !eq(want, got) {
+				return true
+			}
+		} else if want != got {
+			return true
+		}
+	}
+	return false
+}
+
+// covered reports whether the events matching both conditions are matched by a more specific
+// condition in earlier.
+func (cond Condition) covered(other Condition, earlier []match) bool {
+	if len(cond.Deferred) > 0 || len(other.Deferred) > 0 {
+		return false
+	}
+	both := Condition{TriggerName: cond.TriggerName, Value: maps.Clone(cond.Value), Equal: maps.Clone(cond.Equal)}
+	maps.Copy(both.Value, other.Value)
+	if both.Equal == nil {
+		both.Equal = make(map[string]func(a, b any) bool)
+	}
+	maps.Copy(both.Equal, other.Equal)
+	return slices.ContainsFunc(earlier, func(mt match) bool {
+		return mt.cond.specificity() > cond.specificity() && mt.cond.subsumes(both)
+	})
+}
+
+// match is a condition of a trigger, in the order conditions are tried, see CompiledState.matches.
+type match struct {
+	trigger int
+	index   int // index of the condition in the trigger
+	cond    Condition
+}
+
+// simplify orders the conditions of every state by specificity, so `on press(id=3)` is tried before
+// `on press`, and equally specific conditions in file order. It drops conditions which can never
+// match, because they require different values for the same event-data or a condition tried before
+// matches whenever they do, with a warning, and warns about overlapping conditions of the same specificity. Triggers
+// stay in place, so their indices keep referring to the source.
+func (cm *CompiledMachine) simplify() {
+	for _, name := range cm.order {
+		st := cm.states[name]
+		var order []match
+		for index, trg := range st.Triggers {
+			for condidx, cond := range trg.cond {
+				order = append(order, match{index, condidx, cond})
+			}
+		}
+		slices.SortStableFunc(order, func(a, b match) int {
+			return b.cond.specificity() - a.cond.specificity()
+		})
+		kept := make([][]Condition, len(st.Triggers))
+		st.matches = nil
+		for _, mt := range order {
+			switch {
+			case mt.cond.never:
+				cm.warn(st.Name, mt.trigger, "unreachable", fmt.Sprintf("condition #%d is always false: conflicting values for event-data", mt.index))
+				continue
+			case slices.ContainsFunc(st.matches, func(other match) bool { return other.cond.subsumes(mt.cond) }):
+				cm.warn(st.Name, mt.trigger, "unreachable", fmt.Sprintf("condition #%d is always false: handled by an earlier trigger", mt.index))
+				continue
+			}
+			for _, other := range st.matches {
+				if other.trigger != mt.trigger && other.cond.specificity() == mt.cond.specificity() &&
+					!other.cond.disjoint(mt.cond) && !other.cond.covered(mt.cond, st.matches) {
+					cm.warn(st.Name, mt.trigger, "ambiguous", fmt.Sprintf("condition #%d overlaps with trigger #%d, which is as specific and declared first, so it wins", mt.index, other.trigger))
+					break
+				}
+			}
+			st.matches = append(st.matches, mt)
+			kept[mt.trigger] = append(kept[mt.trigger], mt.cond)
+		}
+		for index := range st.Triggers {
+			trg := &st.Triggers[index]
+			if len(trg.cond) > 0 && len(kept[index]) == 0 {
+				cm.warn(st.Name, index, "unreachable", "trigger never fires")
+			}
+			trg.cond = kept[index]
+		}
+	}
+}
+
+// warn adds a warning about a trigger, see Warnings.
+func (cm *CompiledMachine) warn(state string, index int, code, message string) {
+	var span Span
+	if st := cm.source(state); st != nil && index < len(st.Triggers) {
+		span = st.Triggers[index].Span
+	}
+	d := Diagnostic{cm.file.Filename, span, "warning", code, fmt.Sprintf("in trigger %s#%d: %s", state, index, message)}
+	cm.warnings = append(cm.warnings, d)
+}
@@ -0,0 +1,64 @@
+package mova
+
+import (
+	"bytes"
+	"log"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// TestSimplifyWarnings checks that dropped conditions and event-data are reported by Warnings
+// instead of the log.
+func TestSimplifyWarnings(t *testing.T) {
+	var reg Registry
+	NewTrigger[bindEvent](&reg, "press")
+	NewAction(&reg, "num", []string{"n"}, func(n int) {})
+	tests := []struct {
+		src  string
+		want []string // code and message of each warning
+	}{
+		{`state a { on press(ID=1, ID=2) -> num(n=1); };`, []string{
+			"unreachable: in trigger a#0: condition #0 is always false: conflicting values for event-data",
+			"unreachable: in trigger a#0: trigger never fires",
+		}},
+		{`state a { on press -> num(n=1); on press(ID=1), press -> num(n=2); };`, []string{
+			"unbound: in trigger a#1: dropping event-data \"ID\": not mentioned in condition #1",
+			"unreachable: in trigger a#1: condition #1 is always false: handled by an earlier trigger",
+		}},
+		{`state a { on press -> num(n=1); on press -> num(n=2); };`, []string{
+			"unreachable: in trigger a#1: condition #0 is always false: handled by an earlier trigger",
+			"unreachable: in trigger a#1: trigger never fires",
+		}},
+		{`state a { on press(ID), press(P) -> num(n=1); };`, []string{
+			"unbound: in trigger a#0: dropping event-data \"ID\": not mentioned in condition #1",
+			"unbound: in trigger a#0: dropping event-data \"P\": not mentioned in condition #0",
+			"unreachable: in trigger a#0: condition #1 is always false: handled by an earlier trigger",
+		}},
+		{`state a { on press(ID=1) -> num(n=1); on press -> num(n=2); };`, nil},
+	}
+	var logged bytes.Buffer
+	defer log.SetOutput(log.Writer())
+	log.SetOutput(&logged)
+	for _, tt := range tests {
+		t.Run(tt.src, func(t *testing.T) {
+			cm, err := BuildMachine("test.mova", strings.NewReader(tt.src), &reg, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, d := range cm.Warnings() {
+				if d.Severity != "warning" || d.Range.Start.Line != 1 {
+					t.Errorf("got %+v, want a warning on line 1", d)
+				}
+				got = append(got, d.Code+": "+d.Message)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+	if logged.Len() != 0 {
+		t.Errorf("logged %q", logged.String())
+	}
+}
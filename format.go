@@ -0,0 +1,233 @@
+package mova
+
+import (
+	"fmt"
+	"io"
+	"maps"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Rendering of AST nodes as source text.
+
+var escape = strings.NewReplacer(
+	"\"", "\\\"",
+	"\a", "\\a",
+	"\b", "\\b",
+	"\033", "\\e",
+	"\f", "\\f",
+	"\n", "\\n",
+	"\r", "\\r",
+	"\t", "\\t",
+	"\v", "\\v",
+	"\\", "\\\\",
+)
+
+var plainIdent = regexp.MustCompile(`^[\pL_][\pL\pN_]*(\.[\pL_][\pL\pN_]*)*$`)
+
+// formatName returns name as identifier, quoted if necessary.
+func formatName(name string) string {
+	if plainIdent.MatchString(name) && !slices.Contains(keywords, name) && !slices.Contains(booleans, name) {
+		return name
+	}
+	return "'" + strings.NewReplacer(`\`, `\\`, "'", `\'`).Replace(name) + "'"
+}
+
+func formatValue(v Value) string {
+	switch v := v.(type) {
+	case *ConstValue:
+		switch c := v.Value.(type) {
+		case string:
+			return `"` + escape.Replace(c) + `"`
+		case time.Duration:
+			return c.String()
+		case float64:
+			s := strconv.FormatFloat(c, 'f', -1, 64)
+			if !strings.Contains(s, ".") {
+				s += ".0"
+			}
+			return s
+		}
+		return fmt.Sprint(v.Value)
+	case *ReferenceValue:
+		return formatName(v.Ref)
+	case *CastValue:
+		return v.Type + "(" + formatValue(v.Value) + ")"
+	case *FuncValue:
+		args := make([]string, len(v.Args))
+		for i, arg := range v.Args {
+			args[i] = formatValue(arg)
+		}
+		return formatName(v.Name) + "(" + strings.Join(args, ", ") + ")"
+	}
+	return fmt.Sprint(v)
+}
+
+func formatArgs(args map[string]Value) string {
+	if len(args) == 0 {
+		return ""
+	}
+	var parts []string
+	for _, key := range slices.Sorted(maps.Keys(args)) {
+		parts = append(parts, formatName(key)+"="+formatValue(args[key]))
+	}
+	return "(" + strings.Join(parts, ", ") + ")"
+}
+
+func formatCond(c TriggerCond) string {
+	if len(c.Params) == 0 {
+		return formatName(c.Name)
+	}
+	var parts []string
+	for _, p := range c.Params {
+		if p.Optional {
+			parts = append(parts, formatName(p.Key)+"?")
+		} else if p.Value == nil {
+			parts = append(parts, formatName(p.Key))
+		} else {
+			parts = append(parts, formatName(p.Key)+"="+formatValue(p.Value))
+		}
+	}
+	return formatName(c.Name) + "(" + strings.Join(parts, ", ") + ")"
+}
+
+func formatConds(conds []TriggerCond) string {
+	var parts []string
+	for _, c := range conds {
+		parts = append(parts, formatCond(c))
+	}
+	return strings.Join(parts, ", ")
+}
+
+func formatStatement(stmt Statement) string {
+	switch s := stmt.(type) {
+	case *MoveStmt:
+		return "move " + formatName(s.Dest) + formatArgs(s.Args)
+	case *Call:
+		text := formatName(s.Name) + formatArgs(s.Args)
+		if s.Policy.Timeout > 0 {
+			text += " timeout " + s.Policy.Timeout.String()
+		}
+		if s.Policy.Retries > 0 {
+			text += " retry(" + strconv.Itoa(s.Policy.Retries)
+			if s.Policy.Backoff > 0 {
+				text += ", " + s.Policy.Backoff.String()
+			}
+			text += ")"
+		}
+		return text
+	}
+	if s, ok := stmt.(fmt.Stringer); ok {
+		return s.String()
+	}
+	return fmt.Sprintf("%#v", stmt)
+}
+
+func formatStatements(stmts []Statement) string {
+	var parts []string
+	for _, stmt := range stmts {
+		parts = append(parts, formatStatement(stmt))
+	}
+	return strings.Join(parts, ", ")
+}
+
+func formatParams(params []Param) string {
+	if len(params) == 0 {
+		return ""
+	}
+	var parts []string
+	for _, p := range params {
+		parts = append(parts, formatName(p.Name)+": "+p.Type)
+	}
+	return "(" + strings.Join(parts, ", ") + ")"
+}
+
+// formatCompensate formats a compensate block, it returns "" for nil.
+func formatCompensate(stmts []Statement) string {
+	switch {
+	case stmts == nil:
+		return ""
+	case len(stmts) == 0:
+		return "compensate {};"
+	}
+	return "compensate { " + formatStatements(stmts) + "; };"
+}
+
+func formatEntry(e Entry) string {
+	switch e := e.(type) {
+	case *SetStmt:
+		text := formatName(e.Key) + " = " + formatValue(e.Value) + ";"
+		if e.Override {
+			text = "override " + text
+		}
+		return text
+	case *VarDecl:
+		return e.String()
+	case *State:
+		var sb strings.Builder
+		if e.Final {
+			sb.WriteString("final ")
+		}
+		sb.WriteString("state " + formatName(e.Name) + formatParams(e.Params))
+		if e.Task != "" {
+			sb.WriteString(" awaiting task " + formatValue(&ConstValue{e.Task}))
+		}
+		sb.WriteString(" {\n")
+		for _, decl := range e.Vars {
+			sb.WriteString("\t" + decl.String() + "\n")
+		}
+		if len(e.Init) > 0 {
+			sb.WriteString("\t" + formatStatements(e.Init) + ";\n")
+		}
+		if e.Compensate != nil {
+			sb.WriteString("\t" + formatCompensate(e.Compensate) + "\n")
+		}
+		if len(e.Ignore) > 0 {
+			sb.WriteString("\t" + formatIgnore(e.Ignore) + "\n")
+		}
+		for _, trg := range e.Triggers {
+			sb.WriteString("\ton " + formatConds(trg.Cond) + " -> " + formatStatements(trg.Actions) + ";\n")
+		}
+		sb.WriteString("};")
+		return sb.String()
+	}
+	return fmt.Sprintf("%#v", e)
+}
+
+func formatIgnore(events []string) string {
+	var names []string
+	for _, event := range events {
+		names = append(names, formatName(event))
+	}
+	return "ignore " + strings.Join(names, ", ") + ";"
+}
+
+// WriteSource writes f as mova source. Comments and layout of the parsed source are not kept,
+// statements added by extensions are written using their String method.
+func (f *File) WriteSource(w io.Writer) error {
+	if f.Version != 0 {
+		if _, err := fmt.Fprintf(w, "mova %d;\n\n", f.Version); err != nil {
+			return err
+		}
+	}
+	for i, e := range f.Entries {
+		if _, isState := e.(*State); isState && i > 0 {
+			if _, err := io.WriteString(w, "\n"); err != nil {
+				return err
+			}
+		}
+		if _, err := io.WriteString(w, formatEntry(e)+"\n"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (f *File) String() string {
+	var sb strings.Builder
+	f.WriteSource(&sb)
+	return sb.String()
+}
@@ -0,0 +1,265 @@
+package mova
+
+import (
+	"cmp"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// FuncValue calls a pure function, written as `name(value, ...)`, registered using NewFunc or builtin:
+//
+//	len(x)             length of a string, list or map
+//	abs(x)             absolute value of a number
+//	min(x, y, ...)     smallest of numbers or strings
+//	max(x, y, ...)     largest of numbers or strings
+//	contains(x, y)     whether string x contains y, list x has an element y or map x a key y
+type FuncValue struct {
+	Name string
+	Args []Value
+
+	fn *funcSpec // nil if Name is not a function
+}
+
+// funcSpec is a pure function usable in values.
+type funcSpec struct {
+	// typ checks the types of the arguments and returns the type of the result.
+	typ  func(args []reflect.Type) (reflect.Type, error)
+	call func(args []reflect.Value, result reflect.Type) (any, error)
+	sig  reflect.Type // of functions registered with NewFunc, see Manifest
+}
+
+var builtinFuncs = map[string]*funcSpec{
+	"len": {
+		typ: func(args []reflect.Type) (reflect.Type, error) {
+			if len(args) != 1 {
+				return nil, fmt.Errorf("expected 1 argument, got %d", len(args))
+			}
+			switch args[0].Kind() {
+			case reflect.String, reflect.Slice, reflect.Array, reflect.Map:
+				return reflect.TypeFor[int](), nil
+			}
+			return nil, fmt.Errorf("invalid argument of type %v", args[0])
+		},
+		call: func(args []reflect.Value, result reflect.Type) (any, error) {
+			return args[0].Len(), nil
+		},
+	},
+	"abs": {
+		typ: func(args []reflect.Type) (reflect.Type, error) {
+			if len(args) != 1 {
+				return nil, fmt.Errorf("expected 1 argument, got %d", len(args))
+			}
+			if k := args[0].Kind(); !isInt(k) && !isFloat(k) {
+				return nil, fmt.Errorf("invalid argument of type %v", args[0])
+			}
+			return args[0], nil
+		},
+		call: func(args []reflect.Value, result reflect.Type) (any, error) {
+			v := args[0]
+			switch {
+			case v.CanInt() && v.Int() < 0:
+				return reflect.ValueOf(-v.Int()).Convert(result).Interface(), nil
+			case v.CanFloat() && v.Float() < 0:
+				return reflect.ValueOf(-v.Float()).Convert(result).Interface(), nil
+			}
+			return v.Interface(), nil
+		},
+	},
+	"min": {typ: orderedType, call: func(args []reflect.Value, result reflect.Type) (any, error) { return extreme(args, result, -1), nil }},
+	"max": {typ: orderedType, call: func(args []reflect.Value, result reflect.Type) (any, error) { return extreme(args, result, 1), nil }},
+	"contains": {
+		typ: func(args []reflect.Type) (reflect.Type, error) {
+			if len(args) != 2 {
+				return nil, fmt.Errorf("expected 2 arguments, got %d", len(args))
+			}
+			var elem reflect.Type
+			switch args[0].Kind() {
+			case reflect.String:
+				elem = args[0]
+				if args[1].Kind() == reflect.String {
+					elem = args[1]
+				}
+			case reflect.Slice, reflect.Array:
+				elem = args[0].Elem()
+			case reflect.Map:
+				elem = args[0].Key()
+			default:
+				return nil, fmt.Errorf("invalid argument of type %v", args[0])
+			}
+			if !coercible(args[1], elem) {
+				return nil, fmt.Errorf("cannot look for %v in %v", args[1], args[0])
+			}
+			return reflect.TypeFor[bool](), nil
+		},
+		call: func(args []reflect.Value, result reflect.Type) (any, error) {
+			x, y := args[0], args[1]
+			switch x.Kind() {
+			case reflect.String:
+				return strings.Contains(x.String(), y.String()), nil
+			case reflect.Map:
+				return x.MapIndex(y.Convert(x.Type().Key())).IsValid(), nil
+			}
+			y = y.Convert(x.Type().Elem())
+			for i := range x.Len() {
+				if x.Index(i).Equal(y) {
+					return true, nil
+				}
+			}
+			return false, nil
+		},
+	},
+}
+
+// orderedType checks the arguments of min and max, which share a type after coercion.
+func orderedType(args []reflect.Type) (reflect.Type, error) {
+	if len(args) == 0 {
+		return nil, fmt.Errorf("expected at least 1 argument")
+	}
+	typ := args[0]
+	for _, arg := range args {
+		switch {
+		case coercible(arg, typ):
+		case coercible(typ, arg):
+			typ = arg
+		default:
+			return nil, fmt.Errorf("mismatched arguments of type %v and %v", typ, arg)
+		}
+	}
+	if k := typ.Kind(); !isInt(k) && !isFloat(k) && k != reflect.String {
+		return nil, fmt.Errorf("invalid argument of type %v", typ)
+	}
+	return typ, nil
+}
+
+// extreme returns the smallest argument converted to result if sign is -1, or the largest if sign is 1.
+func extreme(args []reflect.Value, result reflect.Type, sign int) any {
+	best := args[0].Convert(result)
+	for _, arg := range args[1:] {
+		arg = arg.Convert(result)
+		if compareValues(arg, best)*sign > 0 {
+			best = arg
+		}
+	}
+	return best.Interface()
+}
+
+// compareValues orders numbers or strings of the same type.
+func compareValues(a, b reflect.Value) int {
+	switch {
+	case a.CanInt():
+		return cmp.Compare(a.Int(), b.Int())
+	case a.CanUint():
+		return cmp.Compare(a.Uint(), b.Uint())
+	case a.CanFloat():
+		return cmp.Compare(a.Float(), b.Float())
+	}
+	return cmp.Compare(a.String(), b.String())
+}
+
+func (v *FuncValue) EvalType(ctx map[string]Value) (reflect.Type, error) {
+	if v.fn == nil {
+		return nil, fmt.Errorf("unknown function %q", v.Name)
+	}
+	types := make([]reflect.Type, len(v.Args))
+	for i, arg := range v.Args {
+		typ, err := arg.EvalType(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if typ == nil {
+			return nil, fmt.Errorf("in call of %s: argument %d is nil", v.Name, i+1)
+		}
+		types[i] = typ
+	}
+	typ, err := v.fn.typ(types)
+	if err != nil {
+		return nil, fmt.Errorf("in call of %s: %w", v.Name, err)
+	}
+	return typ, nil
+}
+
+func (v *FuncValue) EvalValue(ctx map[string]Value) (any, error) {
+	if v.fn == nil {
+		return nil, fmt.Errorf("unknown function %q", v.Name)
+	}
+	args := make([]reflect.Value, len(v.Args))
+	types := make([]reflect.Type, len(v.Args))
+	for i, arg := range v.Args {
+		eval, err := arg.EvalValue(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if eval == nil {
+			return nil, fmt.Errorf("in call of %s: argument %d is nil", v.Name, i+1)
+		}
+		args[i] = reflect.ValueOf(eval)
+		types[i] = args[i].Type()
+	}
+	result, err := v.fn.typ(types)
+	if err != nil {
+		return nil, fmt.Errorf("in call of %s: %w", v.Name, err)
+	}
+	out, err := v.fn.call(args, result)
+	if err != nil {
+		return nil, fmt.Errorf("in call of %s: %w", v.Name, err)
+	}
+	return out, nil
+}
+
+// NewFunc registers fn as pure function, callable in values as name(args). fn returns a single result,
+// optionally followed by an error. As fn must not have side effects, calls with constant arguments
+// are evaluated when the machine is built. Functions replace builtin functions of the same name.
+func NewFunc(r *Registry, name string, fn any) {
+	val := reflect.ValueOf(fn)
+	typ := val.Type()
+	if typ.Kind() != reflect.Func || typ.IsVariadic() {
+		panic(fmt.Errorf("function %s must be a non-variadic func", name))
+	}
+	if typ.NumOut() == 0 || typ.NumOut() > 2 || typ.Out(0) == errorType || typ.NumOut() == 2 && typ.Out(1) != errorType {
+		panic(fmt.Errorf("function %s must return a result, optionally followed by an error", name))
+	}
+	if r.funcs == nil {
+		r.funcs = make(map[string]*funcSpec)
+	}
+	r.funcs[name] = &funcSpec{
+		typ: func(args []reflect.Type) (reflect.Type, error) {
+			if !reflectCall {
+				return nil, fmt.Errorf("not callable without reflection")
+			}
+			return signatureType(typ, args)
+		},
+		call: func(args []reflect.Value, result reflect.Type) (any, error) {
+			for i, arg := range args {
+				args[i] = arg.Convert(typ.In(i))
+			}
+			out, err := callFunc(val, args)
+			if err != nil {
+				return nil, err
+			}
+			return out.Interface(), nil
+		},
+		sig: typ,
+	}
+}
+
+// signatureType checks the types of the arguments of a call of a function of type typ.
+func signatureType(typ reflect.Type, args []reflect.Type) (reflect.Type, error) {
+	if len(args) != typ.NumIn() {
+		return nil, fmt.Errorf("expected %d arguments, got %d", typ.NumIn(), len(args))
+	}
+	for i, arg := range args {
+		if !coercible(arg, typ.In(i)) {
+			return nil, fmt.Errorf("type mismatch for argument %d: expected %v, got %v", i+1, typ.In(i), arg)
+		}
+	}
+	return typ.Out(0), nil
+}
+
+// function returns the function name, registered using NewFunc or builtin.
+func (r *Registry) function(name string) *funcSpec {
+	if fn, ok := r.funcs[name]; ok {
+		return fn
+	}
+	return builtinFuncs[name]
+}
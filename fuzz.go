@@ -0,0 +1,244 @@
+package mova
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"math/rand/v2"
+	"reflect"
+	"slices"
+	"strings"
+)
+
+// Invariant is checked by Fuzz after every event, it returns an error if the machine is in an invalid state.
+type Invariant func(m *StateMachine) error
+
+type FuzzOptions struct {
+	Seed        uint64           // seed of the first run, each following run uses the next seed
+	Runs        int              // number of instances to run, 100 if zero
+	Steps       int              // events emitted per run, 50 if zero
+	Options     []InstanceOption // passed to New for every run
+	Setup       func()           // called before every run, e.g. to reset state shared with actions
+	AllowErrors bool             // do not fail if an action returns an error
+}
+
+type FuzzEvent struct {
+	Name string
+	Data any
+}
+
+// FuzzFailure is returned by Fuzz. Events is a shortest found sequence of events causing Err,
+// running Fuzz with Seed and one run reproduces the original failure.
+type FuzzFailure struct {
+	Seed   uint64
+	Events []FuzzEvent
+	State  string // state after the last event
+	Err    error
+}
+
+func (f *FuzzFailure) Error() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "seed %d: in state %s after %d events: %v", f.Seed, f.State, len(f.Events), f.Err)
+	for _, ev := range f.Events {
+		fmt.Fprintf(&sb, "\n\t%s %+v", ev.Name, ev.Data)
+	}
+	return sb.String()
+}
+
+func (f *FuzzFailure) Unwrap() error {
+	return f.Err
+}
+
+// Fuzz emits random sequences of events to new instances and checks invariants after each event.
+// Events mostly are chosen from the triggers of the current state and their event-data is filled
+// with random values or the values the triggers test for. It returns a *FuzzFailure for the first
+// violated invariant or failing event.
+func (cm *CompiledMachine) Fuzz(opts FuzzOptions, invariants ...Invariant) error {
+	if opts.Runs == 0 {
+		opts.Runs = 100
+	}
+	if opts.Steps == 0 {
+		opts.Steps = 50
+	}
+	for run := range opts.Runs {
+		seed := opts.Seed + uint64(run)
+		g := fuzzer{cm: cm, rnd: rand.New(rand.NewPCG(seed, seed))}
+		var events []FuzzEvent
+		m, err := cm.fuzzRun(opts, invariants, func(m *StateMachine) (FuzzEvent, bool) {
+			if len(events) == opts.Steps {
+				return FuzzEvent{}, false
+			}
+			ev := g.event(m.current.Load())
+			events = append(events, ev)
+			return ev, true
+		})
+		if err != nil {
+			return cm.shrink(opts, invariants, &FuzzFailure{Seed: seed, Events: events, State: currentOf(m), Err: err})
+		}
+	}
+	return nil
+}
+
+func currentOf(m *StateMachine) string {
+	if m == nil {
+		return ""
+	}
+	return m.Current()
+}
+
+// fuzzRun runs a new instance with events from next until it returns false or a check fails.
+func (cm *CompiledMachine) fuzzRun(opts FuzzOptions, invariants []Invariant, next func(*StateMachine) (FuzzEvent, bool)) (*StateMachine, error) {
+	if opts.Setup != nil {
+		opts.Setup()
+	}
+	m, err := cm.New(opts.Options...)
+	if err != nil {
+		return m, err
+	}
+	check := func() error {
+		for _, inv := range invariants {
+			if err := inv(m); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := check(); err != nil {
+		return m, err
+	}
+	for {
+		ev, ok := next(m)
+		if !ok {
+			return m, nil
+		}
+		if err := m.Emit(ev.Name, ev.Data); err != nil && !errors.Is(err, io.EOF) && !opts.AllowErrors {
+			return m, fmt.Errorf("event %q: %w", ev.Name, err)
+		}
+		if err := check(); err != nil {
+			return m, err
+		}
+	}
+}
+
+// shrink removes events from a failing sequence as long as it keeps failing.
+func (cm *CompiledMachine) shrink(opts FuzzOptions, invariants []Invariant, fail *FuzzFailure) *FuzzFailure {
+	replay := func(events []FuzzEvent) (*StateMachine, int, error) {
+		n := 0
+		m, err := cm.fuzzRun(opts, invariants, func(*StateMachine) (FuzzEvent, bool) {
+			if n == len(events) {
+				return FuzzEvent{}, false
+			}
+			n++
+			return events[n-1], true
+		})
+		return m, n, err
+	}
+	for i := 0; i < len(fail.Events); {
+		candidate := slices.Delete(slices.Clone(fail.Events), i, i+1)
+		if m, n, err := replay(candidate); err != nil {
+			fail.Events, fail.State, fail.Err = candidate[:n], currentOf(m), err
+		} else {
+			i++
+		}
+	}
+	return fail
+}
+
+type fuzzer struct {
+	cm  *CompiledMachine
+	rnd *rand.Rand
+}
+
+func (g *fuzzer) event(state *CompiledState) FuzzEvent {
+	var handled []Condition
+	for _, trg := range state.Triggers {
+		for _, cond := range trg.cond {
+			if _, ok := g.cm.reg.triggers[cond.TriggerName]; ok {
+				handled = append(handled, cond)
+			}
+		}
+	}
+	if len(handled) > 0 && g.rnd.IntN(4) != 0 {
+		cond := handled[g.rnd.IntN(len(handled))]
+		typ := dataType(g.cm.reg.triggers[cond.TriggerName])
+		data := g.value(typ, 0)
+		for _, name := range slices.Sorted(maps.Keys(cond.Value)) {
+			want := cond.Value[name]
+			// mostly satisfy the condition, sometimes just miss it
+			if g.rnd.IntN(4) == 0 {
+				continue
+			}
+			if i := getTypeField(typ, name); i != -1 && reflect.TypeOf(want).AssignableTo(typ.Field(i).Type) {
+				data.Field(i).Set(reflect.ValueOf(want))
+			}
+		}
+		return FuzzEvent{cond.TriggerName, g.data(cond.TriggerName, data)}
+	}
+	names := slices.Sorted(maps.Keys(g.cm.reg.triggers))
+	name := names[g.rnd.IntN(len(names))]
+	return FuzzEvent{name, g.data(name, g.value(dataType(g.cm.reg.triggers[name]), 0))}
+}
+
+// data returns v as event-data of the trigger name, which may be a pointer.
+func (g *fuzzer) data(name string, v reflect.Value) any {
+	if g.cm.reg.triggers[name].Kind() == reflect.Pointer {
+		p := reflect.New(v.Type())
+		p.Elem().Set(v)
+		return p.Interface()
+	}
+	return v.Interface()
+}
+
+// value returns a random value of typ, small numbers and short strings make equal values likely.
+func (g *fuzzer) value(typ reflect.Type, depth int) reflect.Value {
+	v := reflect.New(typ).Elem()
+	switch typ.Kind() {
+	case reflect.Bool:
+		v.SetBool(g.rnd.IntN(2) == 0)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		v.SetInt(int64(g.rnd.IntN(21) - 10))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		v.SetUint(uint64(g.rnd.IntN(11)))
+	case reflect.Float32, reflect.Float64:
+		v.SetFloat(float64(g.rnd.IntN(21)-10) / 2)
+	case reflect.String:
+		b := make([]byte, g.rnd.IntN(4))
+		for i := range b {
+			b[i] = "abc"[g.rnd.IntN(3)]
+		}
+		v.SetString(string(b))
+	case reflect.Struct:
+		for i := range typ.NumField() {
+			if typ.Field(i).IsExported() {
+				v.Field(i).Set(g.value(typ.Field(i).Type, depth+1))
+			}
+		}
+	case reflect.Slice:
+		if depth < 3 {
+			n := g.rnd.IntN(4)
+			v.Set(reflect.MakeSlice(typ, n, n))
+			for i := range n {
+				v.Index(i).Set(g.value(typ.Elem(), depth+1))
+			}
+		}
+	case reflect.Array:
+		for i := range v.Len() {
+			v.Index(i).Set(g.value(typ.Elem(), depth+1))
+		}
+	case reflect.Map:
+		if depth < 3 {
+			v.Set(reflect.MakeMap(typ))
+			for range g.rnd.IntN(4) {
+				v.SetMapIndex(g.value(typ.Key(), depth+1), g.value(typ.Elem(), depth+1))
+			}
+		}
+	case reflect.Pointer:
+		if depth < 3 && g.rnd.IntN(2) == 0 {
+			p := reflect.New(typ.Elem())
+			p.Elem().Set(g.value(typ.Elem(), depth+1))
+			v.Set(p)
+		}
+	}
+	return v
+}
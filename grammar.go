@@ -0,0 +1,100 @@
+package mova
+
+import (
+	"bytes"
+	"encoding/json"
+	"maps"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// contextKeywords are identifiers with a meaning in certain positions only, see the parser.
+var contextKeywords = []string{"final", "awaiting", "task", "compensate", "choice", "schedule", "after", "set", "timeout", "retry", "if", "else", "var", "override", "ignore", "parallel"}
+
+// TextMateGrammar returns a TextMate grammar of machine files for editors such as VS Code,
+// derived from the tokens of the lexer. It includes the custom tokens, literals and statements of
+// the registry. Words which are keywords only in certain positions are highlighted everywhere.
+func (r *Registry) TextMateGrammar() []byte {
+	words := func(list []string) string {
+		quoted := make([]string, len(list))
+		for i, w := range list {
+			quoted[i] = regexp.QuoteMeta(w)
+		}
+		return `\b(?:` + strings.Join(quoted, "|") + `)\b`
+	}
+	match := func(scope, pattern string) map[string]any {
+		return map[string]any{"name": scope + ".mova", "match": pattern}
+	}
+	escape := map[string]any{"patterns": []any{match("constant.character.escape", `\\.`)}}
+	str := func(scope, delim string, escapes bool) map[string]any {
+		p := map[string]any{"name": scope + ".mova", "begin": delim, "end": delim}
+		if escapes {
+			maps.Copy(p, escape)
+		}
+		return p
+	}
+	digits := func(digit string) string {
+		return digit + `(?:_?` + digit + `)*`
+	}
+
+	var patterns []any
+	// like the lexer, custom tokens take precedence over builtin ones
+	for _, rule := range r.rules() {
+		pattern := strings.TrimSuffix(strings.TrimPrefix(rule.Pattern.String(), "^("), ")")
+		patterns = append(patterns, match("constant.other."+strings.ReplaceAll(rule.Name, " ", "."), pattern))
+	}
+	statements := slices.Sorted(maps.Keys(r.statements))
+	patterns = append(patterns,
+		match("comment.line.number-sign", `#.*$`),
+		match("meta.preprocessor", `@`+words(directiveWords)+`.*$`),
+		str("string.quoted.triple", `"""`, true),
+		str("string.quoted.double", `"`, true),
+		str("string.quoted.other", "`", false),
+		match("variable.other.quoted", `'(?:[^'\\\n]|\\.)*'`),
+		match("constant.numeric.duration", `[+-]?(?:\d+(?:\.\d*)?(?:`+strings.Join(durationUnits, "|")+`))+\b`),
+		match("constant.numeric.float", `[+-]?`+digits(`\d`)+`\.(?:`+digits(`\d`)+`)?`),
+		match("constant.numeric.integer", `[+-]?(?:0[xX]_?`+digits(`[0-9a-fA-F]`)+`|0[bB]_?`+digits(`[01]`)+`|0[oO]_?`+digits(`[0-7]`)+`|`+digits(`\d`)+`)`),
+		match("constant.language.boolean", words(booleans)),
+		match("keyword.control", words(keywords)),
+		match("keyword.other", words(slices.Concat(contextKeywords, statements))),
+		match("entity.name.function", `[\p{L}_][\p{L}\p{N}_]*(?:\.[\p{L}_][\p{L}\p{N}_]*)*(?=\s*\()`),
+		match("variable.other", `[\p{L}_][\p{L}\p{N}_]*(?:\.[\p{L}_][\p{L}\p{N}_]*)*`),
+		match("keyword.operator.arrow", `->`),
+		match("punctuation", `[`+regexp.QuoteMeta(punctuation)+`]`),
+	)
+	return marshalConfig(map[string]any{
+		"$schema":   "https://raw.githubusercontent.com/martinring/tmlanguage/master/tmlanguage.json",
+		"name":      "mova",
+		"scopeName": "source.mova",
+		"fileTypes": []string{"mova"},
+		"patterns":  patterns,
+	})
+}
+
+// LanguageConfiguration returns the VS Code language configuration of machine files, describing
+// comments, brackets and quotes.
+func LanguageConfiguration() []byte {
+	type pair struct {
+		Open  string `json:"open"`
+		Close string `json:"close"`
+	}
+	quotes := []pair{{`"`, `"`}, {"`", "`"}, {"'", "'"}}
+	return marshalConfig(map[string]any{
+		"comments":         map[string]any{"lineComment": "#"},
+		"brackets":         [][2]string{{"{", "}"}, {"(", ")"}},
+		"autoClosingPairs": slices.Concat([]pair{{"{", "}"}, {"(", ")"}}, quotes),
+		"surroundingPairs": slices.Concat([]pair{{"{", "}"}, {"(", ")"}}, quotes),
+		"wordPattern":      map[string]string{"pattern": `[\p{L}_][\p{L}\p{N}_]*`, "flags": "u"},
+	})
+}
+
+// marshalConfig encodes v as indented JSON, leaving characters of patterns such as > unescaped.
+func marshalConfig(v any) []byte {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	enc.Encode(v)
+	return buf.Bytes()
+}
@@ -0,0 +1,74 @@
+package mova
+
+import "context"
+
+// IdleHook is called when the machine settled: no event is being handled or queued and no
+// asynchronous action is running, including its retries and timeouts.
+type IdleHook func(m *StateMachine)
+
+// WithIdleHook calls hook whenever the machine settles, also after entering its initial state.
+func WithIdleHook(hook IdleHook) InstanceOption {
+	return func(m *StateMachine) {
+		m.idleHooks = append(m.idleHooks, hook)
+	}
+}
+
+// OnIdle calls hook whenever the machine settles, see IdleHook.
+func (m *StateMachine) OnIdle(hook IdleHook) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.idleHooks = append(m.idleHooks, hook)
+}
+
+// Idle reports whether the machine settled. Events in the store of a Scheduler are not considered.
+func (m *StateMachine) Idle() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.quiet()
+}
+
+// WaitIdle blocks until the machine settled, e.g. after emitting a burst of events which start
+// asynchronous actions.
+func (m *StateMachine) WaitIdle(ctx context.Context) error {
+	for {
+		m.mu.Lock()
+		if m.quiet() {
+			m.mu.Unlock()
+			return nil
+		}
+		if m.idleWait == nil {
+			m.idleWait = make(chan struct{})
+		}
+		wait := m.idleWait
+		m.mu.Unlock()
+		select {
+		case <-wait:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// quiet reports whether nothing is being handled, queued or running, m.mu must be held.
+func (m *StateMachine) quiet() bool {
+	return !m.busy && len(m.pending) == 0 && m.running == 0
+}
+
+// settled wakes WaitIdle and calls the idle hooks if the machine just became idle.
+func (m *StateMachine) settled() {
+	m.mu.Lock()
+	if m.idle || !m.quiet() {
+		m.mu.Unlock()
+		return
+	}
+	m.idle = true
+	if m.idleWait != nil {
+		close(m.idleWait)
+		m.idleWait = nil
+	}
+	hooks := m.idleHooks
+	m.mu.Unlock()
+	for _, hook := range hooks {
+		hook(m)
+	}
+}
@@ -0,0 +1,81 @@
+package mova
+
+import (
+	"maps"
+	"slices"
+)
+
+// The accessors below give tooling a read-only view of a compiled machine, returned values are
+// copies and may be modified freely.
+
+// Initial returns the name of the state a new instance starts in.
+func (cm *CompiledMachine) Initial() string {
+	return cm.order[0]
+}
+
+// States returns the names of all states in the order they are declared.
+func (cm *CompiledMachine) States() []string {
+	return slices.Clone(cm.order)
+}
+
+// State returns a copy of the state name.
+func (cm *CompiledMachine) State(name string) (CompiledState, bool) {
+	st, ok := cm.states[name]
+	if !ok {
+		return CompiledState{}, false
+	}
+	out := *st
+	out.Params = maps.Clone(st.Params)
+	out.Init = slices.Clone(st.Init)
+	out.Compensate = slices.Clone(st.Compensate)
+	out.Triggers = slices.Clone(st.Triggers)
+	out.initMoves = slices.Clone(st.initMoves)
+	return out, true
+}
+
+// Ignored returns the events the state ignores, see WithExhaustive.
+func (st CompiledState) Ignored() []string {
+	return slices.Clone(st.ignore)
+}
+
+// InitTargets returns the destinations of moves in the init actions of the state.
+func (st CompiledState) InitTargets() []string {
+	return slices.Clone(st.initMoves)
+}
+
+// Events returns the names of the events handled by the trigger, in order of its conditions.
+func (trg CompiledTrigger) Events() []string {
+	var out []string
+	for _, cond := range trg.cond {
+		if !slices.Contains(out, cond.TriggerName) {
+			out = append(out, cond.TriggerName)
+		}
+	}
+	return out
+}
+
+// Conditions returns the conditions of the trigger, any of which fires it.
+func (trg CompiledTrigger) Conditions() []Condition {
+	out := make([]Condition, len(trg.cond))
+	for i, cond := range trg.cond {
+		cond.Value = maps.Clone(cond.Value)
+		cond.Equal = maps.Clone(cond.Equal)
+		cond.Deferred = maps.Clone(cond.Deferred)
+		cond.keys = slices.Clone(cond.keys)
+		cond.types = maps.Clone(cond.types)
+		out[i] = cond
+	}
+	return out
+}
+
+// Bindings returns the event-data bound when the trigger fires, including optional ones, sorted.
+func (trg CompiledTrigger) Bindings() []string {
+	out := slices.Concat(trg.datatypes, slices.Collect(maps.Keys(trg.optional)))
+	slices.Sort(out)
+	return out
+}
+
+// Targets returns the destinations of moves in the actions of the trigger.
+func (trg CompiledTrigger) Targets() []string {
+	return slices.Clone(trg.moves)
+}
@@ -0,0 +1,54 @@
+package mova
+
+import (
+	"context"
+	"reflect"
+)
+
+// Invoker calls an action with its inputs, including injected parameters, in the order of the
+// parameters of ActionSpec.Function. It returns the result of the action, if any, and its error.
+type Invoker func(ins []reflect.Value) (reflect.Value, error)
+
+// input returns the value of an input, nil interfaces result in the zero value.
+func input[T any](v reflect.Value) T {
+	t, _ := v.Interface().(T)
+	return t
+}
+
+// newInvokedAction registers fn like NewAction, which is called by invoke.
+func newInvokedAction(r *Registry, name string, args []string, fn any, invoke Invoker) {
+	NewAction(r, name, args, fn)
+	spec := r.actions[name]
+	spec.Invoke = invoke
+	r.actions[name] = spec
+}
+
+// NewAction0 registers fn as action without arguments. Unlike NewAction, actions registered with
+// NewAction0 to NewAction3 are called without reflect.Value.Call, so they are available when building
+// with TinyGo or the mova_noreflect tag, e.g. for WASM and embedded targets.
+func NewAction0(r *Registry, name string, fn func(ctx context.Context, m *StateMachine) error) {
+	newInvokedAction(r, name, nil, fn, func(ins []reflect.Value) (reflect.Value, error) {
+		return reflect.Value{}, fn(input[context.Context](ins[0]), input[*StateMachine](ins[1]))
+	})
+}
+
+// NewAction1 registers fn as action with the argument a, see NewAction0.
+func NewAction1[A any](r *Registry, name, a string, fn func(ctx context.Context, m *StateMachine, a A) error) {
+	newInvokedAction(r, name, []string{a}, fn, func(ins []reflect.Value) (reflect.Value, error) {
+		return reflect.Value{}, fn(input[context.Context](ins[0]), input[*StateMachine](ins[1]), input[A](ins[2]))
+	})
+}
+
+// NewAction2 registers fn as action with the arguments a and b, see NewAction0.
+func NewAction2[A, B any](r *Registry, name, a, b string, fn func(ctx context.Context, m *StateMachine, a A, b B) error) {
+	newInvokedAction(r, name, []string{a, b}, fn, func(ins []reflect.Value) (reflect.Value, error) {
+		return reflect.Value{}, fn(input[context.Context](ins[0]), input[*StateMachine](ins[1]), input[A](ins[2]), input[B](ins[3]))
+	})
+}
+
+// NewAction3 registers fn as action with the arguments a, b and c, see NewAction0.
+func NewAction3[A, B, C any](r *Registry, name, a, b, c string, fn func(ctx context.Context, m *StateMachine, a A, b B, c C) error) {
+	newInvokedAction(r, name, []string{a, b, c}, fn, func(ins []reflect.Value) (reflect.Value, error) {
+		return reflect.Value{}, fn(input[context.Context](ins[0]), input[*StateMachine](ins[1]), input[A](ins[2]), input[B](ins[3]), input[C](ins[4]))
+	})
+}
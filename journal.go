@@ -0,0 +1,79 @@
+package mova
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+)
+
+type JournalKind string
+
+const (
+	JournalEvent        JournalKind = "event"        // an event was emitted
+	JournalUnhandled    JournalKind = "unhandled"    // an event matched no trigger
+	JournalTrigger      JournalKind = "trigger"      // a trigger matched an event
+	JournalAction       JournalKind = "action"       // an action returned
+	JournalTransition   JournalKind = "transition"   // the machine changed state
+	JournalCompensation JournalKind = "compensation" // the compensation of a saga step started, see WithSaga
+	JournalDuplicate    JournalKind = "duplicate"    // an event was dropped as duplicate, see WithDedup
+	JournalRateLimited  JournalKind = "ratelimited"  // an event exceeded a rate limit, see WithRateLimit
+	JournalAsyncError   JournalKind = "asyncerror"   // the done or error event of an asynchronous action failed, see NewAsyncAction
+)
+
+// JournalEntry describes a single step of a machine. Fields not applicable to Kind are left empty.
+type JournalEntry struct {
+	Time     time.Time     `json:"time"`
+	Machine  string        `json:"machine,omitempty"`
+	Kind     JournalKind   `json:"kind"`
+	State    string        `json:"state,omitempty"`
+	Event    string        `json:"event,omitempty"`
+	Data     any           `json:"data,omitempty"`
+	Trigger  *int          `json:"trigger,omitempty"`
+	Action   string        `json:"action,omitempty"`
+	Duration time.Duration `json:"duration,omitempty"`
+	Error    string        `json:"error,omitempty"`
+	From     string        `json:"from,omitempty"`
+	To       string        `json:"to,omitempty"`
+}
+
+// Journal records the steps of a machine, e.g. for auditing, see WithJournal.
+// Implementations must be safe for concurrent use.
+type Journal interface {
+	Record(JournalEntry)
+}
+
+// WithJournal records every event, matched trigger, action result and state change to j.
+func WithJournal(j Journal) InstanceOption {
+	return func(m *StateMachine) {
+		m.journal = j
+	}
+}
+
+func (m *StateMachine) record(entry JournalEntry) {
+	if m.journal == nil {
+		return
+	}
+	entry.Time = m.clock.Now()
+	entry.Machine = m.ID
+	m.journal.Record(entry)
+}
+
+// JSONJournal writes journal entries as JSON lines.
+type JSONJournal struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+	Err error // first error encountered while writing
+}
+
+func NewJSONJournal(w io.Writer) *JSONJournal {
+	return &JSONJournal{enc: json.NewEncoder(w)}
+}
+
+func (j *JSONJournal) Record(entry JournalEntry) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if err := j.enc.Encode(entry); err != nil && j.Err == nil {
+		j.Err = err
+	}
+}
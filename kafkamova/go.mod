@@ -0,0 +1,16 @@
+module github.com/friedelschoen/mova/kafkamova
+
+go 1.25.3
+
+replace github.com/friedelschoen/mova => ../
+
+require (
+	github.com/friedelschoen/mova v0.0.0-00010101000000-000000000000
+	github.com/segmentio/kafka-go v0.4.51
+)
+
+require (
+	github.com/coder/websocket v1.8.15 // indirect
+	github.com/klauspost/compress v1.15.9 // indirect
+	github.com/pierrec/lz4/v4 v4.1.15 // indirect
+)
@@ -0,0 +1,60 @@
+// Package kafkamova consumes Kafka messages as events of mova machines, see adapters.Consumer. It
+// is a module of its own, so only programs using it depend on the Kafka client.
+package kafkamova
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/friedelschoen/mova/adapters"
+	"github.com/segmentio/kafka-go"
+)
+
+// ErrRedeliver is returned when a message could not be handled. Kafka cannot redeliver a single
+// message, so the consumer stops without committing its offset, and the message is delivered again
+// once the consumer group resumes.
+var ErrRedeliver = errors.New("message must be redelivered")
+
+// Reader is the part of a kafka.Reader used by Source.
+type Reader interface {
+	FetchMessage(ctx context.Context) (kafka.Message, error)
+	CommitMessages(ctx context.Context, msgs ...kafka.Message) error
+}
+
+var _ Reader = (*kafka.Reader)(nil)
+
+// Source receives the messages of a Kafka reader of a consumer group, see kafka.ReaderConfig.GroupID.
+// The offset of a message is committed once its event was handled, messages whose event failed stop
+// the consumer with ErrRedeliver.
+type Source struct {
+	r Reader
+}
+
+var _ adapters.Source = (*Source)(nil)
+
+// NewSource returns a Source receiving the messages of r, usually a *kafka.Reader.
+func NewSource(r Reader) *Source {
+	return &Source{r: r}
+}
+
+// Receive waits for the next message. It returns io.EOF once the reader is closed.
+func (s *Source) Receive(ctx context.Context) (adapters.Message, error) {
+	msg, err := s.r.FetchMessage(ctx)
+	if err != nil {
+		return adapters.Message{}, err // io.EOF if closed
+	}
+	id := fmt.Sprintf("%s/%d/%d", msg.Topic, msg.Partition, msg.Offset)
+	return adapters.Message{
+		ID:      id,
+		Subject: msg.Topic,
+		Data:    msg.Value,
+		Ack: func() error {
+			// the event is handled, the commit must not be abandoned with the consumer
+			return s.r.CommitMessages(context.WithoutCancel(ctx), msg)
+		},
+		Nack: func() error {
+			return fmt.Errorf("%w: %s", ErrRedeliver, id)
+		},
+	}, nil
+}
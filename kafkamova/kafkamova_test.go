@@ -0,0 +1,86 @@
+package kafkamova
+
+import (
+	"context"
+	"errors"
+	"io"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/friedelschoen/mova"
+	"github.com/friedelschoen/mova/adapters"
+	"github.com/segmentio/kafka-go"
+)
+
+// partition is a Reader of a single partition, which is closed at its end.
+type partition struct {
+	msgs      []kafka.Message
+	next      int
+	committed []int64
+}
+
+func (p *partition) FetchMessage(ctx context.Context) (kafka.Message, error) {
+	if p.next == len(p.msgs) {
+		return kafka.Message{}, io.EOF
+	}
+	p.next++
+	return p.msgs[p.next-1], nil
+}
+
+func (p *partition) CommitMessages(ctx context.Context, msgs ...kafka.Message) error {
+	for _, msg := range msgs {
+		p.committed = append(p.committed, msg.Offset)
+	}
+	return nil
+}
+
+type Paid struct {
+	Order  string `mova:"order"`
+	Amount int    `mova:"amount"`
+}
+
+func TestSource(t *testing.T) {
+	var reg mova.Registry
+	mova.NewTrigger[Paid](&reg, "paid")
+	booked := make(map[string]int)
+	fail := true
+	mova.NewAction(&reg, "book", []string{"order", "amount"}, func(order string, amount int) error {
+		if order == "2" && fail {
+			fail = false
+			return errors.New("unavailable")
+		}
+		booked[order] += amount
+		return nil
+	})
+	cm, err := mova.BuildMachine("orders.mova", strings.NewReader(`state open { on paid(order, amount) -> book(order=order, amount=amount); };`), &reg, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	router := mova.NewRouter(cm, mova.FieldKey("Order"))
+	var msgs []kafka.Message
+	for i, data := range []string{`{"order": "1", "amount": 3}`, `{"order": "2", "amount": 5}`, `{"order": "3", "amount": 1}`} {
+		msgs = append(msgs, kafka.Message{Topic: "paid", Offset: int64(i), Value: []byte(data)})
+	}
+
+	p := &partition{msgs: msgs}
+	consumer := &adapters.Consumer{Source: NewSource(p), Router: router, Decoder: adapters.DecodeSubject}
+	if err := consumer.Run(context.Background()); !errors.Is(err, ErrRedeliver) {
+		t.Fatalf("got error %v, want %v", err, ErrRedeliver)
+	}
+	if !slices.Equal(p.committed, []int64{0}) {
+		t.Fatalf("committed %v, want the handled message", p.committed)
+	}
+	// the consumer group resumes at the committed offset
+	p = &partition{msgs: msgs[1:]}
+	consumer.Source = NewSource(p)
+	if err := consumer.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(p.committed, []int64{1, 2}) {
+		t.Fatalf("committed %v", p.committed)
+	}
+	if booked["1"] != 3 || booked["2"] != 5 || booked["3"] != 1 {
+		t.Fatalf("booked %v", booked)
+	}
+}
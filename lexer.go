@@ -1,102 +1,196 @@
 package mova
 
 import (
-	"bufio"
+	"bytes"
 	"errors"
 	"io"
 	"regexp"
 	"unicode/utf8"
 )
 
+// chunkSize is the number of bytes read from the input at once.
+const chunkSize = 4096
+
+// rule is a token added by an extension, see NewToken.
 type rule struct {
 	Name    string
 	Pattern *regexp.Regexp
 }
 
+// Position is a location in a source file.
+type Position struct {
+	Offset int `json:"offset"` // byte offset, starting at 0
+	Line   int `json:"line"`   // line number, starting at 1
+	Column int `json:"column"` // byte offset in the line, starting at 0
+}
+
+// Span is the range of a node in a source file, End is exclusive.
+type Span struct {
+	Start Position `json:"start"`
+	End   Position `json:"end"`
+}
+
 type lexer struct {
-	reader *bufio.Reader
+	reader io.Reader
 	rules  []rule
 
-	text     []byte
-	linesize int
+	buf []byte // unread input, starting at the current token
+	eof bool
+
+	prevEnd  Position // end of the previous token
+	comments bool     // emit comments as tokens instead of skipping them, directives are always emitted
 
 	Token  string
 	Linenr int
 	Offset int
+	Pos    int
 	Length int
 	Value  string
 	Err    error
 }
 
 func newLexer(reader io.Reader, rules []rule) *lexer {
+	return newLexerAt(reader, rules, Position{Line: 1})
+}
+
+// Token is a lexical element of a source file, as returned by Tokenize.
+type Token struct {
+	Kind  string // "keyword", "identifier", "string", "int", "comment", "ILLEGAL", ...
+	Value string
+	Span  Span
+}
+
+// Tokenize splits src into tokens using the builtin syntax, including comments but not whitespace.
+func Tokenize(src []byte) []Token {
+	var r Registry
+	return r.Tokenize(src)
+}
+
+// Tokenize is like the Tokenize function, but also recognizes custom tokens and literals of the registry.
+func (r *Registry) Tokenize(src []byte) []Token {
+	return tokenize(bytes.NewReader(src), r.rules())
+}
+
+func tokenize(reader io.Reader, rules []rule) []Token {
+	tz := &lexer{reader: reader, rules: rules, comments: true, Linenr: 1}
+	var toks []Token
+	for tz.Next(); tz.Token != "EOF" && tz.Token != "ERROR"; tz.Next() {
+		start := tz.position()
+		end := start
+		for _, c := range tz.buf[:tz.Length] {
+			end.Offset++
+			if c == '\n' {
+				end.Line++
+				end.Column = 0
+			} else {
+				end.Column++
+			}
+		}
+		toks = append(toks, Token{Kind: tz.Token, Value: tz.Value, Span: Span{start, end}})
+	}
+	return toks
+}
+
+// newLexerAt creates a lexer for input starting at pos of a file.
+func newLexerAt(reader io.Reader, rules []rule, pos Position) *lexer {
 	var lex lexer
-	lex.reader = bufio.NewReader(reader)
+	lex.reader = reader
 	lex.rules = rules
-	lex.Linenr = 1
+	lex.Pos = pos.Offset
+	lex.Linenr = pos.Line
+	lex.Offset = pos.Column
 
 	lex.Next() /* pull first token */
 	return &lex
 }
 
+func (tz *lexer) position() Position {
+	return Position{Offset: tz.Pos, Line: tz.Linenr, Column: tz.Offset}
+}
+
 func (tz *lexer) move(n int) {
-	tz.text = tz.text[n:]
-	tz.Offset += n
+	for _, c := range tz.buf[:n] {
+		if c == '\n' {
+			tz.Linenr++
+			tz.Offset = 0
+		} else {
+			tz.Offset++
+		}
+	}
+	tz.buf = tz.buf[n:]
+	tz.Pos += n
 }
 
 func (tz *lexer) makeToken(typ string, n int) {
 	tz.Token = typ
 	tz.Length = n
-	tz.Value = string(tz.text[:n])
+	tz.Value = string(tz.buf[:n])
 }
 
-func (tz *lexer) readLine() error {
-	tz.Linenr += tz.linesize
-	tz.Offset = 0
-	tz.linesize = 0
-
-	var buf []byte
-	for {
-		tz.linesize++
-		line, err := tz.reader.ReadBytes('\n')
-		if err != nil {
-			return err
-		}
-		if line[len(line)-1] != '\\' {
-			tz.text = append(buf, line...)
-			return nil
+// fill reads the next chunk of input, it reports false if the input is exhausted.
+func (tz *lexer) fill() bool {
+	if tz.eof {
+		return false
+	}
+	chunk := make([]byte, chunkSize)
+	n, err := tz.reader.Read(chunk)
+	tz.buf = append(tz.buf, chunk[:n]...)
+	if err != nil {
+		if !errors.Is(err, io.EOF) {
+			tz.Err = err
 		}
-		buf = append(buf, line[:len(line)-1]...)
+		tz.eof = true
 	}
+	return true
 }
 
 func (tz *lexer) Next() {
 	// move forward
 	tz.move(tz.Length)
+	tz.Length = 0
+	tz.prevEnd = tz.position()
 
 tokenLoop:
 	for {
-		if len(tz.text) == 0 {
-			if err := tz.readLine(); err != nil {
-				if errors.Is(err, io.EOF) {
-					tz.makeToken("EOF", 0)
-					return
-				}
-				tz.Err = err
-				tz.makeToken("ERROR", 0)
-				return
-			}
+		if tz.Err != nil {
+			tz.makeToken("ERROR", 0)
+			return
+		}
+		// tokens may look ahead to the end of the line, e.g. 0_1 or 3us, read it before matching
+		if bytes.IndexByte(tz.buf, '\n') == -1 && tz.fill() {
+			continue
+		}
+		if len(tz.buf) == 0 {
+			tz.makeToken("EOF", 0)
+			return
 		}
+		// longest match wins, ties go to whitespace, comments and directives, then to the earlier
+		// custom rule and lastly to builtin tokens
+		kind, n := scan(tz.buf)
+		fixed := kind == "" || kind == "comment" || kind == "directive"
 		for _, r := range tz.rules {
-			if loc := r.Pattern.FindIndex(tz.text); loc != nil && loc[0] == 0 {
-				if r.Name == "" {
-					tz.move(loc[1])
-					continue tokenLoop
-				}
-				tz.makeToken(r.Name, loc[1])
-				return
+			loc := r.Pattern.FindIndex(tz.buf)
+			if loc != nil && loc[0] == 0 && (loc[1] > n || loc[1] == n && n > 0 && !fixed) {
+				kind, n, fixed = r.Name, loc[1], true
 			}
 		}
-		_, sz := utf8.DecodeRune(tz.text)
+		if n > 0 {
+			// the token may continue in the next chunk
+			if n == len(tz.buf) && tz.fill() {
+				continue tokenLoop
+			}
+			if kind == "" || kind == "comment" && !tz.comments {
+				tz.move(n)
+				continue tokenLoop
+			}
+			tz.makeToken(kind, n)
+			return
+		}
+		// the token may be completed by the next chunk, e.g. an unterminated string
+		if tz.fill() {
+			continue
+		}
+		_, sz := utf8.DecodeRune(tz.buf)
 		tz.makeToken("ILLEGAL", sz)
 		return
 	}
@@ -0,0 +1,185 @@
+package mova
+
+import (
+	"bytes"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+// tok is an expected token, its span is written as line:column-line:column.
+type tok struct {
+	kind, value, span string
+}
+
+func TestTokenize(t *testing.T) {
+	var reg Registry
+	NewToken(&reg, "version", `v[0-9]+(\.[0-9]+)+`)
+	NewToken(&reg, "tag", `@[a-z]+`)
+	NewToken(&reg, "abc", `abc`)
+	long := strings.Repeat("x", 2*chunkSize)
+	tests := []struct {
+		name string
+		src  string
+		want []tok
+	}{
+		{"int", "0 007 0x1F 0b101 0o17 0x -5 +5 1_000 1__0 0_1", []tok{
+			{"int", "0", "1:0-1:1"},
+			{"int", "007", "1:2-1:5"},
+			{"int", "0x1F", "1:6-1:10"},
+			{"int", "0b101", "1:11-1:16"},
+			{"int", "0o17", "1:17-1:21"},
+			{"int", "0", "1:22-1:23"},
+			{"identifier", "x", "1:23-1:24"},
+			{"int", "-5", "1:25-1:27"},
+			{"int", "+5", "1:28-1:30"},
+			{"int", "1_000", "1:31-1:36"},
+			{"int", "1", "1:37-1:38"},
+			{"identifier", "__0", "1:38-1:41"},
+			{"int", "0_1", "1:42-1:45"},
+		}},
+		{"float", "1.5 1. 007.50 -0.5", []tok{
+			{"float", "1.5", "1:0-1:3"},
+			{"float", "1.", "1:4-1:6"},
+			{"float", "007.50", "1:7-1:13"},
+			{"float", "-0.5", "1:14-1:18"},
+		}},
+		{"duration", "1h30m 1.5s 10ms -2s 5min 3us 2hx", []tok{
+			{"duration", "1h30m", "1:0-1:5"},
+			{"duration", "1.5s", "1:6-1:10"},
+			{"duration", "10ms", "1:11-1:15"},
+			{"duration", "-2s", "1:16-1:19"},
+			{"int", "5", "1:20-1:21"},
+			{"identifier", "min", "1:21-1:24"},
+			{"duration", "3us", "1:25-1:28"},
+			{"int", "2", "1:29-1:30"},
+			{"identifier", "hx", "1:30-1:32"},
+		}},
+		{"string", "\"a\\\"b\" \"multi\nline\" `raw\n\\n` \"\"\"long\n\"q\"\n\"\"\" \"\"", []tok{
+			{"string", "\"a\\\"b\"", "1:0-1:6"},
+			{"string", "\"multi\nline\"", "1:7-2:5"},
+			{"string", "`raw\n\\n`", "2:6-3:3"},
+			{"string", "\"\"\"long\n\"q\"\n\"\"\"", "3:4-5:3"},
+			{"string", "\"\"", "5:4-5:6"},
+		}},
+		{"unterminated string", "\"open", []tok{
+			{"ILLEGAL", "\"", "1:0-1:1"},
+			{"identifier", "open", "1:1-1:5"},
+		}},
+		{"quoted identifier", "'my id' 'x' '' 'a\nb'", []tok{
+			{"identifier", "'my id'", "1:0-1:7"},
+			{"identifier", "'x'", "1:8-1:11"},
+			{"ILLEGAL", "'", "1:12-1:13"},
+			{"identifier", "' '", "1:13-1:16"},
+			{"identifier", "a", "1:16-1:17"},
+			{"identifier", "b", "2:0-2:1"},
+			{"ILLEGAL", "'", "2:1-2:2"},
+		}},
+		{"unicode identifier", "naïve 日本 x.y.z a. _1 é1", []tok{
+			{"identifier", "naïve", "1:0-1:6"},
+			{"identifier", "日本", "1:7-1:13"},
+			{"identifier", "x.y.z", "1:14-1:19"},
+			{"identifier", "a", "1:20-1:21"},
+			{"ILLEGAL", ".", "1:21-1:22"},
+			{"identifier", "_1", "1:23-1:25"},
+			{"identifier", "é1", "1:26-1:29"},
+		}},
+		{"keyword", "state states on on_x move final override var ignore awaiting compensate mova true truex", []tok{
+			{"keyword", "state", "1:0-1:5"},
+			{"identifier", "states", "1:6-1:12"},
+			{"keyword", "on", "1:13-1:15"},
+			{"identifier", "on_x", "1:16-1:20"},
+			{"keyword", "move", "1:21-1:25"},
+			{"identifier", "final", "1:26-1:31"},
+			{"identifier", "override", "1:32-1:40"},
+			{"identifier", "var", "1:41-1:44"},
+			{"identifier", "ignore", "1:45-1:51"},
+			{"identifier", "awaiting", "1:52-1:60"},
+			{"identifier", "compensate", "1:61-1:71"},
+			{"identifier", "mova", "1:72-1:76"},
+			{"bool", "true", "1:77-1:81"},
+			{"identifier", "truex", "1:82-1:87"},
+		}},
+		{"custom", "v1.2 v1 @tag abc abcd", []tok{
+			{"version", "v1.2", "1:0-1:4"},
+			{"identifier", "v1", "1:5-1:7"},
+			{"tag", "@tag", "1:8-1:12"},
+			{"abc", "abc", "1:13-1:16"},
+			{"identifier", "abcd", "1:17-1:21"},
+		}},
+		{"comment and directive", "# c\n@if pro # x\n@else\n@endif\n@iffy", []tok{
+			{"comment", "# c", "1:0-1:3"},
+			{"directive", "@if pro # x", "2:0-2:11"},
+			{"directive", "@else", "3:0-3:5"},
+			{"directive", "@endif", "4:0-4:6"},
+			{"tag", "@iffy", "5:0-5:5"},
+		}},
+		{"punctuation", "-> ; { } ( ) , = : % ? ! $", []tok{
+			{"arrow", "->", "1:0-1:2"},
+			{"punct", ";", "1:3-1:4"},
+			{"punct", "{", "1:5-1:6"},
+			{"punct", "}", "1:7-1:8"},
+			{"punct", "(", "1:9-1:10"},
+			{"punct", ")", "1:11-1:12"},
+			{"punct", ",", "1:13-1:14"},
+			{"punct", "=", "1:15-1:16"},
+			{"punct", ":", "1:17-1:18"},
+			{"punct", "%", "1:19-1:20"},
+			{"punct", "?", "1:21-1:22"},
+			{"ILLEGAL", "!", "1:23-1:24"},
+			{"ILLEGAL", "$", "1:25-1:26"},
+		}},
+		{"longer than a chunk", long + " `" + long + "` #" + long, []tok{
+			{"identifier", long, fmt.Sprintf("1:0-1:%d", len(long))},
+			{"string", "`" + long + "`", fmt.Sprintf("1:%d-1:%d", len(long)+1, 2*len(long)+3)},
+			{"comment", "#" + long, fmt.Sprintf("1:%d-1:%d", 2*len(long)+4, 3*len(long)+5)},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// the chunk boundary at every byte of src, and a chunk for every byte
+			for pad := max(chunkSize-len(tt.src), 0); pad <= chunkSize; pad++ {
+				src := strings.Repeat("\n", pad) + tt.src
+				checkTokens(t, src, reg.Tokenize([]byte(src)), tt.want, pad)
+			}
+			checkTokens(t, tt.src, tokenize(iotest.OneByteReader(strings.NewReader(tt.src)), reg.rules()), tt.want, 0)
+		})
+	}
+}
+
+// checkTokens compares toks of src with want, whose lines are shifted by pad.
+func checkTokens(t *testing.T, src string, toks []Token, want []tok, pad int) {
+	t.Helper()
+	var got []tok
+	for _, tk := range toks {
+		s := tk.Span
+		if src[s.Start.Offset:s.End.Offset] != tk.Value {
+			t.Fatalf("pad %d: span %d-%d of %q covers %q", pad, s.Start.Offset, s.End.Offset, tk.Value, src[s.Start.Offset:s.End.Offset])
+		}
+		span := fmt.Sprintf("%d:%d-%d:%d", s.Start.Line-pad, s.Start.Column, s.End.Line-pad, s.End.Column)
+		got = append(got, tok{tk.Kind, tk.Value, span})
+	}
+	if len(got) != len(want) {
+		t.Fatalf("pad %d: got %d tokens %q, want %d", pad, len(got), got, len(want))
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("pad %d: token %d is %q, want %q", pad, i, got[i], want[i])
+		}
+	}
+}
+
+// TestLexerSkips checks that the parser does not see whitespace and comments, but does see
+// directives.
+func TestLexerSkips(t *testing.T) {
+	lex := newLexer(bytes.NewReader([]byte("  # c\n@if x\n\ta")), nil)
+	var got []string
+	for ; lex.Token != "EOF"; lex.Next() {
+		got = append(got, lex.Token+" "+lex.Value)
+	}
+	if want := []string{"directive @if x", "identifier a"}; !slices.Equal(got, want) {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,302 @@
+package mova
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+)
+
+var (
+	ErrInstanceExists  = errors.New("instance already exists")
+	ErrUnknownInstance = errors.New("unknown instance")
+)
+
+// Manager owns named instances of a machine and serializes events per instance.
+type Manager struct {
+	cm   *CompiledMachine
+	opts []InstanceOption
+
+	mu        sync.Mutex // guards the maps and life, not held while handling events or accessing the store
+	instances map[string]*managed
+	keys      map[string]*keyLock // by instance name, see lock
+	life      Lifecycle
+}
+
+type managed struct {
+	m       *StateMachine
+	loaded  time.Time
+	touched time.Time
+}
+
+// keyLock serializes events and store access for an instance name.
+type keyLock struct {
+	mu   sync.Mutex
+	refs int // callers holding or waiting for mu, the lock is dropped at zero
+}
+
+// Lifecycle moves instances of a Manager between memory and a Store.
+// Evicted instances are restored on the next event for them, with the options of the manager.
+// Options passed to Create are not restored.
+type Lifecycle struct {
+	Store   Store
+	TTL     time.Duration // evict instances this long after they were created or restored, 0 for no limit
+	Idle    time.Duration // evict instances which received no event for this long, 0 for no limit
+	Migrate Migration     // passed to Restore for snapshots of another version
+	Clock   Clock         // the system clock if nil
+}
+
+// NewManager returns a Manager creating instances of cm with opts.
+func NewManager(cm *CompiledMachine, opts ...InstanceOption) *Manager {
+	return &Manager{
+		cm:        cm,
+		opts:      opts,
+		instances: make(map[string]*managed),
+		keys:      make(map[string]*keyLock),
+		life:      Lifecycle{Clock: realClock{}},
+	}
+}
+
+// SetLifecycle makes mg evict instances to l.Store, see Evict.
+func (mg *Manager) SetLifecycle(l Lifecycle) {
+	if l.Clock == nil {
+		l.Clock = realClock{}
+	}
+	mg.mu.Lock()
+	defer mg.mu.Unlock()
+	mg.life = l
+}
+
+// Machine returns the machine of all instances.
+func (mg *Manager) Machine() *CompiledMachine {
+	return mg.cm
+}
+
+// lock locks the instance name id until the returned function is called, so events and store
+// access for it are serialized while other instances proceed.
+func (mg *Manager) lock(id string) (unlock func()) {
+	mg.mu.Lock()
+	k, ok := mg.keys[id]
+	if !ok {
+		k = &keyLock{}
+		mg.keys[id] = k
+	}
+	k.refs++
+	mg.mu.Unlock()
+	k.mu.Lock()
+	return func() {
+		k.mu.Unlock()
+		mg.mu.Lock()
+		if k.refs--; k.refs == 0 {
+			delete(mg.keys, id)
+		}
+		mg.mu.Unlock()
+	}
+}
+
+func (mg *Manager) add(id string, m *StateMachine) *managed {
+	mg.mu.Lock()
+	defer mg.mu.Unlock()
+	now := mg.life.Clock.Now()
+	inst := &managed{m: m, loaded: now, touched: now}
+	mg.instances[id] = inst
+	return inst
+}
+
+// lifecycle returns the lifecycle of mg.
+func (mg *Manager) lifecycle() Lifecycle {
+	mg.mu.Lock()
+	defer mg.mu.Unlock()
+	return mg.life
+}
+
+// lookup returns the instance named id, restoring it from the store if it was evicted, id must
+// be locked. It returns nil if the instance does not exist.
+func (mg *Manager) lookup(ctx context.Context, id string) (*managed, error) {
+	mg.mu.Lock()
+	inst, ok := mg.instances[id]
+	life := mg.life
+	mg.mu.Unlock()
+	if ok || life.Store == nil {
+		return inst, nil
+	}
+	s, err := life.Store.Load(ctx, id)
+	if errors.Is(err, ErrNotStored) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("unable to load instance %q: %w", id, err)
+	}
+	m, err := mg.cm.Restore(s, life.Migrate, mg.opts...)
+	if err != nil {
+		return nil, fmt.Errorf("unable to restore instance %q: %w", id, err)
+	}
+	return mg.add(id, m), nil
+}
+
+// Create creates an instance named id, with opts applied after the options of the manager.
+func (mg *Manager) Create(id string, opts ...InstanceOption) (*StateMachine, error) {
+	defer mg.lock(id)()
+	mg.mu.Lock()
+	_, exists := mg.instances[id]
+	store := mg.life.Store
+	mg.mu.Unlock()
+	if exists {
+		return nil, fmt.Errorf("%w: %q", ErrInstanceExists, id)
+	}
+	if store != nil {
+		if _, err := store.Load(context.Background(), id); !errors.Is(err, ErrNotStored) {
+			if err != nil {
+				return nil, fmt.Errorf("unable to load instance %q: %w", id, err)
+			}
+			return nil, fmt.Errorf("%w: %q", ErrInstanceExists, id)
+		}
+	}
+	m, err := mg.cm.New(append(slices.Concat(mg.opts, opts), WithID(id))...)
+	if err != nil {
+		return nil, err
+	}
+	mg.add(id, m)
+	return m, nil
+}
+
+// Get returns the instance named id, restoring it from the store if it was evicted.
+func (mg *Manager) Get(id string) (*StateMachine, bool) {
+	defer mg.lock(id)()
+	inst, err := mg.lookup(context.Background(), id)
+	if err != nil || inst == nil {
+		return nil, false
+	}
+	return inst.m, true
+}
+
+// Delete removes the instance named id, also from the store, and reports whether it existed.
+func (mg *Manager) Delete(id string) bool {
+	defer mg.lock(id)()
+	mg.mu.Lock()
+	_, ok := mg.instances[id]
+	delete(mg.instances, id)
+	store := mg.life.Store
+	mg.mu.Unlock()
+	if store != nil {
+		ctx := context.Background()
+		if _, err := store.Load(ctx, id); err == nil {
+			ok = true
+		}
+		store.Delete(ctx, id)
+	}
+	return ok
+}
+
+// IDs returns the names of all instances in memory in sorted order.
+func (mg *Manager) IDs() []string {
+	mg.mu.Lock()
+	defer mg.mu.Unlock()
+	ids := make([]string, 0, len(mg.instances))
+	for id := range mg.instances {
+		ids = append(ids, id)
+	}
+	slices.Sort(ids)
+	return ids
+}
+
+// Emit delivers an event to the instance named id. Unlike calling Emit on the instance from several goroutines,
+// concurrent calls for the same instance wait for each other, so each caller receives the result of its own event.
+func (mg *Manager) Emit(ctx context.Context, id string, name string, v any) error {
+	return mg.deliver(ctx, id, "", Event{Name: name, Data: v})
+}
+
+// EmitEvent is Emit for ev, which is deduplicated by its ID if the instance was created WithDedup.
+func (mg *Manager) EmitEvent(ctx context.Context, id string, ev Event) error {
+	return mg.deliver(ctx, id, "", ev)
+}
+
+// deliver emits ev to the instance named id, which must wait for task if not empty.
+func (mg *Manager) deliver(ctx context.Context, id, task string, ev Event) error {
+	defer mg.lock(id)()
+	inst, err := mg.lookup(ctx, id)
+	if err != nil {
+		return err
+	}
+	if inst == nil {
+		return fmt.Errorf("%w: %q", ErrUnknownInstance, id)
+	}
+	if task != "" {
+		if err := awaits(inst.m, task); err != nil {
+			return err
+		}
+	}
+	return mg.emit(ctx, inst, ev)
+}
+
+// emit delivers ev to inst, whose name must be locked.
+func (mg *Manager) emit(ctx context.Context, inst *managed, ev Event) error {
+	err := inst.m.EmitEvent(ctx, ev)
+	mg.mu.Lock()
+	inst.touched = mg.life.Clock.Now()
+	mg.mu.Unlock()
+	return err
+}
+
+// Evict saves the instances whose TTL or idle timeout of the lifecycle expired to its store and
+// removes them from memory. It returns the number of evicted instances.
+func (mg *Manager) Evict(ctx context.Context) (int, error) {
+	life := mg.lifecycle()
+	if life.Store == nil {
+		return 0, nil
+	}
+	due := func(inst *managed, now time.Time) bool {
+		return life.TTL > 0 && now.Sub(inst.loaded) >= life.TTL || life.Idle > 0 && now.Sub(inst.touched) >= life.Idle
+	}
+	var ids []string
+	mg.mu.Lock()
+	now := life.Clock.Now()
+	for id, inst := range mg.instances {
+		if due(inst, now) {
+			ids = append(ids, id)
+		}
+	}
+	mg.mu.Unlock()
+	slices.Sort(ids)
+
+	n := 0
+	var errs []error
+	for _, id := range ids {
+		unlock := mg.lock(id)
+		// the instance may have been deleted or received an event meanwhile
+		mg.mu.Lock()
+		inst, ok := mg.instances[id]
+		ok = ok && due(inst, life.Clock.Now())
+		mg.mu.Unlock()
+		if ok {
+			if err := life.Store.Save(ctx, inst.m.Snapshot()); err != nil {
+				errs = append(errs, fmt.Errorf("unable to save instance %q: %w", id, err))
+			} else {
+				mg.mu.Lock()
+				delete(mg.instances, id)
+				mg.mu.Unlock()
+				n++
+			}
+		}
+		unlock()
+	}
+	return n, errors.Join(errs...)
+}
+
+// EvictEvery calls Evict every interval until ctx is cancelled, errors are passed to onError if not nil.
+func (mg *Manager) EvictEvery(ctx context.Context, interval time.Duration, onError func(error)) error {
+	for {
+		mg.mu.Lock()
+		clock := mg.life.Clock
+		mg.mu.Unlock()
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-clock.After(interval):
+		}
+		if _, err := mg.Evict(ctx); err != nil && onError != nil {
+			onError(err)
+		}
+	}
+}
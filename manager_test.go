@@ -0,0 +1,234 @@
+package mova
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type orderEvent struct {
+	Order string
+	Count int
+}
+
+// newOrderMachine returns a machine counting events per order until it is closed.
+func newOrderMachine(t *testing.T) *CompiledMachine {
+	t.Helper()
+	var reg Registry
+	NewTrigger[orderEvent](&reg, "add")
+	NewTrigger[orderEvent](&reg, "close")
+	src := `
+	var total: int;
+	state open {
+		on add(Count) -> set total = Count;
+		on close -> move closed;
+	};
+	final state closed { };`
+	cm, err := BuildMachine("test.mova", strings.NewReader(src), &reg, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cm
+}
+
+// total returns the variable total of an instance of newOrderMachine.
+func total(m *StateMachine) int64 {
+	n, _ := m.Vars()["total"].(int64)
+	return n
+}
+
+func TestManager(t *testing.T) {
+	mg := NewManager(newOrderMachine(t))
+	ctx := context.Background()
+	if _, err := mg.Create("a"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := mg.Create("b"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := mg.Create("a"); !errors.Is(err, ErrInstanceExists) {
+		t.Fatalf("got error %v, want %v", err, ErrInstanceExists)
+	}
+	if err := mg.Emit(ctx, "a", "add", orderEvent{Count: 3}); err != nil {
+		t.Fatal(err)
+	}
+	if err := mg.Emit(ctx, "c", "add", orderEvent{}); !errors.Is(err, ErrUnknownInstance) {
+		t.Fatalf("got error %v, want %v", err, ErrUnknownInstance)
+	}
+	if m, ok := mg.Get("a"); !ok || total(m) != 3 {
+		t.Fatalf("got %v, %v", m, ok)
+	}
+	if ids := mg.IDs(); !slices.Equal(ids, []string{"a", "b"}) {
+		t.Fatalf("got %v", ids)
+	}
+	if !mg.Delete("a") || mg.Delete("a") {
+		t.Fatal("deleted a twice")
+	}
+	if _, ok := mg.Get("a"); ok {
+		t.Fatal("got deleted instance")
+	}
+}
+
+// TestManagerSerialize checks that concurrent events for an instance do not interleave.
+func TestManagerSerialize(t *testing.T) {
+	var reg Registry
+	NewTrigger[orderEvent](&reg, "add")
+	var mu sync.Mutex
+	running := map[string]int{}
+	NewAction(&reg, "work", []string{"id"}, func(id string) error {
+		mu.Lock()
+		running[id]++
+		n := running[id]
+		mu.Unlock()
+		time.Sleep(time.Millisecond)
+		mu.Lock()
+		running[id]--
+		mu.Unlock()
+		if n > 1 {
+			return errors.New("interleaved")
+		}
+		return nil
+	})
+	cm, err := BuildMachine("test.mova", strings.NewReader(`state a { on add -> work(id=self.id); };`), &reg, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mg := NewManager(cm)
+	mg.Create("a")
+	mg.Create("b")
+	var wg sync.WaitGroup
+	for i := range 20 {
+		wg.Go(func() {
+			if err := mg.Emit(context.Background(), []string{"a", "b"}[i%2], "add", orderEvent{}); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+	wg.Wait()
+}
+
+func TestManagerEvict(t *testing.T) {
+	clock := NewFakeClock(time.Unix(0, 0))
+	store := &MemoryStore{}
+	mg := NewManager(newOrderMachine(t))
+	mg.SetLifecycle(Lifecycle{Store: store, Idle: time.Minute, TTL: time.Hour, Clock: clock})
+	ctx := context.Background()
+	for _, id := range []string{"a", "b"} {
+		if _, err := mg.Create(id); err != nil {
+			t.Fatal(err)
+		}
+	}
+	mg.Emit(ctx, "a", "add", orderEvent{Count: 5})
+	clock.Advance(50 * time.Second)
+	mg.Emit(ctx, "b", "add", orderEvent{Count: 1})
+	clock.Advance(10 * time.Second)
+	if n, err := mg.Evict(ctx); n != 1 || err != nil {
+		t.Fatalf("evicted %d, %v, want 1", n, err)
+	}
+	if ids := mg.IDs(); !slices.Equal(ids, []string{"b"}) {
+		t.Fatalf("got %v in memory", ids)
+	}
+	if _, err := mg.Create("a"); !errors.Is(err, ErrInstanceExists) {
+		t.Fatalf("got error %v, want %v", err, ErrInstanceExists)
+	}
+	// restored on the next event
+	if err := mg.Emit(ctx, "a", "close", orderEvent{}); err != nil {
+		t.Fatal(err)
+	}
+	if m, _ := mg.Get("a"); m.Current() != "closed" || total(m) != 5 {
+		t.Fatalf("got %s with total %v", m.Current(), total(m))
+	}
+	// the TTL counts from the restore
+	clock.Advance(time.Hour - time.Second)
+	for _, id := range []string{"a", "b"} {
+		mg.Emit(ctx, id, "add", orderEvent{})
+	}
+	if n, _ := mg.Evict(ctx); n != 1 {
+		t.Fatalf("evicted %d, want b", n)
+	}
+	if !mg.Delete("b") {
+		t.Fatal("stored instance not deleted")
+	}
+	if _, err := store.Load(ctx, "b"); !errors.Is(err, ErrNotStored) {
+		t.Fatalf("got error %v, want %v", err, ErrNotStored)
+	}
+}
+
+// slowStore blocks loading the instance named slow until released.
+type slowStore struct {
+	MemoryStore
+	loading chan struct{}
+	release chan struct{}
+}
+
+func (s *slowStore) Load(ctx context.Context, id string) (Snapshot, error) {
+	if id == "slow" {
+		close(s.loading)
+		<-s.release
+	}
+	return s.MemoryStore.Load(ctx, id)
+}
+
+// TestManagerSlowStore checks that a slow store only holds up events for the instance it loads.
+func TestManagerSlowStore(t *testing.T) {
+	store := &slowStore{loading: make(chan struct{}), release: make(chan struct{})}
+	mg := NewManager(newOrderMachine(t))
+	mg.SetLifecycle(Lifecycle{Store: store})
+	ctx := context.Background()
+	if _, err := mg.Create("fast"); err != nil {
+		t.Fatal(err)
+	}
+	m, _ := mg.Get("fast")
+	store.Save(ctx, m.Snapshot())
+	done := make(chan error, 1)
+	go func() { done <- mg.Emit(ctx, "slow", "add", orderEvent{}) }()
+	<-store.loading
+	if err := mg.Emit(ctx, "fast", "add", orderEvent{Count: 1}); err != nil {
+		t.Fatal(err)
+	}
+	mg.IDs() // neither is the manager
+	close(store.release)
+	if err := <-done; !errors.Is(err, ErrUnknownInstance) {
+		t.Fatalf("got error %v, want %v", err, ErrUnknownInstance)
+	}
+}
+func TestRouter(t *testing.T) {
+	r := NewRouter(newOrderMachine(t), FieldKey("Order"))
+	r.SetLifecycle(Lifecycle{Store: &MemoryStore{}})
+	ctx := context.Background()
+	for _, ev := range []Event{
+		{Name: "add", Data: orderEvent{Order: "1", Count: 1}},
+		{Name: "add", Data: orderEvent{Order: "2", Count: 2}},
+		{Name: "add", Data: orderEvent{Order: "1", Count: 3}},
+	} {
+		if _, err := r.Route(ctx, ev); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if ids := r.IDs(); !slices.Equal(ids, []string{"1", "2"}) {
+		t.Fatalf("got %v", ids)
+	}
+	if m, _ := r.Get("1"); total(m) != 3 {
+		t.Fatalf("got total %v, want 3", total(m))
+	}
+	if _, err := r.Route(ctx, Event{Name: "add", Data: orderEvent{Count: 1}}); !errors.Is(err, ErrNoCorrelationKey) {
+		t.Fatalf("got error %v, want %v", err, ErrNoCorrelationKey)
+	}
+	// final instances are deleted, the next event starts over
+	if id, err := r.Route(ctx, Event{Name: "close", Data: orderEvent{Order: "1"}}); err != nil || id != "1" {
+		t.Fatalf("got %q, %v", id, err)
+	}
+	if ids := r.IDs(); !slices.Equal(ids, []string{"2"}) {
+		t.Fatalf("got %v", ids)
+	}
+	if _, err := r.Route(ctx, Event{Name: "add", Data: orderEvent{Order: "1"}}); err != nil {
+		t.Fatal(err)
+	}
+	if m, _ := r.Get("1"); m.Current() != "open" || total(m) != 0 {
+		t.Fatalf("got %s with total %v, want a new instance", m.Current(), total(m))
+	}
+}
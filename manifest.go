@@ -0,0 +1,238 @@
+package mova
+
+import (
+	"fmt"
+	"maps"
+	"reflect"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// Manifest describes the triggers, actions and types of a Registry, so tools such as mova-lsp
+// can check machine files without the Go code behind them. It encodes to JSON.
+type Manifest struct {
+	Triggers map[string][]ManifestField `json:"triggers"`
+	Actions  map[string]ManifestAction  `json:"actions"`
+	Types    []ManifestType             `json:"types,omitempty"`
+	Funcs    map[string]ManifestFunc    `json:"funcs,omitempty"`
+
+	Aliases       map[string]string `json:"aliases,omitempty"` // see NewTriggerAlias
+	ActionAliases map[string]string `json:"actionAliases,omitempty"`
+	Deprecated    []string          `json:"deprecated,omitempty"` // aliases registered by RegisterAlias
+	IgnoreCase    bool              `json:"ignoreCase,omitempty"`
+}
+
+// ManifestField is a piece of event-data or an action argument.
+type ManifestField struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+type ManifestAction struct {
+	Args  []ManifestField `json:"args"`
+	Async bool            `json:"async,omitempty"`
+}
+
+// ManifestFunc is a function registered with NewFunc, described by the types of its arguments and result.
+type ManifestFunc struct {
+	Args   []string `json:"args"`
+	Result string   `json:"result"`
+}
+
+type ManifestType struct {
+	Name    string `json:"name"`
+	Pattern string `json:"pattern,omitempty"`
+}
+
+// goTypes are the unnamed Go types which a manifest can restore.
+var goTypes = make(map[string]reflect.Type)
+
+func init() {
+	for _, t := range []reflect.Type{
+		reflect.TypeFor[int](), reflect.TypeFor[int8](), reflect.TypeFor[int16](), reflect.TypeFor[int32](), reflect.TypeFor[int64](),
+		reflect.TypeFor[uint](), reflect.TypeFor[uint8](), reflect.TypeFor[uint16](), reflect.TypeFor[uint32](), reflect.TypeFor[uint64](),
+		reflect.TypeFor[float32](), reflect.TypeFor[float64](), reflect.TypeFor[string](), reflect.TypeFor[bool](),
+		reflect.TypeFor[any](), reflect.TypeFor[map[string]any](),
+	} {
+		goTypes[t.String()] = t
+	}
+}
+
+// typeName returns the name of typ in a manifest, preferring mova type names.
+func (r *Registry) typeName(typ reflect.Type) string {
+	if t := r.typeFor(typ); t != nil {
+		return t.Name
+	}
+	for name, t := range typeNames {
+		if t == typ {
+			return name
+		}
+	}
+	return typ.String()
+}
+
+// Manifest describes the registry, excluding statements and tokens added by extensions.
+func (r *Registry) Manifest() *Manifest {
+	mf := &Manifest{
+		Triggers: make(map[string][]ManifestField),
+		Actions:  make(map[string]ManifestAction),
+
+		Aliases:       maps.Clone(r.aliases),
+		ActionAliases: maps.Clone(r.actionAliases),
+		Deprecated:    slices.Sorted(maps.Keys(r.deprecated)),
+		IgnoreCase:    r.ignoreCase,
+	}
+	for name, typ := range r.triggers {
+		fields := []ManifestField{}
+		for i := range typ.NumField() {
+			f := typ.Field(i)
+			if !f.IsExported() {
+				continue
+			}
+			fname := f.Name
+			if tag := f.Tag.Get("mova"); tag != "" {
+				fname = tag
+			}
+			fields = append(fields, ManifestField{fname, r.typeName(f.Type)})
+		}
+		mf.Triggers[name] = fields
+	}
+	for name, spec := range r.actions {
+		args := []ManifestField{}
+		for _, input := range spec.args() {
+			typ, _ := spec.arg(input)
+			args = append(args, ManifestField{input, r.typeName(typ)})
+		}
+		mf.Actions[name] = ManifestAction{Args: args, Async: spec.Async}
+	}
+	for name, fn := range r.funcs {
+		if mf.Funcs == nil {
+			mf.Funcs = make(map[string]ManifestFunc)
+		}
+		mfn := ManifestFunc{Args: []string{}, Result: r.typeName(fn.sig.Out(0))}
+		for i := range fn.sig.NumIn() {
+			mfn.Args = append(mfn.Args, r.typeName(fn.sig.In(i)))
+		}
+		mf.Funcs[name] = mfn
+	}
+	for _, t := range r.types {
+		mt := ManifestType{Name: t.Name}
+		if t.Pattern != nil {
+			mt.Pattern = strings.TrimSuffix(strings.TrimPrefix(t.Pattern.String(), "^("), ")")
+		}
+		mf.Types = append(mf.Types, mt)
+	}
+	return mf
+}
+
+// opaqueType stands in for a Go type of which only the name is known.
+func opaqueType(name string) reflect.Type {
+	return reflect.StructOf([]reflect.StructField{{Name: "Type", Type: reflect.TypeFor[string](), Tag: reflect.StructTag(fmt.Sprintf("mova:%q", name))}})
+}
+
+// Registry creates a registry from the manifest, suitable for type-checking machine files.
+// Actions do nothing and custom types parse every literal to a zero value.
+func (mf *Manifest) Registry() (*Registry, error) {
+	return mf.Stub(nil)
+}
+
+// Stub is like Registry, but actions report their arguments to call, if not nil.
+// Asynchronous actions complete with a zero result, and functions return a zero result.
+func (mf *Manifest) Stub(call func(action string, args map[string]any)) (*Registry, error) {
+	r := &Registry{
+		triggers: make(map[string]reflect.Type),
+		actions:  make(map[string]ActionSpec),
+
+		aliases:       maps.Clone(mf.Aliases),
+		actionAliases: maps.Clone(mf.ActionAliases),
+		ignoreCase:    mf.IgnoreCase,
+	}
+	for _, old := range mf.Deprecated {
+		if r.deprecated == nil {
+			r.deprecated = make(map[string]bool)
+		}
+		r.deprecated[old] = true
+	}
+	for _, mt := range mf.Types {
+		typ := opaqueType(mt.Name)
+		spec := &TypeSpec{
+			Name: mt.Name,
+			Type: typ,
+			Parse: func(string) (any, error) {
+				return reflect.Zero(typ).Interface(), nil
+			},
+		}
+		if mt.Pattern != "" {
+			pattern, err := regexp.Compile(`^(` + mt.Pattern + `)`)
+			if err != nil {
+				return nil, fmt.Errorf("invalid pattern for type %s: %w", mt.Name, err)
+			}
+			spec.Pattern = pattern
+		}
+		r.types = append(r.types, spec)
+	}
+	var resolve func(name string) reflect.Type
+	resolve = func(name string) reflect.Type {
+		if elem, ok := strings.CutPrefix(name, "*"); ok {
+			return reflect.PointerTo(resolve(elem))
+		}
+		if typ, ok := r.typeByName(name); ok {
+			return typ
+		}
+		if typ, ok := goTypes[name]; ok {
+			return typ
+		}
+		return opaqueType(name)
+	}
+	for name, mfields := range mf.Triggers {
+		var fields []reflect.StructField
+		for i, f := range mfields {
+			fields = append(fields, reflect.StructField{
+				Name: fmt.Sprintf("F%d", i),
+				Type: resolve(f.Type),
+				Tag:  reflect.StructTag(fmt.Sprintf("mova:%q", f.Name)),
+			})
+		}
+		r.triggers[name] = reflect.StructOf(fields)
+	}
+	for name, ma := range mf.Actions {
+		ins := make([]reflect.Type, len(ma.Args))
+		inputs := make([]string, len(ma.Args))
+		for i, arg := range ma.Args {
+			ins[i] = resolve(arg.Type)
+			inputs[i] = arg.Name
+		}
+		fn := reflect.MakeFunc(reflect.FuncOf(ins, nil, false), func(values []reflect.Value) []reflect.Value {
+			if call != nil {
+				args := make(map[string]any, len(values))
+				for i, v := range values {
+					args[inputs[i]] = v.Interface()
+				}
+				call(name, args)
+			}
+			return nil
+		})
+		r.actions[name] = ActionSpec{Inputs: inputs, Function: fn, Async: ma.Async}
+	}
+	for name, mfn := range mf.Funcs {
+		ins := make([]reflect.Type, len(mfn.Args))
+		for i, arg := range mfn.Args {
+			ins[i] = resolve(arg)
+		}
+		sig := reflect.FuncOf(ins, []reflect.Type{resolve(mfn.Result)}, false)
+		if r.funcs == nil {
+			r.funcs = make(map[string]*funcSpec)
+		}
+		r.funcs[name] = &funcSpec{
+			typ: func(args []reflect.Type) (reflect.Type, error) {
+				return signatureType(sig, args)
+			},
+			call: func(args []reflect.Value, result reflect.Type) (any, error) {
+				return reflect.Zero(result).Interface(), nil
+			},
+			sig: sig,
+		}
+	}
+	return r, nil
+}
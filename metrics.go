@@ -0,0 +1,28 @@
+package mova
+
+import "time"
+
+// Metrics receives measurements of a running machine, see WithMetrics.
+// Implementations must be safe for concurrent use, as one Metrics may be shared by many machines.
+type Metrics interface {
+	EventEmitted(event string)
+	EventHandled(state, event string)
+	EventUnhandled(state, event string)
+	Transition(from, to string)
+	ActionDuration(action string, d time.Duration, err error)
+}
+
+// WithMetrics reports the activity of the machine to mt.
+func WithMetrics(mt Metrics) InstanceOption {
+	return func(m *StateMachine) {
+		m.metrics = mt
+	}
+}
+
+type nopMetrics struct{}
+
+func (nopMetrics) EventEmitted(string)                         {}
+func (nopMetrics) EventHandled(string, string)                 {}
+func (nopMetrics) EventUnhandled(string, string)               {}
+func (nopMetrics) Transition(string, string)                   {}
+func (nopMetrics) ActionDuration(string, time.Duration, error) {}
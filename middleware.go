@@ -0,0 +1,74 @@
+package mova
+
+import (
+	"context"
+	"maps"
+	"slices"
+)
+
+// Middleware wraps every call of a registered action, e.g. for logging, metrics or checking
+// permissions. The input passed to the Action holds the variables visible to the call, and the
+// name of the action as `self.action`. Returning without calling next skips the action.
+// Asynchronous actions are wrapped while they are started, not until they complete.
+type Middleware func(next Action) Action
+
+// Use adds mw to the middleware of the instance. The middleware added first is the outermost.
+func (m *StateMachine) Use(mw Middleware) {
+	m.middleware = append(m.middleware, mw)
+}
+
+// WithMiddleware adds middleware before the machine enters its initial state, see Use.
+func WithMiddleware(mw ...Middleware) InstanceOption {
+	return func(m *StateMachine) {
+		m.middleware = append(m.middleware, mw...)
+	}
+}
+
+// around runs the action named action through the middleware of the instance.
+func (m *StateMachine) around(action string, ctx map[string]Value, next Action) error {
+	if len(m.middleware) == 0 {
+		return next(m, ctx)
+	}
+	for _, mw := range slices.Backward(m.middleware) {
+		next = mw(next)
+	}
+	input := maps.Clone(ctx)
+	input["self.action"] = &ConstValue{action}
+	return next(m, input)
+}
+
+// DispatchFunc handles a single event, see DispatchMiddleware.
+type DispatchFunc func(ctx context.Context, m *StateMachine, name string, v any) error
+
+// DispatchMiddleware wraps the handling of every event, including queued ones, from matching the
+// triggers until the actions ran and the machine moved, e.g. for deduplication, tracing or
+// persisting snapshots. Returning without calling next drops the event. Events emitted from
+// within are queued until the current event is handled.
+type DispatchMiddleware func(next DispatchFunc) DispatchFunc
+
+// UseDispatch adds mw to the dispatch middleware of the instance. The middleware added first is
+// the outermost.
+func (m *StateMachine) UseDispatch(mw DispatchMiddleware) {
+	m.dispatchers = append(m.dispatchers, mw)
+}
+
+// WithDispatchMiddleware adds dispatch middleware when creating the instance, see UseDispatch.
+func WithDispatchMiddleware(mw ...DispatchMiddleware) InstanceOption {
+	return func(m *StateMachine) {
+		m.dispatchers = append(m.dispatchers, mw...)
+	}
+}
+
+// handle dispatches an event through the dispatch middleware of the instance.
+func (m *StateMachine) handle(ctx context.Context, name string, v any) error {
+	if len(m.dispatchers) == 0 {
+		return m.dispatch(ctx, name, v)
+	}
+	next := DispatchFunc(func(ctx context.Context, m *StateMachine, name string, v any) error {
+		return m.dispatch(ctx, name, v)
+	})
+	for _, mw := range slices.Backward(m.dispatchers) {
+		next = mw(next)
+	}
+	return next(ctx, m, name, v)
+}
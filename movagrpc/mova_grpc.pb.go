@@ -0,0 +1,283 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.6.0
+// - protoc             (unknown)
+// source: mova.proto
+
+package movagrpc
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	Machines_CreateInstance_FullMethodName   = "/mova.v1.Machines/CreateInstance"
+	Machines_DeleteInstance_FullMethodName   = "/mova.v1.Machines/DeleteInstance"
+	Machines_GetState_FullMethodName         = "/mova.v1.Machines/GetState"
+	Machines_Emit_FullMethodName             = "/mova.v1.Machines/Emit"
+	Machines_WatchTransitions_FullMethodName = "/mova.v1.Machines/WatchTransitions"
+)
+
+// MachinesClient is the client API for Machines service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// Machines drives the instances of a single machine, like the movahttp package.
+type MachinesClient interface {
+	CreateInstance(ctx context.Context, in *CreateInstanceRequest, opts ...grpc.CallOption) (*Instance, error)
+	DeleteInstance(ctx context.Context, in *DeleteInstanceRequest, opts ...grpc.CallOption) (*DeleteInstanceResponse, error)
+	GetState(ctx context.Context, in *GetStateRequest, opts ...grpc.CallOption) (*Instance, error)
+	Emit(ctx context.Context, in *EmitRequest, opts ...grpc.CallOption) (*EmitResponse, error)
+	// WatchTransitions streams the transitions of an instance until it is deleted or the call is cancelled.
+	WatchTransitions(ctx context.Context, in *WatchTransitionsRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Transition], error)
+}
+
+type machinesClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewMachinesClient(cc grpc.ClientConnInterface) MachinesClient {
+	return &machinesClient{cc}
+}
+
+func (c *machinesClient) CreateInstance(ctx context.Context, in *CreateInstanceRequest, opts ...grpc.CallOption) (*Instance, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Instance)
+	err := c.cc.Invoke(ctx, Machines_CreateInstance_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *machinesClient) DeleteInstance(ctx context.Context, in *DeleteInstanceRequest, opts ...grpc.CallOption) (*DeleteInstanceResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(DeleteInstanceResponse)
+	err := c.cc.Invoke(ctx, Machines_DeleteInstance_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *machinesClient) GetState(ctx context.Context, in *GetStateRequest, opts ...grpc.CallOption) (*Instance, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Instance)
+	err := c.cc.Invoke(ctx, Machines_GetState_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *machinesClient) Emit(ctx context.Context, in *EmitRequest, opts ...grpc.CallOption) (*EmitResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(EmitResponse)
+	err := c.cc.Invoke(ctx, Machines_Emit_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *machinesClient) WatchTransitions(ctx context.Context, in *WatchTransitionsRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Transition], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &Machines_ServiceDesc.Streams[0], Machines_WatchTransitions_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[WatchTransitionsRequest, Transition]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Machines_WatchTransitionsClient = grpc.ServerStreamingClient[Transition]
+
+// MachinesServer is the server API for Machines service.
+// All implementations must embed UnimplementedMachinesServer
+// for forward compatibility.
+//
+// Machines drives the instances of a single machine, like the movahttp package.
+type MachinesServer interface {
+	CreateInstance(context.Context, *CreateInstanceRequest) (*Instance, error)
+	DeleteInstance(context.Context, *DeleteInstanceRequest) (*DeleteInstanceResponse, error)
+	GetState(context.Context, *GetStateRequest) (*Instance, error)
+	Emit(context.Context, *EmitRequest) (*EmitResponse, error)
+	// WatchTransitions streams the transitions of an instance until it is deleted or the call is cancelled.
+	WatchTransitions(*WatchTransitionsRequest, grpc.ServerStreamingServer[Transition]) error
+	mustEmbedUnimplementedMachinesServer()
+}
+
+// UnimplementedMachinesServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedMachinesServer struct{}
+
+func (UnimplementedMachinesServer) CreateInstance(context.Context, *CreateInstanceRequest) (*Instance, error) {
+	return nil, status.Error(codes.Unimplemented, "method CreateInstance not implemented")
+}
+func (UnimplementedMachinesServer) DeleteInstance(context.Context, *DeleteInstanceRequest) (*DeleteInstanceResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method DeleteInstance not implemented")
+}
+func (UnimplementedMachinesServer) GetState(context.Context, *GetStateRequest) (*Instance, error) {
+	return nil, status.Error(codes.Unimplemented, "method GetState not implemented")
+}
+func (UnimplementedMachinesServer) Emit(context.Context, *EmitRequest) (*EmitResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method Emit not implemented")
+}
+func (UnimplementedMachinesServer) WatchTransitions(*WatchTransitionsRequest, grpc.ServerStreamingServer[Transition]) error {
+	return status.Error(codes.Unimplemented, "method WatchTransitions not implemented")
+}
+func (UnimplementedMachinesServer) mustEmbedUnimplementedMachinesServer() {}
+func (UnimplementedMachinesServer) testEmbeddedByValue()                  {}
+
+// UnsafeMachinesServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to MachinesServer will
+// result in compilation errors.
+type UnsafeMachinesServer interface {
+	mustEmbedUnimplementedMachinesServer()
+}
+
+func RegisterMachinesServer(s grpc.ServiceRegistrar, srv MachinesServer) {
+	// If the following call panics, it indicates UnimplementedMachinesServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&Machines_ServiceDesc, srv)
+}
+
+func _Machines_CreateInstance_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreateInstanceRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MachinesServer).CreateInstance(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Machines_CreateInstance_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MachinesServer).CreateInstance(ctx, req.(*CreateInstanceRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Machines_DeleteInstance_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteInstanceRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MachinesServer).DeleteInstance(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Machines_DeleteInstance_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MachinesServer).DeleteInstance(ctx, req.(*DeleteInstanceRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Machines_GetState_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetStateRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MachinesServer).GetState(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Machines_GetState_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MachinesServer).GetState(ctx, req.(*GetStateRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Machines_Emit_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(EmitRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MachinesServer).Emit(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Machines_Emit_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MachinesServer).Emit(ctx, req.(*EmitRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Machines_WatchTransitions_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchTransitionsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(MachinesServer).WatchTransitions(m, &grpc.GenericServerStream[WatchTransitionsRequest, Transition]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Machines_WatchTransitionsServer = grpc.ServerStreamingServer[Transition]
+
+// Machines_ServiceDesc is the grpc.ServiceDesc for Machines service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Machines_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "mova.v1.Machines",
+	HandlerType: (*MachinesServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "CreateInstance",
+			Handler:    _Machines_CreateInstance_Handler,
+		},
+		{
+			MethodName: "DeleteInstance",
+			Handler:    _Machines_DeleteInstance_Handler,
+		},
+		{
+			MethodName: "GetState",
+			Handler:    _Machines_GetState_Handler,
+		},
+		{
+			MethodName: "Emit",
+			Handler:    _Machines_Emit_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "WatchTransitions",
+			Handler:       _Machines_WatchTransitions_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "mova.proto",
+}
@@ -0,0 +1,161 @@
+// Package movagrpc exposes the instances of a mova.Manager as gRPC service.
+// The service is defined in mova.proto, MachinesClient is the matching client.
+package movagrpc
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/friedelschoen/mova"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// WatchBuffer is the number of transitions buffered per watcher, further transitions are dropped
+// while a watcher falls behind.
+const WatchBuffer = 64
+
+// Server implements MachinesServer. Transitions can only be watched on instances created through the server.
+type Server struct {
+	UnimplementedMachinesServer
+
+	mgr *mova.Manager
+
+	mu       sync.Mutex
+	watchers map[string]map[chan *Transition]struct{}
+}
+
+var _ MachinesServer = (*Server)(nil)
+
+func NewServer(mgr *mova.Manager) *Server {
+	return &Server{
+		mgr:      mgr,
+		watchers: make(map[string]map[chan *Transition]struct{}),
+	}
+}
+
+func (s *Server) notify(m *mova.StateMachine, from, to string) {
+	tr := &Transition{Id: m.ID, From: from, To: to, Time: timestamppb.New(time.Now())}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for ch := range s.watchers[m.ID] {
+		select {
+		case ch <- tr:
+		default:
+		}
+	}
+}
+
+func (s *Server) instance(id string) (*mova.StateMachine, error) {
+	m, ok := s.mgr.Get(id)
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "unknown instance %q", id)
+	}
+	return m, nil
+}
+
+func (s *Server) CreateInstance(ctx context.Context, req *CreateInstanceRequest) (*Instance, error) {
+	id := req.GetId()
+	if id == "" {
+		var buf [8]byte
+		rand.Read(buf[:])
+		id = hex.EncodeToString(buf[:])
+	}
+	m, err := s.mgr.Create(id, mova.WithMeta(req.GetMeta().AsMap()), mova.WithTransitionHook(s.notify))
+	if errors.Is(err, mova.ErrInstanceExists) {
+		return nil, status.Error(codes.AlreadyExists, err.Error())
+	}
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return &Instance{Id: m.ID, State: m.Current()}, nil
+}
+
+func (s *Server) DeleteInstance(ctx context.Context, req *DeleteInstanceRequest) (*DeleteInstanceResponse, error) {
+	if !s.mgr.Delete(req.GetId()) {
+		return nil, status.Errorf(codes.NotFound, "unknown instance %q", req.GetId())
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for ch := range s.watchers[req.GetId()] {
+		close(ch)
+	}
+	delete(s.watchers, req.GetId())
+	return &DeleteInstanceResponse{}, nil
+}
+
+func (s *Server) GetState(ctx context.Context, req *GetStateRequest) (*Instance, error) {
+	m, err := s.instance(req.GetId())
+	if err != nil {
+		return nil, err
+	}
+	return &Instance{Id: m.ID, State: m.Current()}, nil
+}
+
+func (s *Server) Emit(ctx context.Context, req *EmitRequest) (*EmitResponse, error) {
+	m, err := s.instance(req.GetId())
+	if err != nil {
+		return nil, err
+	}
+	var data []byte
+	if req.GetData() != nil {
+		if data, err = protojson.Marshal(req.GetData()); err != nil {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+	}
+	v, err := s.mgr.Machine().Registry().Decode(req.GetEvent(), data)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	err = s.mgr.Emit(ctx, req.GetId(), req.GetEvent(), v)
+	switch {
+	case errors.Is(err, io.EOF):
+		return &EmitResponse{Handled: false, State: m.Current()}, nil
+	case errors.Is(err, mova.ErrUnknownInstance):
+		return nil, status.Error(codes.NotFound, err.Error())
+	case err != nil:
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return &EmitResponse{Handled: true, State: m.Current()}, nil
+}
+
+func (s *Server) WatchTransitions(req *WatchTransitionsRequest, stream Machines_WatchTransitionsServer) error {
+	if _, err := s.instance(req.GetId()); err != nil {
+		return err
+	}
+	ch := make(chan *Transition, WatchBuffer)
+	s.mu.Lock()
+	if s.watchers[req.GetId()] == nil {
+		s.watchers[req.GetId()] = make(map[chan *Transition]struct{})
+	}
+	s.watchers[req.GetId()][ch] = struct{}{}
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if _, ok := s.watchers[req.GetId()][ch]; ok {
+			delete(s.watchers[req.GetId()], ch)
+		}
+	}()
+
+	for {
+		select {
+		case <-stream.Context().Done():
+			return stream.Context().Err()
+		case tr, ok := <-ch:
+			if !ok {
+				return nil // instance deleted
+			}
+			if err := stream.Send(tr); err != nil {
+				return err
+			}
+		}
+	}
+}
@@ -0,0 +1,247 @@
+// Package movahttp exposes the instances of a mova.Manager over HTTP.
+//
+//	POST   /instances              create an instance, body {"id": "...", "meta": {...}}, both optional
+//	GET    /instances              list instance ids
+//	GET    /instances/{id}         current state and transition history
+//	POST   /instances/{id}/events  emit an event, body {"event": "...", "data": {...}}
+//	DELETE /instances/{id}         remove an instance
+//
+// All responses are JSON, errors are returned as {"error": "..."}.
+package movahttp
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/friedelschoen/mova"
+)
+
+// MaxBodySize limits the size of request bodies.
+const MaxBodySize = 1 << 20
+
+type Transition struct {
+	From string    `json:"from,omitempty"`
+	To   string    `json:"to"`
+	Time time.Time `json:"time"`
+}
+
+type Instance struct {
+	ID      string         `json:"id"`
+	State   string         `json:"state"`
+	Meta    map[string]any `json:"meta,omitempty"`
+	History []Transition   `json:"history,omitempty"`
+}
+
+type Server struct {
+	MaxHistory int // maximum number of transitions kept per instance, unlimited if zero
+
+	mgr *mova.Manager
+	mux *http.ServeMux
+
+	mu      sync.Mutex
+	history map[string][]Transition
+}
+
+func New(mgr *mova.Manager) *Server {
+	s := &Server{
+		mgr:     mgr,
+		mux:     http.NewServeMux(),
+		history: make(map[string][]Transition),
+	}
+	s.mux.HandleFunc("POST /instances", s.create)
+	s.mux.HandleFunc("GET /instances", s.list)
+	s.mux.HandleFunc("GET /instances/{id}", s.get)
+	s.mux.HandleFunc("POST /instances/{id}/events", s.emit)
+	s.mux.HandleFunc("DELETE /instances/{id}", s.delete)
+	s.mux.HandleFunc("GET /tasks", s.tasks)
+	s.mux.HandleFunc("POST /instances/{id}/tasks/{task}", s.complete)
+	return s
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	var derr *mova.DecodeError
+	if !errors.As(err, &derr) {
+		writeJSON(w, status, map[string]string{"error": err.Error()})
+		return
+	}
+	fields := make(map[string]string)
+	for _, f := range derr.Fields {
+		fields[f.Path] = f.Err.Error()
+	}
+	writeJSON(w, status, map[string]any{"error": err.Error(), "fields": fields})
+}
+
+func readJSON(w http.ResponseWriter, r *http.Request, v any) error {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, MaxBodySize))
+	if err != nil {
+		return err
+	}
+	if len(body) == 0 {
+		return nil
+	}
+	return json.Unmarshal(body, v)
+}
+
+func (s *Server) record(m *mova.StateMachine, from, to string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	hist := append(s.history[m.ID], Transition{From: from, To: to, Time: time.Now()})
+	if s.MaxHistory > 0 && len(hist) > s.MaxHistory {
+		hist = hist[len(hist)-s.MaxHistory:]
+	}
+	s.history[m.ID] = hist
+}
+
+func (s *Server) describe(m *mova.StateMachine) Instance {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return Instance{
+		ID:      m.ID,
+		State:   m.Current(),
+		Meta:    m.Meta,
+		History: append([]Transition(nil), s.history[m.ID]...),
+	}
+}
+
+func (s *Server) create(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		ID   string         `json:"id"`
+		Meta map[string]any `json:"meta"`
+	}
+	if err := readJSON(w, r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if req.ID == "" {
+		var buf [8]byte
+		rand.Read(buf[:])
+		req.ID = hex.EncodeToString(buf[:])
+	}
+	m, err := s.mgr.Create(req.ID, mova.WithMeta(req.Meta), mova.WithTransitionHook(s.record))
+	switch {
+	case errors.Is(err, mova.ErrInstanceExists):
+		writeError(w, http.StatusConflict, err)
+	case err != nil:
+		writeError(w, http.StatusInternalServerError, err)
+	default:
+		writeJSON(w, http.StatusCreated, s.describe(m))
+	}
+}
+
+func (s *Server) list(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.mgr.IDs())
+}
+
+func (s *Server) get(w http.ResponseWriter, r *http.Request) {
+	m, ok := s.mgr.Get(r.PathValue("id"))
+	if !ok {
+		writeError(w, http.StatusNotFound, mova.ErrUnknownInstance)
+		return
+	}
+	writeJSON(w, http.StatusOK, s.describe(m))
+}
+
+func (s *Server) emit(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	m, ok := s.mgr.Get(id)
+	if !ok {
+		writeError(w, http.StatusNotFound, mova.ErrUnknownInstance)
+		return
+	}
+	var req struct {
+		ID    string          `json:"id"`
+		Event string          `json:"event"`
+		Data  json.RawMessage `json:"data"`
+	}
+	if err := readJSON(w, r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	v, err := s.mgr.Machine().Registry().Decode(req.Event, req.Data)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	err = s.mgr.EmitEvent(r.Context(), id, mova.Event{ID: req.ID, Name: req.Event, Data: v})
+	switch {
+	case errors.Is(err, mova.ErrDuplicateEvent):
+		// already handled, retries of the request succeed
+		writeJSON(w, http.StatusOK, s.describe(m))
+	case errors.Is(err, io.EOF):
+		writeError(w, http.StatusConflict, errors.New("event not handled in current state"))
+	case errors.Is(err, mova.ErrUnknownInstance):
+		writeError(w, http.StatusNotFound, err)
+	case err != nil:
+		writeError(w, http.StatusInternalServerError, err)
+	default:
+		writeJSON(w, http.StatusOK, s.describe(m))
+	}
+}
+
+func (s *Server) tasks(w http.ResponseWriter, r *http.Request) {
+	tasks := s.mgr.Tasks()
+	if tasks == nil {
+		tasks = []mova.Task{}
+	}
+	writeJSON(w, http.StatusOK, tasks)
+}
+
+func (s *Server) complete(w http.ResponseWriter, r *http.Request) {
+	id, task := r.PathValue("id"), r.PathValue("task")
+	m, ok := s.mgr.Get(id)
+	if !ok {
+		writeError(w, http.StatusNotFound, mova.ErrUnknownInstance)
+		return
+	}
+	var data json.RawMessage
+	if err := readJSON(w, r, &data); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	v, err := s.mgr.Machine().Registry().Decode(task, data)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	err = s.mgr.CompleteTask(r.Context(), id, task, v)
+	switch {
+	case errors.Is(err, io.EOF):
+		writeError(w, http.StatusConflict, errors.New("event not handled in current state"))
+	case errors.Is(err, mova.ErrNoTask):
+		writeError(w, http.StatusConflict, err)
+	case errors.Is(err, mova.ErrUnknownInstance):
+		writeError(w, http.StatusNotFound, err)
+	case err != nil:
+		writeError(w, http.StatusInternalServerError, err)
+	default:
+		writeJSON(w, http.StatusOK, s.describe(m))
+	}
+}
+
+func (s *Server) delete(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if !s.mgr.Delete(id) {
+		writeError(w, http.StatusNotFound, mova.ErrUnknownInstance)
+		return
+	}
+	s.mu.Lock()
+	delete(s.history, id)
+	s.mu.Unlock()
+	w.WriteHeader(http.StatusNoContent)
+}
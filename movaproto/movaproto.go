@@ -0,0 +1,25 @@
+// Package movaproto uses protobuf messages as event-data of mova triggers.
+package movaproto
+
+import (
+	"github.com/friedelschoen/mova"
+	"google.golang.org/protobuf/proto"
+)
+
+// NewTriggerProto registers the trigger name with event-data of the generated message type T,
+// such as *pb.OrderPlaced. The event-data is decoded from the protobuf wire format by
+// Registry.DecodeBytes and StateMachine.EmitBytes. Fields are named by their Go or protobuf name
+// in machine files, e.g. OrderId or order_id.
+func NewTriggerProto[T proto.Message](r *mova.Registry, name string) {
+	mova.NewTriggerDecoder(r, name, Unmarshal[T])
+}
+
+// Unmarshal decodes a message of type T from the protobuf wire format.
+func Unmarshal[T proto.Message](data []byte) (T, error) {
+	var zero T
+	msg := zero.ProtoReflect().New().Interface().(T)
+	if err := proto.Unmarshal(data, msg); err != nil {
+		return zero, err
+	}
+	return msg, nil
+}
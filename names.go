@@ -0,0 +1,93 @@
+package mova
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// IgnoreCase makes the names of triggers and actions match regardless of case, in machine files
+// as well as in Emit. Exact matches win over others, then the first registered name in sorted order.
+func (r *Registry) IgnoreCase() {
+	r.ignoreCase = true
+}
+
+// NewTriggerAlias makes alias another name for the trigger name, in machine files as well as in
+// Emit. Events arrive under the registered name, whichever name was used.
+func NewTriggerAlias(r *Registry, alias, name string) {
+	if _, ok := r.triggers[name]; !ok {
+		panic(fmt.Errorf("alias %q of unspecified trigger %q", alias, name))
+	}
+	if _, ok := r.triggers[alias]; ok {
+		panic(fmt.Errorf("alias %q is a trigger itself", alias))
+	}
+	if r.aliases == nil {
+		r.aliases = make(map[string]string)
+	}
+	r.aliases[alias] = name
+}
+
+// RegisterAlias makes old a deprecated name of the trigger or action name. Machine files using it
+// keep compiling, with a warning, see CompiledMachine.Warnings.
+func RegisterAlias(r *Registry, old, name string) {
+	if _, ok := r.triggers[name]; ok {
+		NewTriggerAlias(r, old, name)
+	} else if _, ok := r.actions[name]; ok {
+		if _, ok := r.actions[old]; ok {
+			panic(fmt.Errorf("alias %q is an action itself", old))
+		}
+		if r.actionAliases == nil {
+			r.actionAliases = make(map[string]string)
+		}
+		r.actionAliases[old] = name
+	} else {
+		panic(fmt.Errorf("alias %q of unspecified trigger or action %q", old, name))
+	}
+	if r.deprecated == nil {
+		r.deprecated = make(map[string]bool)
+	}
+	r.deprecated[old] = true
+}
+
+// resolve returns the key of names matching name, looking into aliases and ignoring case if enabled.
+func resolve[T any](r *Registry, names map[string]T, aliases map[string]string, name string) (string, bool) {
+	if _, ok := names[name]; ok {
+		return name, true
+	}
+	if target, ok := aliases[name]; ok {
+		return target, true
+	}
+	if r.ignoreCase {
+		for _, key := range slices.Sorted(maps.Keys(names)) {
+			if strings.EqualFold(key, name) {
+				return key, true
+			}
+		}
+		for _, alias := range slices.Sorted(maps.Keys(aliases)) {
+			if strings.EqualFold(alias, name) {
+				return aliases[alias], true
+			}
+		}
+	}
+	return "", false
+}
+
+// triggerName returns the registered name of trigger name.
+func (r *Registry) triggerName(name string) (string, bool) {
+	if _, ok := builtinTriggers[name]; ok {
+		return name, true
+	}
+	return resolve(r, r.triggers, r.aliases, name)
+}
+
+// actionName returns the registered name of action name.
+func (r *Registry) actionName(name string) (string, bool) {
+	return resolve(r, r.actions, r.actionAliases, name)
+}
+
+// action returns the action registered as name.
+func (r *Registry) action(name string) (ActionSpec, bool) {
+	name, ok := r.actionName(name)
+	return r.actions[name], ok
+}
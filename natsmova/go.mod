@@ -0,0 +1,25 @@
+module github.com/friedelschoen/mova/natsmova
+
+go 1.25.3
+
+replace github.com/friedelschoen/mova => ../
+
+require (
+	github.com/friedelschoen/mova v0.0.0-00010101000000-000000000000
+	github.com/nats-io/nats-server/v2 v2.12.0
+	github.com/nats-io/nats.go v1.53.1
+)
+
+require (
+	github.com/antithesishq/antithesis-sdk-go v0.4.3-default-no-op // indirect
+	github.com/coder/websocket v1.8.15 // indirect
+	github.com/google/go-tpm v0.9.5 // indirect
+	github.com/klauspost/compress v1.18.5 // indirect
+	github.com/minio/highwayhash v1.0.3 // indirect
+	github.com/nats-io/jwt/v2 v2.8.0 // indirect
+	github.com/nats-io/nkeys v0.4.15 // indirect
+	github.com/nats-io/nuid v1.0.1 // indirect
+	golang.org/x/crypto v0.49.0 // indirect
+	golang.org/x/sys v0.47.0 // indirect
+	golang.org/x/time v0.13.0 // indirect
+)
@@ -0,0 +1,52 @@
+// Package natsmova consumes NATS messages as events of mova machines, see adapters.Consumer. It is
+// a module of its own, so only programs using it depend on the NATS client.
+package natsmova
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/friedelschoen/mova/adapters"
+	"github.com/nats-io/nats.go"
+)
+
+// Source receives the messages of a synchronous NATS subscription.
+type Source struct {
+	sub *nats.Subscription
+}
+
+var _ adapters.Source = (*Source)(nil)
+
+// NewSource returns a Source for sub, created by SubscribeSync or QueueSubscribeSync, or the
+// equivalent subscriptions of JetStream. Messages of JetStream are acknowledged once their event
+// was handled, and negatively acknowledged for a redelivery if it failed. Core NATS does not
+// redeliver, its messages are not acknowledged.
+func NewSource(sub *nats.Subscription) *Source {
+	return &Source{sub: sub}
+}
+
+// Receive waits for the next message. It returns io.EOF once the subscription or its connection
+// is closed.
+func (s *Source) Receive(ctx context.Context) (adapters.Message, error) {
+	msg, err := s.sub.NextMsgWithContext(ctx)
+	if errors.Is(err, nats.ErrBadSubscription) || errors.Is(err, nats.ErrConnectionClosed) {
+		return adapters.Message{}, io.EOF
+	} else if err != nil {
+		return adapters.Message{}, err
+	}
+	out := adapters.Message{
+		ID:      msg.Header.Get(nats.MsgIdHdr),
+		Subject: msg.Subject,
+		Data:    msg.Data,
+	}
+	if meta, err := msg.Metadata(); err == nil {
+		if out.ID == "" {
+			out.ID = fmt.Sprintf("%s/%d", meta.Stream, meta.Sequence.Stream)
+		}
+		out.Ack = func() error { return msg.Ack() }
+		out.Nack = func() error { return msg.Nak() }
+	}
+	return out, nil
+}
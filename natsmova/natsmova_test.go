@@ -0,0 +1,110 @@
+package natsmova
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/friedelschoen/mova"
+	"github.com/friedelschoen/mova/adapters"
+	"github.com/nats-io/nats-server/v2/server"
+	"github.com/nats-io/nats.go"
+)
+
+type Paid struct {
+	Order  string `mova:"order"`
+	Amount int    `mova:"amount"`
+}
+
+func TestJetStream(t *testing.T) {
+	srv, err := server.NewServer(&server.Options{Port: -1, JetStream: true, StoreDir: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv.Start()
+	defer srv.Shutdown()
+	if !srv.ReadyForConnections(5 * time.Second) {
+		t.Fatal("server not ready")
+	}
+	nc, err := nats.Connect(srv.ClientURL())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer nc.Close()
+	js, err := nc.JetStream()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := js.AddStream(&nats.StreamConfig{Name: "ORDERS", Subjects: []string{"paid"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	var reg mova.Registry
+	mova.NewTrigger[Paid](&reg, "paid")
+	var (
+		mu     sync.Mutex
+		failed bool
+		booked = make(map[string]int)
+		done   = make(chan struct{})
+	)
+	mova.NewAction(&reg, "book", []string{"order", "amount"}, func(order string, amount int) error {
+		mu.Lock()
+		defer mu.Unlock()
+		if order == "2" && !failed {
+			failed = true // the first delivery fails
+			return errors.New("unavailable")
+		}
+		booked[order] += amount
+		if len(booked) == 2 {
+			close(done)
+		}
+		return nil
+	})
+	cm, err := mova.BuildMachine("orders.mova", strings.NewReader(`state open { on paid(order, amount) -> book(order=order, amount=amount); };`), &reg, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	router := mova.NewRouter(cm, mova.FieldKey("Order"), mova.WithDedup(&mova.MemoryDedup{}, time.Hour))
+
+	for _, data := range []string{`{"order": "1", "amount": 3}`, `{"order": "2", "amount": 5}`} {
+		if _, err := js.Publish("paid", []byte(data)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	sub, err := js.SubscribeSync("paid", nats.AckExplicit())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var errs []error
+	consumer := &adapters.Consumer{
+		Source:  NewSource(sub),
+		Router:  router,
+		Decoder: adapters.DecodeSubject,
+		OnError: func(_ adapters.Message, err error) { errs = append(errs, err) },
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	go func() {
+		select {
+		case <-done:
+			sub.Unsubscribe()
+		case <-ctx.Done():
+		}
+	}()
+	if err := consumer.Run(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if booked["1"] != 3 || booked["2"] != 5 {
+		t.Fatalf("booked %v", booked)
+	}
+	if len(errs) != 1 {
+		t.Fatalf("got errors %v, want the failed delivery", errs)
+	}
+	info, err := sub.ConsumerInfo()
+	if err == nil && info.NumAckPending != 0 {
+		t.Fatalf("%d messages not acknowledged", info.NumAckPending)
+	}
+}
@@ -0,0 +1,42 @@
+package mova
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+)
+
+// ErrorEvent is the event-data of the builtin `error` trigger, which fires when an action
+// of a trigger in the current state failed.
+type ErrorEvent struct {
+	Message string `mova:"message"` // error message
+	Type    string `mova:"type"`    // Go type of the innermost error
+	Event   string `mova:"event"`   // event which was being handled
+}
+
+// handleError routes err, raised while handling event name in state, to the `error` trigger of state.
+// err is returned unchanged if the state does not handle errors.
+func (m *StateMachine) handleError(state *CompiledState, name string, err error) error {
+	inner := err
+	for {
+		next := errors.Unwrap(inner)
+		if next == nil {
+			break
+		}
+		inner = next
+	}
+	ev := ErrorEvent{
+		Message: err.Error(),
+		Type:    fmt.Sprintf("%T", inner),
+		Event:   name,
+	}
+	herr := m.fire(state, "error", reflect.ValueOf(ev))
+	if errors.Is(herr, io.EOF) {
+		return err
+	}
+	if herr != nil {
+		return errors.Join(err, fmt.Errorf("in error handler: %w", herr))
+	}
+	return nil
+}
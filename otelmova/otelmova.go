@@ -0,0 +1,56 @@
+// Package otelmova reports the activity of mova machines to OpenTelemetry.
+package otelmova
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/friedelschoen/mova"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const instrumentationName = "github.com/friedelschoen/mova"
+
+// Tracer implements mova.Tracer using an OpenTelemetry tracer.
+type Tracer struct {
+	tracer trace.Tracer
+}
+
+var _ mova.Tracer = (*Tracer)(nil)
+
+// New returns a Tracer creating spans with tp, or the global TracerProvider if tp is nil.
+func New(tp trace.TracerProvider) *Tracer {
+	if tp == nil {
+		tp = otel.GetTracerProvider()
+	}
+	return &Tracer{tracer: tp.Tracer(instrumentationName)}
+}
+
+func (t *Tracer) Start(ctx context.Context, name string, attrs map[string]any) (context.Context, func(error)) {
+	kvs := make([]attribute.KeyValue, 0, len(attrs))
+	for key, value := range attrs {
+		switch v := value.(type) {
+		case string:
+			kvs = append(kvs, attribute.String(key, v))
+		case int:
+			kvs = append(kvs, attribute.Int(key, v))
+		case int64:
+			kvs = append(kvs, attribute.Int64(key, v))
+		case bool:
+			kvs = append(kvs, attribute.Bool(key, v))
+		default:
+			kvs = append(kvs, attribute.String(key, fmt.Sprint(v)))
+		}
+	}
+	ctx, span := t.tracer.Start(ctx, name, trace.WithAttributes(kvs...))
+	return ctx, func(err error) {
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+	}
+}
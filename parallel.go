@@ -0,0 +1,82 @@
+package mova
+
+import (
+	"fmt"
+	"maps"
+	"sync"
+)
+
+// ParallelStmt runs calls concurrently and waits for all of them, written as
+//
+//	parallel { notify(user=id), audit(kind="login"), profile = lookup(user=id) }
+//
+// Only calls and captures may run in parallel, their results are visible after the group. If calls
+// fail, the error which occurred first is returned once all calls returned.
+type ParallelStmt struct {
+	Span    Span
+	Actions []Statement
+}
+
+func (ps *ParallelStmt) CheckType(ctx map[string]Value, m *CompiledMachine) error {
+	captured := make(map[string]Value)
+	for _, stmt := range ps.Actions {
+		cs, ok := stmt.(*CaptureStmt)
+		if _, call := stmt.(*Call); !ok && !call {
+			return located(statementSpan(stmt, ps.Span), fmt.Errorf("cannot run %s in parallel, only calls", formatStatement(stmt)))
+		}
+		// calls do not see the results captured by each other
+		local := maps.Clone(ctx)
+		if err := stmt.CheckType(local, m); err != nil {
+			return located(statementSpan(stmt, ps.Span), err)
+		}
+		if ok {
+			if _, dup := captured[cs.Name]; dup {
+				return located(cs.Span, fmt.Errorf("cannot capture into %q twice within parallel", cs.Name))
+			}
+			captured[cs.Name] = local[cs.Name]
+		}
+	}
+	maps.Copy(ctx, captured)
+	return nil
+}
+
+func (ps *ParallelStmt) Execute(cm *CompiledMachine) Action {
+	var actions []Action
+	for _, stmt := range ps.Actions {
+		actions = append(actions, stmt.Execute(cm))
+	}
+	return func(m *StateMachine, ctx map[string]Value) error {
+		var (
+			wg     sync.WaitGroup
+			mu     sync.Mutex
+			first  error
+			locals = make([]map[string]Value, len(actions))
+		)
+		for i, action := range actions {
+			locals[i] = maps.Clone(ctx)
+			wg.Go(func() {
+				if err := action(m, locals[i]); err != nil {
+					mu.Lock()
+					if first == nil {
+						first = err
+					}
+					mu.Unlock()
+				}
+			})
+		}
+		wg.Wait()
+		if first != nil {
+			return first
+		}
+		for i, stmt := range ps.Actions {
+			if cs, ok := stmt.(*CaptureStmt); ok {
+				ctx[cs.Name] = locals[i][cs.Name]
+			}
+		}
+		return nil
+	}
+}
+
+func (ps *ParallelStmt) String() string {
+	return "parallel { " + formatStatements(ps.Actions) + " }"
+}
@@ -0,0 +1,67 @@
+package mova
+
+import (
+	"reflect"
+	"slices"
+)
+
+// structParams is a struct parameter of an action whose fields are populated from named arguments.
+type structParams struct {
+	index  int // of the parameter
+	fields []structField
+}
+
+type structField struct {
+	name  string // the mova tag or the name of the field
+	index int
+	typ   reflect.Type
+}
+
+// newStructParams returns the fields of the struct parameter i of type typ as arguments.
+func newStructParams(i int, typ reflect.Type) *structParams {
+	params := &structParams{index: i}
+	for j := range typ.NumField() {
+		f := typ.Field(j)
+		if !f.IsExported() || f.Anonymous {
+			continue
+		}
+		name := f.Name
+		if tag := f.Tag.Get("mova"); tag == "-" {
+			continue
+		} else if tag != "" {
+			name = tag
+		}
+		params.fields = append(params.fields, structField{name, j, f.Type})
+	}
+	return params
+}
+
+// arg returns the type of the argument name of the action, and whether the action takes it.
+func (spec ActionSpec) arg(name string) (reflect.Type, bool) {
+	if i := slices.Index(spec.Inputs, name); i != -1 && name != "" {
+		return spec.Function.Type().In(i), true
+	}
+	if spec.params != nil {
+		for _, f := range spec.params.fields {
+			if f.name == name {
+				return f.typ, true
+			}
+		}
+	}
+	return nil, false
+}
+
+// args returns the names of the arguments of the action in order.
+func (spec ActionSpec) args() []string {
+	var out []string
+	for i, input := range spec.Inputs {
+		if input != "" {
+			out = append(out, input)
+		} else if spec.params != nil && spec.params.index == i {
+			for _, f := range spec.params.fields {
+				out = append(out, f.name)
+			}
+		}
+	}
+	return out
+}
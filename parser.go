@@ -1,40 +1,51 @@
 package mova
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
-	"regexp"
+	"io"
 	"strconv"
 	"strings"
+	"time"
 )
 
-var rules = []rule{
-	{"", regexp.MustCompile(`^[\s\t\r\n]+`)}, // whitespace
-	{"", regexp.MustCompile(`^#[^\n]*`)},     // comment
-
-	{"arrow", regexp.MustCompile(`^->`)},
-	{"punct", regexp.MustCompile(`^[{}(),;=]`)},
-	{"string", regexp.MustCompile(`^"(\\.|[^"\\])*"`)},
-	{"float", regexp.MustCompile(`^[+-]?[0-9]+\.[0-9]*`)},
-	{"int", regexp.MustCompile(`^[+-]?[0-9]+`)},
-	{"bool", regexp.MustCompile(`^(true|false)\b`)},
-	{"keyword", regexp.MustCompile(`^(state|on|move)\b`)},
-	{"identifier", regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*`)},
-}
-
 type parser struct {
 	*lexer
 	filename string
+	reg      *Registry
+	errs     []error // syntax errors recovered from, see try
+	errPos   int     // position of the last syntax error
+	depth    int     // number of open braces
+	version  int     // language version, 0 until the pragma is looked for
+
+	features map[string]bool // enabled features, see WithFeatures
+	tested   map[string]bool // features tested by @if, whether they were enabled
+	branches []branch        // open @if directives
 }
 
+// LanguageVersion is the latest version of the language. Files declare the version they are
+// written in with a `mova <version>;` pragma before all entries, so later versions can change the
+// syntax without changing the meaning of existing files. Files without pragma are version 1.
+const LanguageVersion = 1
+
+// expect consumes a token of kind name. Keywords are accepted as identifiers, they are only
+// reserved where a statement or entry starts.
 func (p *parser) expect(name string) string {
-	if p.Token != name {
+	if p.Token != name && !(name == "identifier" && p.Token == "keyword") {
 		p.errUnexpected(name)
 	}
 	v := p.Value
+	if name == "identifier" && v[0] == '\'' {
+		v = unquoteIdent.Replace(v[1 : len(v)-1])
+	}
 	p.Next()
 	return v
 }
 
+// quoted identifiers may contain any character, 'like this' or 'it\'s'
+var unquoteIdent = strings.NewReplacer(`\'`, "'", `\\`, `\`)
+
 func (p *parser) expectValue(val string) {
 	if p.Value != val {
 		p.errUnexpected(strconv.Quote(val))
@@ -46,10 +57,16 @@ type ParseError struct {
 	Filename             string
 	Expected             []string
 	Line, Offset, Length int
+	Pos                  int // byte offset in the file
 	Type, Value          string
 }
 
 func (perr *ParseError) Error() string {
+	return fmt.Sprintf("%s:%d:%d-%d: %s", perr.Filename, perr.Line, perr.Offset, perr.Offset+perr.Length, perr.message())
+}
+
+// message describes the error without its location.
+func (perr *ParseError) message() string {
 	var exp strings.Builder
 	if len(perr.Expected) == 0 {
 		exp.WriteString("??")
@@ -65,7 +82,7 @@ func (perr *ParseError) Error() string {
 		exp.WriteString(" or ")
 		exp.WriteString(perr.Expected[len(perr.Expected)-1])
 	}
-	return fmt.Sprintf("%s:%d:%d-%d: expected %s, got %q", perr.Filename, perr.Line, perr.Offset, perr.Offset+perr.Length, exp.String(), perr.Value)
+	return fmt.Sprintf("expected %s, got %q", exp.String(), perr.Value)
 }
 
 func (p *parser) errUnexpected(expected ...string) {
@@ -75,6 +92,7 @@ func (p *parser) errUnexpected(expected ...string) {
 		Line:     p.Linenr,
 		Offset:   p.Offset,
 		Length:   p.Length,
+		Pos:      p.Pos,
 		Type:     p.Token,
 		Value:    p.Value,
 	}
@@ -83,61 +101,315 @@ func (p *parser) errUnexpected(expected ...string) {
 
 // entry point
 func (p *parser) ParseFile() (f *File, err error) {
+	f = &File{Filename: p.filename}
+	if p.version == 0 {
+		p.version = 1
+		// mova <version>;, `mova` is not reserved
+		if p.Token == "identifier" && p.Value == "mova" {
+			p.try(false, func() {
+				start := p.position()
+				p.Next()
+				if p.Value == "=" {
+					f.Entries = append(f.Entries, p.parseSet(start, "mova"))
+					return
+				}
+				f.Version = p.parseVersion()
+				p.version = f.Version
+			})
+		}
+	}
+	for p.Token != "EOF" && p.Token != "ERROR" {
+		p.try(false, func() {
+			f.Entries = append(f.Entries, p.parseEntry())
+		})
+	}
+	if p.Token == "ERROR" {
+		p.errs = append(p.errs, p.Err)
+	}
+	for _, b := range p.branches {
+		p.directiveError(b.span, "missing @endif")
+	}
+	return f, errors.Join(p.errs...)
+}
+
+// try runs parse and recovers from a syntax error, which is recorded. It then skips to the end of
+// the failed element, the next `;` outside of braces opened by it, or the `}` closing the block
+// the element is in.
+func (p *parser) try(inBlock bool, parse func()) {
+	depth := p.depth
 	defer func() {
-		if r := recover(); r != nil {
-			if e, ok := r.(error); ok {
-				err = e
-			} else {
-				err = fmt.Errorf("panic: %v", r)
-			}
+		r := recover()
+		if r == nil {
+			return
+		}
+		err, ok := r.(error)
+		if !ok {
+			err = fmt.Errorf("panic: %v", r)
+		}
+		// elements failing at the same token, e.g. at the end of the file, report it once
+		if perr, ok := err.(*ParseError); !ok || perr.Pos != p.errPos || len(p.errs) == 0 {
+			p.errs = append(p.errs, err)
 		}
+		p.errPos = p.Pos
+		p.sync(depth, inBlock)
 	}()
+	parse()
+}
 
-	f = &File{}
-	for p.Token != "EOF" {
-		e := p.parseEntry()
-		f.Entries = append(f.Entries, e)
+func (p *parser) sync(depth int, inBlock bool) {
+	for p.Token != "EOF" && p.Token != "ERROR" {
+		if p.depth <= depth {
+			if p.Value == ";" {
+				p.Next()
+				return
+			}
+			if p.Value == "}" && inBlock {
+				return
+			}
+		}
+		p.Next()
 	}
-	p.expect("EOF")
-	return f, nil
+}
+
+// Next advances to the next token, counting the braces consumed.
+func (p *parser) Next() {
+	switch p.Value {
+	case "{":
+		p.depth++
+	case "}":
+		p.depth = max(p.depth-1, 0)
+	}
+	p.lexer.Next()
+	p.skipDirectives()
+}
+
+// span returns the span from start to the end of the last consumed token.
+func (p *parser) span(start Position) Span {
+	return Span{Start: start, End: p.prevEnd}
+}
+
+// parseVersion parses the version of the pragma after `mova`.
+func (p *parser) parseVersion() int {
+	start := p.position()
+	version := int(parseInt(p.expect("int")))
+	if version < 1 || version > LanguageVersion {
+		panic(&CompileError{p.filename, p.span(start), fmt.Errorf("unsupported language version %d, latest is %d", version, LanguageVersion)})
+	}
+	p.expectValue(";")
+	return version
 }
 
 func (p *parser) parseEntry() Entry {
-	if p.Value == "state" {
+	start := p.position()
+	final := false
+	// final state <name> ..., `final` is not reserved
+	if p.Token == "identifier" && p.Value == "final" {
+		p.Next()
+		if p.Value != "state" {
+			return p.parseSet(start, "final")
+		}
+		final = true
+	}
+	if p.Token == "keyword" && p.Value == "state" {
+		p.Next()
+		// state = <value>; defines a constant named state
+		if p.Value == "=" && !final {
+			return p.parseSet(start, "state")
+		}
 		st := p.parseState()
 		p.expectValue(";")
+		st.Span = p.span(start)
+		st.Final = final
 		return st
 	}
-	if p.Token == "identifier" {
-		key := p.expect("identifier")
-		p.expectValue("=")
-		val := p.parseValue()
-		p.expectValue(";")
-		return &SetStmt{Key: key, Value: val}
+	// override <name> = <value>;, `override` is not reserved
+	if p.Token == "identifier" && p.Value == "override" {
+		p.Next()
+		if p.Value == "=" {
+			return p.parseSet(start, "override")
+		}
+		set := p.parseSet(start, p.expect("identifier"))
+		set.Override = true
+		return set
+	}
+	// var <name>: <type> = <value>;, `var` is not reserved
+	if p.Token == "identifier" && p.Value == "var" {
+		p.Next()
+		if p.Value == "=" {
+			return p.parseSet(start, "var")
+		}
+		return p.parseVar(start)
+	}
+	if p.Token == "identifier" || p.Token == "keyword" {
+		return p.parseSet(start, p.expect("identifier"))
 	}
 	p.errUnexpected("identifier", "\"state\"")
 	return nil
 }
 
+func (p *parser) parseSet(start Position, key string) *SetStmt {
+	p.expectValue("=")
+	val := p.parseValue()
+	p.expectValue(";")
+	return &SetStmt{Span: p.span(start), Key: key, Value: val}
+}
+
+// parseState parses a state after `state`.
 func (p *parser) parseState() *State {
-	p.expectValue("state")
 	name := p.expect("identifier")
+	var params []Param
+	if p.Value == "(" {
+		p.Next()
+		for p.Value != ")" {
+			pname := p.expect("identifier")
+			p.expectValue(":")
+			ptype := p.expect("identifier")
+			params = append(params, Param{Name: pname, Type: ptype})
+			if p.Value != "," {
+				break
+			}
+			p.Next() // skip comma
+		}
+		p.expectValue(")")
+	}
+	var task string
+	// awaiting task "<name>", `awaiting` is not reserved
+	if p.Token == "identifier" && p.Value == "awaiting" {
+		p.Next()
+		p.expectValue("task")
+		task = unquote(p.expect("string"))
+	}
 	p.expectValue("{")
-	var init []Statement
-	if p.Value != "on" {
-		init = append(init, p.parseAction())
+	var vars []*VarDecl
+	var init, compensate []Statement
+	var triggers []Trigger
+	var ignore []string
+	// var <name>: <type> = <value>;, before the init actions, `var` is not reserved
+	for p.Token == "identifier" && p.Value == "var" && init == nil {
+		p.try(true, func() {
+			start := p.position()
+			p.Next()
+			if p.Token != "identifier" && p.Token != "keyword" || p.Value == "=" {
+				init = p.parseActions(p.parseCallOrCapture(start, "var"))
+				return
+			}
+			vars = append(vars, p.parseVar(start))
+		})
+	}
+	if init == nil && p.Value != "}" && p.Token != "EOF" {
+		p.try(true, func() {
+			start := p.position()
+			var first Statement
+			// compensate { ... };, `compensate` is not reserved
+			if p.Token == "identifier" && p.Value == "compensate" {
+				p.Next()
+				if p.Value == "{" {
+					compensate = p.parseCompensate()
+				} else {
+					first = p.parseCallAt(start, "compensate")
+				}
+			} else if p.Token == "identifier" && p.Value == "ignore" {
+				// ignore <event>, ...; starts the triggers, ignore(args) calls an action named ignore
+				p.Next()
+				if p.Token == "identifier" || p.Token == "keyword" {
+					ignore = append(ignore, p.parseIgnore()...)
+					return
+				}
+				first = p.parseCallOrCapture(start, "ignore")
+			} else if p.Token == "keyword" && p.Value == "on" {
+				// on <event> starts the triggers, on(args) calls an action named on
+				p.Next()
+				if p.Value != "(" {
+					triggers = append(triggers, p.parseTrigger(start))
+					return
+				}
+				first = p.parseCallAt(start, "on")
+			} else {
+				first = p.parseAction()
+			}
+			if first != nil {
+				init = p.parseActions(first)
+			}
+		})
+	}
+	for p.Value != "}" && p.Token != "EOF" && p.Token != "ERROR" {
+		p.try(true, func() {
+			if p.Token == "identifier" && p.Value == "compensate" && compensate == nil {
+				p.Next()
+				compensate = p.parseCompensate()
+				return
+			}
+			// ignore <event>, ...;, `ignore` is not reserved
+			if p.Token == "identifier" && p.Value == "ignore" {
+				p.Next()
+				ignore = append(ignore, p.parseIgnore()...)
+				return
+			}
+			start := p.position()
+			if p.Token != "keyword" || p.Value != "on" {
+				p.errUnexpected("\"on\"")
+			}
+			p.Next()
+			triggers = append(triggers, p.parseTrigger(start))
+		})
+	}
+	p.expectValue("}")
+	return &State{Name: name, Params: params, Task: task, Vars: vars, Init: init, Compensate: compensate, Triggers: triggers, Ignore: ignore}
+}
+
+// parseIgnore parses the events of an ignore statement, `ignore` is already consumed.
+func (p *parser) parseIgnore() []string {
+	events := []string{p.expect("identifier")}
+	for p.Value == "," {
+		p.Next()
+		events = append(events, p.expect("identifier"))
+	}
+	p.expectValue(";")
+	return events
+}
+
+// parseActions parses the rest of a list of actions terminated by `;`, the first one is already parsed.
+func (p *parser) parseActions(first Statement) []Statement {
+	actions := []Statement{first}
+	for p.Value == "," {
+		p.Next()
+		actions = append(actions, p.parseAction())
+	}
+	p.expectValue(";")
+	return actions
+}
+
+// parseVar parses the rest of a variable declaration, `var` is already consumed.
+func (p *parser) parseVar(start Position) *VarDecl {
+	decl := &VarDecl{Name: p.expect("identifier")}
+	if p.Value == ":" {
+		p.Next()
+		decl.Type = p.expect("identifier")
+	}
+	if decl.Type == "" || p.Value == "=" {
+		p.expectValue("=")
+		decl.Value = p.parseValue()
+	}
+	p.expectValue(";")
+	decl.Span = p.span(start)
+	return decl
+}
+
+// parseCompensate parses `{ <action>, ...; };` after `compensate`, it never returns nil.
+func (p *parser) parseCompensate() []Statement {
+	p.expectValue("{")
+	actions := []Statement{}
+	if p.Value != "}" {
+		actions = append(actions, p.parseAction())
 		for p.Value == "," {
 			p.Next()
-			init = append(init, p.parseAction())
+			actions = append(actions, p.parseAction())
 		}
 		p.expectValue(";")
 	}
-	var triggers []Trigger
-	for p.Value != "}" {
-		triggers = append(triggers, p.parseTrigger())
-	}
 	p.expectValue("}")
-	return &State{Name: name, Init: init, Triggers: triggers}
+	p.expectValue(";")
+	return actions
 }
 
 func (p *parser) parseTriggerCond() TriggerCond {
@@ -157,11 +429,12 @@ func (p *parser) parseTriggerCond() TriggerCond {
 	return TriggerCond{name, params}
 }
 
-func (p *parser) parseTrigger() Trigger {
-	p.expectValue("on")
+// parseTrigger parses a trigger starting at start, after `on`.
+func (p *parser) parseTrigger(start Position) Trigger {
 	var conds []TriggerCond
 	conds = append(conds, p.parseTriggerCond())
 	for p.Value == "," {
+		p.Next()
 		conds = append(conds, p.parseTriggerCond())
 	}
 	p.expectValue("->")
@@ -172,26 +445,187 @@ func (p *parser) parseTrigger() Trigger {
 		actions = append(actions, p.parseAction())
 	}
 	p.expectValue(";")
-	return Trigger{Cond: conds, Actions: actions}
+	return Trigger{Span: p.span(start), Cond: conds, Actions: actions}
 }
 
 func (p *parser) parseAction() Statement {
-	// move <state>
-	if p.Value == "move" {
+	// move <state>(args)
+	if p.Token == "keyword" && p.Value == "move" {
+		start := p.position()
 		p.Next()
+		// move(args) calls an action named move
+		if p.Token != "identifier" && p.Token != "keyword" {
+			return p.parseCallAt(start, "move")
+		}
 		dst := p.expect("identifier")
-		return &MoveStmt{Dest: dst}
+		// move choice { <weight>% <state>(args); ... }, `choice` is not reserved
+		if dst == "choice" && p.Value == "{" {
+			return p.parseChoice(start)
+		}
+		args := p.parseArgs()
+		return &MoveStmt{Span: p.span(start), Dest: dst, Args: args}
+	}
+	// schedule <event>(args) after <duration>, `schedule` is not reserved
+	if p.Token == "identifier" && p.Value == "schedule" {
+		if _, ok := p.reg.statements["schedule"]; !ok {
+			start := p.position()
+			p.Next()
+			if p.Token != "identifier" && p.Token != "keyword" {
+				return p.parseCallAt(start, "schedule")
+			}
+			event := p.expect("identifier")
+			args := p.parseArgs()
+			p.expectValue("after")
+			return &ScheduleStmt{Span: p.span(start), Event: event, Args: args, After: p.parseDuration()}
+		}
+	}
+	// set <name> = <value>, `set` is not reserved
+	if p.Token == "identifier" && p.Value == "set" {
+		if _, ok := p.reg.statements["set"]; !ok {
+			start := p.position()
+			p.Next()
+			if p.Token != "identifier" && p.Token != "keyword" {
+				return p.parseCallAt(start, "set")
+			}
+			name := p.expect("identifier")
+			p.expectValue("=")
+			return &AssignStmt{Span: p.span(start), Name: name, Value: p.parseValue()}
+		}
 	}
-	// CALL(args)
-	if p.Token == "identifier" {
-		return p.parseCall()
+	// <keyword> ..., registered using NewStatement
+	if parse, ok := p.reg.statements[p.Value]; ok && p.Token == "identifier" {
+		p.Next()
+		return parse(&Parser{p})
+	}
+	// if <value> { actions } else { actions }, `if` is not reserved
+	if p.Token == "identifier" && p.Value == "if" {
+		start := p.position()
+		p.Next()
+		if p.Value != "(" && p.Value != "=" {
+			return p.parseIf(start)
+		}
+		return p.parseCallOrCapture(start, "if")
+	}
+	// parallel { calls }, `parallel` is not reserved
+	if p.Token == "identifier" && p.Value == "parallel" {
+		start := p.position()
+		p.Next()
+		if p.Value == "{" {
+			return &ParallelStmt{Actions: p.parseBlock(), Span: p.span(start)}
+		}
+		return p.parseCallOrCapture(start, "parallel")
+	}
+	// CALL(args) or <name> = CALL(args)
+	if p.Token == "identifier" || p.Token == "keyword" {
+		start := p.position()
+		return p.parseCallOrCapture(start, p.expect("identifier"))
 	}
 	p.errUnexpected("\"move\"", "\"set\"", "identifier")
 	return nil
 }
 
+// parseCallOrCapture parses the rest of a call or a capture of its result, whose first name is already consumed.
+func (p *parser) parseCallOrCapture(start Position, name string) Statement {
+	if p.Value != "=" {
+		return p.parseCallAt(start, name)
+	}
+	p.Next()
+	call := p.parseCall()
+	return &CaptureStmt{Span: p.span(start), Name: name, Call: call}
+}
+
+// parseIf parses the rest of an if statement, `if` is already consumed.
+func (p *parser) parseIf(start Position) *IfStmt {
+	stmt := &IfStmt{Cond: p.parseValue(), Then: p.parseBlock()}
+	if p.Token == "identifier" && p.Value == "else" {
+		p.Next()
+		stmt.Else = p.parseBlock()
+	}
+	stmt.Span = p.span(start)
+	return stmt
+}
+
+// parseBlock parses actions separated by commas in braces.
+func (p *parser) parseBlock() []Statement {
+	p.expectValue("{")
+	var actions []Statement
+	for p.Value != "}" {
+		actions = append(actions, p.parseAction())
+		if p.Value != "," {
+			break
+		}
+		p.Next()
+	}
+	p.expectValue("}")
+	return actions
+}
+
+func (p *parser) parseChoice(start Position) *ChoiceStmt {
+	p.expectValue("{")
+	choice := &ChoiceStmt{}
+	for p.Value != "}" {
+		var weight float64
+		switch p.Token {
+		case "int":
+			weight = float64(parseInt(p.expect("int")))
+		case "float":
+			weight, _ = strconv.ParseFloat(strings.ReplaceAll(p.expect("float"), "_", ""), 64)
+		default:
+			p.errUnexpected("int", "float")
+		}
+		p.expectValue("%")
+		mstart := p.position()
+		dst := p.expect("identifier")
+		move := &MoveStmt{Dest: dst, Args: p.parseArgs()}
+		move.Span = p.span(mstart)
+		p.expectValue(";")
+		choice.Branches = append(choice.Branches, ChoiceBranch{Weight: weight, Move: move})
+	}
+	p.expectValue("}")
+	choice.Span = p.span(start)
+	return choice
+}
+
 func (p *parser) parseCall() *Call {
-	name := p.expect("identifier")
+	start := p.position()
+	return p.parseCallAt(start, p.expect("identifier"))
+}
+
+// parseCallAt parses the rest of a call starting at start, whose name is already consumed.
+func (p *parser) parseCallAt(start Position, name string) *Call {
+	call := &Call{Name: name, Args: p.parseArgs()}
+	// optional annotations: timeout <duration> retry(<int>[, <duration>])
+	for p.Token == "identifier" {
+		switch p.Value {
+		case "timeout":
+			p.Next()
+			call.Policy.Timeout = p.parseDuration()
+		case "retry":
+			p.Next()
+			p.expectValue("(")
+			call.Policy.Retries = int(parseInt(p.expect("int")))
+			if p.Value == "," {
+				p.Next()
+				call.Policy.Backoff = p.parseDuration()
+			}
+			p.expectValue(")")
+		default:
+			p.errUnexpected("\"timeout\"", "\"retry\"")
+		}
+	}
+	call.Span = p.span(start)
+	return call
+}
+
+func (p *parser) parseDuration() time.Duration {
+	d, err := time.ParseDuration(p.expect("duration"))
+	if err != nil {
+		panic(err)
+	}
+	return d
+}
+
+func (p *parser) parseArgs() map[string]Value {
 	args := make(map[string]Value)
 	if p.Value == "(" {
 		p.Next()
@@ -205,7 +639,7 @@ func (p *parser) parseCall() *Call {
 		}
 		p.expectValue(")")
 	}
-	return &Call{Name: name, Args: args}
+	return args
 }
 
 func (p *parser) parseParam() Arg {
@@ -214,6 +648,10 @@ func (p *parser) parseParam() Arg {
 		p.Next()
 		return Arg{Key: key, Value: p.parseValue()}
 	}
+	if p.Value == "?" {
+		p.Next()
+		return Arg{Key: key, Optional: true}
+	}
 	return Arg{Key: key}
 }
 
@@ -226,51 +664,190 @@ func (p *parser) parseArg() (string, Value) {
 	return key, &ReferenceValue{Ref: key}
 }
 
+var unescape = strings.NewReplacer(
+	"\\\"", "\"",
+	"\\'", "'",
+	"\\a", "\a",
+	"\\b", "\b",
+	"\\e", "\033",
+	"\\f", "\f",
+	"\\n", "\n",
+	"\\r", "\r",
+	"\\t", "\t",
+	"\\v", "\v",
+	"\\\\", "\\",
+)
+
+// unquote returns the contents of a string token. Raw `...` strings are taken
+// verbatim, in """...""" strings a newline right after the opening quotes is
+// dropped.
+func unquote(raw string) string {
+	switch {
+	case raw[0] == '`':
+		return raw[1 : len(raw)-1]
+	case strings.HasPrefix(raw, `"""`):
+		s := raw[3 : len(raw)-3]
+		s = strings.TrimPrefix(strings.TrimPrefix(s, "\r"), "\n")
+		return unescape.Replace(s)
+	}
+	return unescape.Replace(raw[1 : len(raw)-1])
+}
+
+// parseInt parses an int token. Without a 0x, 0b or 0o prefix the number is
+// decimal, even with leading zeros.
+func parseInt(s string) int64 {
+	digits := strings.TrimLeft(s, "+-")
+	base := 10
+	if len(digits) > 1 && digits[0] == '0' && strings.ContainsRune("xXbBoO", rune(digits[1])) {
+		base = 0
+	} else {
+		s = strings.ReplaceAll(s, "_", "")
+	}
+	i, err := strconv.ParseInt(s, base, 64)
+	if err != nil {
+		panic(err)
+	}
+	return i
+}
+
 func (p *parser) parseValue() Value {
 	switch p.Token {
 	case "string":
 		raw := p.Value
+		if strings.HasPrefix(raw, `"""`) && (len(raw) < 6 || !strings.HasSuffix(raw, `"""`)) {
+			p.errUnexpected("\"\"\"")
+		}
 		p.Next()
-		s := strings.NewReplacer(
-			"\\\"", "\"",
-			"\\'", "'",
-			"\\a", "\a",
-			"\\b", "\b",
-			"\\e", "\033",
-			"\\f", "\f",
-			"\\n", "\n",
-			"\\r", "\r",
-			"\\t", "\t",
-			"\\v", "\v",
-			"\\\\", "\\",
-		).Replace(raw[1 : len(raw)-1])
+		s := unquote(raw)
 		return &ConstValue{s}
 	case "int":
 		s := p.Value
 		p.Next()
-		i, err := strconv.ParseInt(s, 10, 64)
-		if err != nil {
-			panic(err)
-		}
-		return &ConstValue{i}
+		return &ConstValue{parseInt(s)}
 	case "float":
 		s := p.Value
 		p.Next()
-		f, err := strconv.ParseFloat(s, 64)
+		f, err := strconv.ParseFloat(strings.ReplaceAll(s, "_", ""), 64)
 		if err != nil {
 			panic(err)
 		}
 		return &ConstValue{f}
+	case "duration":
+		return &ConstValue{p.parseDuration()}
 	case "bool":
 		s := p.Value
 		p.Next()
 		return &ConstValue{s == "true"}
-	case "identifier":
-		s := p.Value
-		p.Next()
+	case "identifier", "keyword":
+		quoted := p.Value[0] == '\''
+		s := p.expect("identifier")
+		// type(value)
+		if _, ok := p.reg.typeByName(s); ok && !quoted && p.Value == "(" {
+			p.Next()
+			inner := p.parseValue()
+			p.expectValue(")")
+			return &CastValue{Type: s, Value: inner, custom: p.reg.customType(s)}
+		}
+		// function(args)
+		if !quoted && p.Value == "(" {
+			p.Next()
+			fn := &FuncValue{Name: s, fn: p.reg.function(s)}
+			for p.Value != ")" {
+				fn.Args = append(fn.Args, p.parseValue())
+				if p.Value != "," {
+					break
+				}
+				p.Next() // skip comma
+			}
+			p.expectValue(")")
+			return fn
+		}
 		return &ReferenceValue{Ref: s}
 	default:
-		p.errUnexpected("string", "int", "float", "bool", "identifier")
+		for _, t := range p.reg.types {
+			if p.Token == literalToken(t) {
+				lit := p.Value
+				p.Next()
+				v, err := t.Parse(lit)
+				if err != nil {
+					panic(fmt.Errorf("%s:%d: invalid %s literal %q: %w", p.filename, p.Linenr, t.Name, lit, err))
+				}
+				return &ConstValue{v}
+			}
+		}
+		p.errUnexpected("string", "int", "float", "duration", "bool", "identifier")
 		return nil
 	}
 }
+
+// newParser creates a parser for input starting at pos of a file.
+func newParser(filename string, r io.Reader, reg *Registry, pos Position, features map[string]bool) *parser {
+	p := &parser{lexer: newLexerAt(r, reg.rules(), pos), filename: filename, reg: reg, features: features}
+	p.skipDirectives()
+	return p
+}
+
+// Parse parses a source file without compiling it. Syntax errors do not stop parsing, the
+// file is returned with the entries and triggers that could be parsed, along with all errors.
+// Only the branches of `@if` directives selected by the features of WithFeatures are parsed,
+// other options are ignored.
+func Parse(filename string, r io.Reader, reg *Registry, opts ...BuildOption) (*File, error) {
+	var conf buildConfig
+	for _, opt := range opts {
+		opt(&conf)
+	}
+	var src bytes.Buffer
+	p := newParser(filename, io.TeeReader(r, &src), reg, Position{Line: 1}, conf.features)
+	f, err := p.ParseFile()
+	if p.tested != nil {
+		// kept to select other branches, see File.Compile
+		f.src, f.features = src.Bytes(), p.tested
+	}
+	return f, err
+}
+
+// entrySpan returns the source range of a top-level entry.
+func entrySpan(e Entry) Span {
+	switch e := e.(type) {
+	case *State:
+		return e.Span
+	case *SetStmt:
+		return e.Span
+	case *VarDecl:
+		return e.Span
+	}
+	return Span{}
+}
+
+// Reparse parses src, an edited version of the source prev was parsed from.
+// Entries of prev ending before changedAt, the byte offset of the first edit,
+// are kept as is and parsing resumes after the last of them. prev must be free of syntax errors.
+// Files with directives are parsed again from the start, with all features disabled.
+func Reparse(filename string, prev *File, src []byte, changedAt int, reg *Registry) (*File, error) {
+	if prev.features != nil {
+		return Parse(filename, bytes.NewReader(src), reg)
+	}
+	f := &File{Filename: filename}
+	start := Position{Line: 1}
+	for _, e := range prev.Entries {
+		span := entrySpan(e)
+		if span.End.Offset == 0 || span.End.Offset > changedAt {
+			break
+		}
+		f.Entries = append(f.Entries, e)
+		start = span.End
+	}
+	p := newParser(filename, bytes.NewReader(src[start.Offset:]), reg, start, nil)
+	if len(f.Entries) > 0 {
+		// the pragma precedes the kept entries
+		f.Version = prev.Version
+		p.version = max(prev.Version, 1)
+	}
+	rest, err := p.ParseFile()
+	f.Version = max(f.Version, rest.Version)
+	f.Entries = append(f.Entries, rest.Entries...)
+	if p.tested != nil {
+		f.src, f.features = src, p.tested
+	}
+	return f, err
+}
@@ -0,0 +1,147 @@
+package mova
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"time"
+)
+
+var ErrActionTimeout = errors.New("action timed out")
+
+// ErrBatchTimeout is returned when the actions of a trigger take longer than allowed, see WithBatchTimeout.
+var ErrBatchTimeout = errors.New("trigger timed out")
+
+var contextType = reflect.TypeFor[context.Context]()
+
+// Policy controls how an action is executed. Zero fields mean no timeout and no retries.
+type Policy struct {
+	Timeout time.Duration // maximum duration of a single attempt
+	Retries int           // number of additional attempts after a failure
+	Backoff time.Duration // delay before the first retry, doubled for every following retry
+	Jitter  float64       // fraction of the delay randomly added or removed, see WithSeed
+}
+
+// merge returns p with all non-zero fields of override applied.
+func (p Policy) merge(override Policy) Policy {
+	if override.Timeout != 0 {
+		p.Timeout = override.Timeout
+	}
+	if override.Retries != 0 {
+		p.Retries = override.Retries
+	}
+	if override.Backoff != 0 {
+		p.Backoff = override.Backoff
+	}
+	if override.Jitter != 0 {
+		p.Jitter = override.Jitter
+	}
+	return p
+}
+
+// SetPolicy sets the default execution policy of a registered action, calls in a machine file may override it.
+func SetPolicy(r *Registry, name string, p Policy) {
+	spec, ok := r.actions[name]
+	if !ok {
+		panic(fmt.Errorf("unspecified action %q", name))
+	}
+	spec.Policy = p
+	r.actions[name] = spec
+}
+
+// WithBatchTimeout limits the time the actions of a trigger may take together, including init actions
+// of the states it moves to. A running action is abandoned when the time is up, its context is
+// cancelled and the trigger fails with ErrBatchTimeout. Asynchronous actions are not limited.
+func WithBatchTimeout(d time.Duration) InstanceOption {
+	return func(m *StateMachine) {
+		m.batchTimeout = d
+	}
+}
+
+// batchExpired returns ErrBatchTimeout if the trigger being executed ran out of time.
+func (m *StateMachine) batchExpired() error {
+	if !m.deadline.IsZero() && !m.clock.Now().Before(m.deadline) {
+		return fmt.Errorf("%w after %v", ErrBatchTimeout, m.batchTimeout)
+	}
+	return nil
+}
+
+// invoke calls an action following policy, attempts are cut short at deadline unless it is zero.
+func (m *StateMachine) invoke(ctx context.Context, name string, spec ActionSpec, ins []reflect.Value, policy Policy, deadline time.Time) (result reflect.Value, err error) {
+	backoff := policy.Backoff
+	for attempt := 0; ; attempt++ {
+		result, err = m.attempt(ctx, name, spec, ins, policy.Timeout, deadline)
+		if err == nil || attempt >= policy.Retries || errors.Is(err, ErrBatchTimeout) {
+			return result, err
+		}
+		delay := backoff
+		if policy.Jitter > 0 {
+			delay += time.Duration((m.Random()*2 - 1) * policy.Jitter * float64(backoff))
+		}
+		select {
+		case <-ctx.Done():
+			return result, ctx.Err()
+		case <-m.clock.After(delay):
+		}
+		backoff *= 2
+	}
+}
+
+func (m *StateMachine) attempt(ctx context.Context, name string, spec ActionSpec, ins []reflect.Value, timeout time.Duration, deadline time.Time) (result reflect.Value, err error) {
+	start := m.clock.Now()
+	defer func() {
+		m.metrics.ActionDuration(name, m.clock.Now().Sub(start), err)
+	}()
+	batch := false // the deadline of the trigger comes first
+	if !deadline.IsZero() {
+		left := deadline.Sub(start)
+		if left <= 0 {
+			return reflect.Value{}, fmt.Errorf("%w after %v", ErrBatchTimeout, m.batchTimeout)
+		}
+		if timeout <= 0 || left < timeout {
+			timeout, batch = left, true
+		}
+	}
+	if timeout <= 0 {
+		return spec.call(withContext(ins, spec, ctx))
+	}
+	// the deadline follows the clock of the machine, which need not be the system clock
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	expired := m.clock.After(timeout)
+
+	type ret struct {
+		result reflect.Value
+		err    error
+	}
+	done := make(chan ret, 1)
+	go func() {
+		result, err := spec.call(withContext(ins, spec, ctx))
+		done <- ret{result, err}
+	}()
+	select {
+	case r := <-done:
+		return r.result, r.err
+	case <-ctx.Done():
+		return reflect.Value{}, ctx.Err()
+	case <-expired:
+		cancel()
+		if batch {
+			return reflect.Value{}, fmt.Errorf("%w after %v: %s did not return", ErrBatchTimeout, m.batchTimeout, name)
+		}
+		return reflect.Value{}, fmt.Errorf("%w: %s after %v", ErrActionTimeout, name, timeout)
+	}
+}
+
+// withContext returns a copy of ins with all context.Context parameters set to ctx.
+func withContext(ins []reflect.Value, spec ActionSpec, ctx context.Context) []reflect.Value {
+	out := make([]reflect.Value, len(ins))
+	for i, in := range ins {
+		if spec.Function.Type().In(i) == contextType {
+			in = reflect.ValueOf(&ctx).Elem()
+		}
+		out[i] = in
+	}
+	return out
+}
@@ -0,0 +1,41 @@
+package mova
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestRetryCancel checks that cancelling the event stops waiting for the next retry.
+func TestRetryCancel(t *testing.T) {
+	var reg Registry
+	NewTrigger[bindEvent](&reg, "press")
+	ctx, cancel := context.WithCancel(context.Background())
+	calls := 0
+	NewAction(&reg, "fail", nil, func() error {
+		calls++
+		cancel()
+		return errors.New("unavailable")
+	})
+	SetPolicy(&reg, "fail", Policy{Retries: 3, Backoff: time.Hour})
+	cm, err := BuildMachine("test.mova", strings.NewReader(`state a { on press -> fail; };`), &reg, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, _ := cm.New(WithClock(NewFakeClock(time.Unix(0, 0))))
+	done := make(chan error, 1)
+	go func() { done <- m.EmitContext(ctx, "press", bindEvent{}) }()
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("got error %v, want %v", err, context.Canceled)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("still waiting for the retry")
+	}
+	if calls != 1 {
+		t.Fatalf("called %d times, want 1", calls)
+	}
+}
@@ -0,0 +1,38 @@
+package mova
+
+import "sync"
+
+// Pool recycles instances of a machine, for workloads creating an instance per request.
+type Pool struct {
+	cm   *CompiledMachine
+	opts []InstanceOption
+	pool sync.Pool
+}
+
+// Pool returns a Pool of instances of cm, each created with opts.
+func (cm *CompiledMachine) Pool(opts ...InstanceOption) *Pool {
+	p := &Pool{cm: cm, opts: opts}
+	p.pool.New = func() any {
+		return new(StateMachine)
+	}
+	return p
+}
+
+// Get returns an instance in the initial state, as New would.
+func (p *Pool) Get() (*StateMachine, error) {
+	m := p.pool.Get().(*StateMachine)
+	m.init(p.cm, p.opts)
+	if err := m.Reset(); err != nil {
+		p.pool.Put(m)
+		return nil, err
+	}
+	return m, nil
+}
+
+// Put returns m to the pool. m must not be used afterwards.
+func (p *Pool) Put(m *StateMachine) {
+	if m.CompiledMachine != p.cm {
+		return
+	}
+	p.pool.Put(m)
+}
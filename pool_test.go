@@ -0,0 +1,129 @@
+package mova
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+	"unsafe"
+)
+
+type dirtyMetrics struct{ nopMetrics }
+
+type dirtyTracer struct{ nopTracer }
+
+// dirtyValues are values for interface fields of StateMachine, which differ from their defaults.
+var dirtyValues = map[reflect.Type]any{
+	reflect.TypeFor[Metrics]():         dirtyMetrics{},
+	reflect.TypeFor[Tracer]():          dirtyTracer{},
+	reflect.TypeFor[Journal]():         &memJournal{},
+	reflect.TypeFor[Clock]():           NewFakeClock(time.Unix(1, 0)),
+	reflect.TypeFor[DedupStore]():      &MemoryDedup{},
+	reflect.TypeFor[context.Context](): context.TODO(),
+	reflect.TypeFor[any]():             1,
+}
+
+// field returns field i of the struct v, also if it is unexported.
+func field(v reflect.Value, i int) reflect.Value {
+	f := v.Field(i)
+	return reflect.NewAt(f.Type(), unsafe.Pointer(f.UnsafeAddr())).Elem()
+}
+
+// dirty sets v to a value differing from its zero value.
+func dirty(t *testing.T, v reflect.Value) {
+	switch v.Kind() {
+	case reflect.Bool:
+		v.SetBool(true)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		v.SetInt(v.Int() + 1)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		v.SetUint(v.Uint() + 1)
+	case reflect.Float32, reflect.Float64:
+		v.SetFloat(v.Float() + 1)
+	case reflect.String:
+		v.SetString(v.String() + "x")
+	case reflect.Pointer:
+		v.Set(reflect.New(v.Type().Elem()))
+	case reflect.Map:
+		m := reflect.MakeMap(v.Type())
+		key, elem := reflect.New(v.Type().Key()).Elem(), reflect.New(v.Type().Elem()).Elem()
+		m.SetMapIndex(key, elem)
+		v.Set(m)
+	case reflect.Slice:
+		v.Set(reflect.MakeSlice(v.Type(), 1, 1))
+	case reflect.Chan:
+		v.Set(reflect.MakeChan(v.Type(), 1))
+	case reflect.Func:
+		v.Set(reflect.MakeFunc(v.Type(), func([]reflect.Value) []reflect.Value { panic("dirty") }))
+	case reflect.Interface:
+		d, ok := dirtyValues[v.Type()]
+		if !ok {
+			t.Fatalf("no dirty value for %v, add one to dirtyValues", v.Type())
+		}
+		v.Set(reflect.ValueOf(d))
+	case reflect.Struct:
+		for i := range v.NumField() {
+			dirty(t, field(v, i))
+		}
+	default:
+		t.Fatalf("cannot dirty %v", v.Type())
+	}
+}
+
+// TestPoolReset checks that an instance returned to a Pool keeps nothing of its earlier use.
+func TestPoolReset(t *testing.T) {
+	var reg Registry
+	NewTrigger[bindEvent](&reg, "press")
+	src := `state a { var v: int; on press(ID) -> set v = ID, move b; }; state b { };`
+	cm, err := BuildMachine("test.mova", strings.NewReader(src), &reg, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clock := NewFakeClock(time.Unix(0, 0))
+	p := cm.Pool(WithClock(clock))
+	fresh, _ := cm.New(WithClock(clock))
+	// locks and counters are only in use while the instance is, their zero value is kept
+	skip := map[reflect.Type]bool{
+		reflect.TypeFor[*CompiledMachine](): true,
+		reflect.TypeFor[sync.Mutex]():       true,
+		reflect.TypeFor[sync.WaitGroup]():   true,
+	}
+	m, err := p.Get()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the pool may drop instances, mostly with the race detector, try until it returns the same one
+	for range 100 {
+		if err := m.Emit("press", bindEvent{ID: 3}); err != nil {
+			t.Fatal(err)
+		}
+		rv := reflect.ValueOf(m).Elem()
+		for i := range rv.NumField() {
+			if f := field(rv, i); !skip[f.Type()] && !strings.HasPrefix(f.Type().PkgPath(), "sync/atomic") {
+				dirty(t, f)
+			}
+		}
+		m.current.Store(&CompiledState{Name: "dirty"})
+		m.debug.Store(&debugger{})
+		p.Put(m)
+		got, err := p.Get()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != m {
+			m = got
+			continue
+		}
+		want := reflect.ValueOf(fresh).Elem()
+		for i := range rv.NumField() {
+			name := rv.Type().Field(i).Name
+			if !reflect.DeepEqual(field(rv, i).Interface(), field(want, i).Interface()) {
+				t.Errorf("%s: got %#v, want %#v", name, field(rv, i).Interface(), field(want, i).Interface())
+			}
+		}
+		return
+	}
+	t.Skip("the pool never returned the same instance")
+}
@@ -0,0 +1,207 @@
+package mova
+
+import (
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultBuckets are the upper bounds in seconds of the action latency histogram.
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// PrometheusMetrics implements Metrics and serves the collected values
+// in the Prometheus text exposition format.
+type PrometheusMetrics struct {
+	Namespace string    // prefix of all metric names, "mova" if empty
+	Buckets   []float64 // latency buckets, DefaultBuckets if nil
+
+	mu         sync.Mutex
+	emitted    map[string]uint64
+	handled    map[[2]string]uint64
+	unhandled  map[[2]string]uint64
+	transition map[[2]string]uint64
+	failed     map[string]uint64
+	latency    map[string]*histogram
+	queues     map[string]*Queue
+}
+
+type histogram struct {
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+var _ Metrics = (*PrometheusMetrics)(nil)
+
+func inc[K comparable](m *map[K]uint64, key K) {
+	if *m == nil {
+		*m = make(map[K]uint64)
+	}
+	(*m)[key]++
+}
+
+func (pm *PrometheusMetrics) EventEmitted(event string) {
+	pm.mu.Lock()
+	defer pm.mu.Unlock()
+	inc(&pm.emitted, event)
+}
+
+func (pm *PrometheusMetrics) EventHandled(state, event string) {
+	pm.mu.Lock()
+	defer pm.mu.Unlock()
+	inc(&pm.handled, [2]string{state, event})
+}
+
+func (pm *PrometheusMetrics) EventUnhandled(state, event string) {
+	pm.mu.Lock()
+	defer pm.mu.Unlock()
+	inc(&pm.unhandled, [2]string{state, event})
+}
+
+func (pm *PrometheusMetrics) Transition(from, to string) {
+	pm.mu.Lock()
+	defer pm.mu.Unlock()
+	inc(&pm.transition, [2]string{from, to})
+}
+
+func (pm *PrometheusMetrics) ActionDuration(action string, d time.Duration, err error) {
+	pm.mu.Lock()
+	defer pm.mu.Unlock()
+	if err != nil {
+		inc(&pm.failed, action)
+	}
+	if pm.latency == nil {
+		pm.latency = make(map[string]*histogram)
+	}
+	h, ok := pm.latency[action]
+	if !ok {
+		h = &histogram{counts: make([]uint64, len(pm.buckets()))}
+		pm.latency[action] = h
+	}
+	secs := d.Seconds()
+	for i, le := range pm.buckets() {
+		if secs <= le {
+			h.counts[i]++
+		}
+	}
+	h.sum += secs
+	h.count++
+}
+
+// WatchQueue exports the counters of q labelled with name.
+func (pm *PrometheusMetrics) WatchQueue(name string, q *Queue) {
+	pm.mu.Lock()
+	defer pm.mu.Unlock()
+	if pm.queues == nil {
+		pm.queues = make(map[string]*Queue)
+	}
+	pm.queues[name] = q
+}
+
+func (pm *PrometheusMetrics) buckets() []float64 {
+	if pm.Buckets == nil {
+		return DefaultBuckets
+	}
+	return pm.Buckets
+}
+
+func (pm *PrometheusMetrics) name(metric string) string {
+	if pm.Namespace == "" {
+		return "mova_" + metric
+	}
+	return pm.Namespace + "_" + metric
+}
+
+func labels(pairs ...string) string {
+	var sb strings.Builder
+	sb.WriteByte('{')
+	for i := 0; i < len(pairs); i += 2 {
+		if i > 0 {
+			sb.WriteByte(',')
+		}
+		fmt.Fprintf(&sb, "%s=%s", pairs[i], strconv.Quote(pairs[i+1]))
+	}
+	sb.WriteByte('}')
+	return sb.String()
+}
+
+func writeCounter[K comparable](w io.Writer, name, help string, values map[K]uint64, label func(K) string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s counter\n", name, help, name)
+	lines := make([]string, 0, len(values))
+	for key, n := range values {
+		lines = append(lines, fmt.Sprintf("%s%s %d\n", name, label(key), n))
+	}
+	slices.Sort(lines)
+	for _, line := range lines {
+		io.WriteString(w, line)
+	}
+}
+
+// WriteText writes all metrics in the Prometheus text exposition format.
+func (pm *PrometheusMetrics) WriteText(w io.Writer) {
+	pm.mu.Lock()
+	defer pm.mu.Unlock()
+
+	pair := func(a, b string) func([2]string) string {
+		return func(k [2]string) string { return labels(a, k[0], b, k[1]) }
+	}
+	writeCounter(w, pm.name("events_emitted_total"), "Events passed to Emit.", pm.emitted, func(k string) string { return labels("event", k) })
+	writeCounter(w, pm.name("events_handled_total"), "Events which matched a trigger.", pm.handled, pair("state", "event"))
+	writeCounter(w, pm.name("events_unhandled_total"), "Events which matched no trigger.", pm.unhandled, pair("state", "event"))
+	writeCounter(w, pm.name("transitions_total"), "State transitions.", pm.transition, pair("from", "to"))
+	writeCounter(w, pm.name("action_errors_total"), "Failed action calls.", pm.failed, func(k string) string { return labels("action", k) })
+
+	if len(pm.queues) > 0 {
+		stats := make(map[string]QueueStats, len(pm.queues))
+		for qname, q := range pm.queues {
+			stats[qname] = q.Stats()
+		}
+		queue := func(k string) string { return labels("queue", k) }
+		counter := func(f func(QueueStats) uint64) map[string]uint64 {
+			out := make(map[string]uint64, len(stats))
+			for qname, s := range stats {
+				out[qname] = f(s)
+			}
+			return out
+		}
+		writeCounter(w, pm.name("queue_pushed_total"), "Events accepted by a queue.", counter(func(s QueueStats) uint64 { return s.Pushed }), queue)
+		writeCounter(w, pm.name("queue_delivered_total"), "Events delivered by a queue.", counter(func(s QueueStats) uint64 { return s.Delivered }), queue)
+		writeCounter(w, pm.name("queue_dropped_total"), "Events dropped by a full queue.", counter(func(s QueueStats) uint64 { return s.Dropped }), queue)
+		writeCounter(w, pm.name("queue_rejected_total"), "Events rejected by a full queue.", counter(func(s QueueStats) uint64 { return s.Rejected }), queue)
+
+		name := pm.name("queue_length")
+		fmt.Fprintf(w, "# HELP %s Events buffered in a queue.\n# TYPE %s gauge\n", name, name)
+		for _, qname := range slices.Sorted(maps.Keys(stats)) {
+			fmt.Fprintf(w, "%s%s %d\n", name, queue(qname), stats[qname].Len)
+		}
+	}
+
+	name := pm.name("action_duration_seconds")
+	fmt.Fprintf(w, "# HELP %s Duration of action calls.\n# TYPE %s histogram\n", name, name)
+	actions := make([]string, 0, len(pm.latency))
+	for action := range pm.latency {
+		actions = append(actions, action)
+	}
+	slices.Sort(actions)
+	for _, action := range actions {
+		h := pm.latency[action]
+		for i, le := range pm.buckets() {
+			fmt.Fprintf(w, "%s_bucket%s %d\n", name, labels("action", action, "le", strconv.FormatFloat(le, 'g', -1, 64)), h.counts[i])
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", name, labels("action", action, "le", "+Inf"), h.count)
+		fmt.Fprintf(w, "%s_sum%s %g\n", name, labels("action", action), h.sum)
+		fmt.Fprintf(w, "%s_count%s %d\n", name, labels("action", action), h.count)
+	}
+}
+
+// ServeHTTP serves the metrics, so pm can be mounted as scrape target.
+func (pm *PrometheusMetrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	pm.WriteText(w)
+}
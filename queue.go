@@ -0,0 +1,262 @@
+package mova
+
+import (
+	"container/heap"
+	"context"
+	"errors"
+	"io"
+	"sync"
+)
+
+var (
+	ErrQueueFull   = errors.New("event queue full")
+	ErrQueueClosed = errors.New("event queue closed")
+)
+
+// Discipline is the order in which a Queue delivers events.
+type Discipline int
+
+const (
+	PriorityOrder Discipline = iota // higher Priority first, in order of arrival among equal priorities
+	FIFO                            // in order of arrival, ignoring priorities
+	LIFO                            // newest first, ignoring priorities
+)
+
+// Overflow is what a Queue does with an event pushed while it is full.
+type Overflow int
+
+const (
+	OverflowReject     Overflow = iota // Push returns ErrQueueFull
+	OverflowDropLowest                 // the event with the lowest priority is dropped, the newest among equal ones
+	OverflowBlock                      // Push waits until there is space
+	OverflowDropOldest                 // the oldest buffered event is dropped
+	OverflowDropNewest                 // the pushed event is dropped
+)
+
+// QueueStats are the counters of a Queue.
+type QueueStats struct {
+	Len       int    // buffered events
+	Pushed    uint64 // events accepted by Push
+	Delivered uint64 // events returned by Pop
+	Dropped   uint64 // events dropped on overflow
+	Rejected  uint64 // events rejected with ErrQueueFull
+}
+
+// Queue buffers events for RunQueue. It is safe for concurrent use.
+type Queue struct {
+	mu         sync.Mutex
+	items      queueHeap
+	seq        uint64
+	limit      int
+	overflow   Overflow
+	discipline Discipline
+	ready      chan struct{} // signalled when an event was pushed or the queue closed
+	space      chan struct{} // signalled when an event was popped
+	done       chan struct{} // closed by Close, wakes all blocked producers
+	closed     bool
+	stats      QueueStats
+}
+
+type QueueOption func(*Queue)
+
+// WithLimit bounds the number of buffered events, overflow decides about events pushed to a full queue.
+func WithLimit(n int, overflow Overflow) QueueOption {
+	return func(q *Queue) {
+		q.limit = n
+		q.overflow = overflow
+	}
+}
+
+func WithDiscipline(d Discipline) QueueOption {
+	return func(q *Queue) {
+		q.discipline = d
+	}
+}
+
+// NewQueue returns an unbounded queue delivering events by priority.
+func NewQueue(opts ...QueueOption) *Queue {
+	q := &Queue{ready: make(chan struct{}, 1), space: make(chan struct{}, 1), done: make(chan struct{})}
+	for _, opt := range opts {
+		opt(q)
+	}
+	q.items.discipline = q.discipline
+	return q
+}
+
+type queued struct {
+	ev  Event
+	seq uint64
+}
+
+type queueHeap struct {
+	discipline Discipline
+	items      []queued
+}
+
+func (h *queueHeap) Len() int      { return len(h.items) }
+func (h *queueHeap) Swap(i, j int) { h.items[i], h.items[j] = h.items[j], h.items[i] }
+func (h *queueHeap) Push(x any)    { h.items = append(h.items, x.(queued)) }
+
+func (h *queueHeap) Pop() any {
+	last := h.items[len(h.items)-1]
+	h.items = h.items[:len(h.items)-1]
+	return last
+}
+
+func (h *queueHeap) Less(i, j int) bool {
+	a, b := h.items[i], h.items[j]
+	switch h.discipline {
+	case FIFO:
+		return a.seq < b.seq
+	case LIFO:
+		return a.seq > b.seq
+	}
+	if a.ev.Priority != b.ev.Priority {
+		return a.ev.Priority > b.ev.Priority
+	}
+	return a.seq < b.seq
+}
+
+// lowest returns the index of the event to drop on overflow.
+func (h *queueHeap) lowest() int {
+	low := 0
+	for i, it := range h.items {
+		lo := h.items[low]
+		if it.ev.Priority < lo.ev.Priority || it.ev.Priority == lo.ev.Priority && it.seq > lo.seq {
+			low = i
+		}
+	}
+	return low
+}
+
+// oldest returns the index of the event which arrived first.
+func (h *queueHeap) oldest() int {
+	old := 0
+	for i, it := range h.items {
+		if it.seq < h.items[old].seq {
+			old = i
+		}
+	}
+	return old
+}
+
+func notify(ch chan struct{}) {
+	select {
+	case ch <- struct{}{}:
+	default:
+	}
+}
+
+// Push adds ev to the queue, see PushContext.
+func (q *Queue) Push(ev Event) error {
+	return q.PushContext(context.Background(), ev)
+}
+
+// PushContext adds ev to the queue. If the queue is full, the overflow policy decides,
+// with OverflowBlock it waits until there is space or ctx is cancelled.
+func (q *Queue) PushContext(ctx context.Context, ev Event) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for {
+		if q.closed {
+			return ErrQueueClosed
+		}
+		if q.limit <= 0 || q.items.Len() < q.limit {
+			break
+		}
+		switch q.overflow {
+		case OverflowReject:
+			q.stats.Rejected++
+			return ErrQueueFull
+		case OverflowDropNewest:
+			q.stats.Dropped++
+			return nil
+		case OverflowDropOldest:
+			heap.Remove(&q.items, q.items.oldest())
+			q.stats.Dropped++
+			continue
+		case OverflowDropLowest:
+			q.stats.Dropped++
+			low := q.items.lowest()
+			if q.items.items[low].ev.Priority >= ev.Priority {
+				return nil // the pushed event is the lowest
+			}
+			heap.Remove(&q.items, low)
+			continue
+		}
+		// OverflowBlock
+		q.mu.Unlock()
+		select {
+		case <-ctx.Done():
+			q.mu.Lock()
+			return ctx.Err()
+		case <-q.space:
+		case <-q.done:
+		}
+		q.mu.Lock()
+	}
+	q.seq++
+	q.stats.Pushed++
+	heap.Push(&q.items, queued{ev, q.seq})
+	notify(q.ready)
+	if q.limit <= 0 || q.items.Len() < q.limit {
+		notify(q.space) // wake other blocked producers
+	}
+	return nil
+}
+
+// Stats returns the counters of the queue.
+func (q *Queue) Stats() QueueStats {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	s := q.stats
+	s.Len = q.items.Len()
+	return s
+}
+
+// Len returns the number of buffered events.
+func (q *Queue) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.items.Len()
+}
+
+// Close makes Pop return io.EOF once the buffered events are delivered.
+func (q *Queue) Close() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if !q.closed {
+		q.closed = true
+		close(q.done)
+	}
+	notify(q.ready)
+}
+
+// Pop removes the next event, waiting for one if the queue is empty.
+// It returns io.EOF if the queue is closed and empty.
+func (q *Queue) Pop(ctx context.Context) (Event, error) {
+	for {
+		q.mu.Lock()
+		if q.items.Len() > 0 {
+			ev := heap.Pop(&q.items).(queued).ev
+			q.stats.Delivered++
+			if q.items.Len() > 0 || q.closed {
+				notify(q.ready) // wake other consumers
+			}
+			notify(q.space)
+			q.mu.Unlock()
+			return ev, nil
+		}
+		closed := q.closed
+		q.mu.Unlock()
+		if closed {
+			notify(q.ready)
+			return Event{}, io.EOF
+		}
+		select {
+		case <-ctx.Done():
+			return Event{}, ctx.Err()
+		case <-q.ready:
+		}
+	}
+}
@@ -0,0 +1,218 @@
+package mova
+
+import (
+	"context"
+	"errors"
+	"io"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// drain pops all buffered events of a closed queue and returns their priorities.
+func drain(t *testing.T, q *Queue) []int {
+	t.Helper()
+	var out []int
+	for {
+		ev, err := q.Pop(context.Background())
+		if errors.Is(err, io.EOF) {
+			return out
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		out = append(out, ev.Priority)
+	}
+}
+
+func TestQueueOverflow(t *testing.T) {
+	tests := []struct {
+		name     string
+		overflow Overflow
+		errs     int // pushes failing with ErrQueueFull
+		want     []int
+		stats    QueueStats
+	}{
+		{"reject", OverflowReject, 2, []int{1, 2}, QueueStats{Pushed: 2, Delivered: 2, Rejected: 2}},
+		{"drop newest", OverflowDropNewest, 0, []int{1, 2}, QueueStats{Pushed: 2, Delivered: 2, Dropped: 2}},
+		{"drop oldest", OverflowDropOldest, 0, []int{3, 4}, QueueStats{Pushed: 4, Delivered: 2, Dropped: 2}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := NewQueue(WithLimit(2, tt.overflow), WithDiscipline(FIFO))
+			errs := 0
+			for p := 1; p <= 4; p++ {
+				if err := q.Push(Event{Priority: p}); errors.Is(err, ErrQueueFull) {
+					errs++
+				} else if err != nil {
+					t.Fatal(err)
+				}
+			}
+			if errs != tt.errs {
+				t.Errorf("%d pushes failed, want %d", errs, tt.errs)
+			}
+			if s := q.Stats(); s.Len != 2 {
+				t.Errorf("got length %d, want 2", s.Len)
+			}
+			q.Close()
+			if got := drain(t, q); !slices.Equal(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+			if s := q.Stats(); s != tt.stats {
+				t.Errorf("got %+v, want %+v", s, tt.stats)
+			}
+		})
+	}
+}
+
+func TestQueueBlock(t *testing.T) {
+	q := NewQueue(WithLimit(1, OverflowBlock))
+	if err := q.Push(Event{Priority: 1}); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := q.PushContext(ctx, Event{Priority: 2}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("got error %v, want %v", err, context.DeadlineExceeded)
+	}
+	// blocked producers continue once there is space
+	var wg sync.WaitGroup
+	for p := 2; p <= 4; p++ {
+		wg.Go(func() {
+			if err := q.Push(Event{Priority: p}); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+	var got []int
+	for range 4 {
+		ev, err := q.Pop(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, ev.Priority)
+	}
+	wg.Wait()
+	slices.Sort(got)
+	if !slices.Equal(got, []int{1, 2, 3, 4}) {
+		t.Fatalf("got %v", got)
+	}
+	if s := q.Stats(); s != (QueueStats{Pushed: 4, Delivered: 4}) {
+		t.Fatalf("got %+v", s)
+	}
+}
+
+// TestQueueCloseBlocked checks that Close wakes every blocked producer.
+func TestQueueCloseBlocked(t *testing.T) {
+	q := NewQueue(WithLimit(1, OverflowBlock))
+	if err := q.Push(Event{}); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	errs := make(chan error, 3)
+	for range 3 {
+		go func() { errs <- q.PushContext(ctx, Event{}) }()
+	}
+	time.Sleep(10 * time.Millisecond) // let the producers block
+	q.Close()
+	for range 3 {
+		if err := <-errs; !errors.Is(err, ErrQueueClosed) {
+			t.Fatalf("got error %v, want %v", err, ErrQueueClosed)
+		}
+	}
+	if err := q.Push(Event{}); !errors.Is(err, ErrQueueClosed) {
+		t.Fatalf("got error %v, want %v", err, ErrQueueClosed)
+	}
+	q.Close() // closing twice is allowed
+	if got := drain(t, q); len(got) != 1 {
+		t.Fatalf("got %d events, want the buffered one", len(got))
+	}
+}
+
+func TestQueueDiscipline(t *testing.T) {
+	pushed := []int{1, 3, 2, 3, 1}
+	tests := []struct {
+		name       string
+		discipline Discipline
+		want       []int // by index in pushed
+	}{
+		{"priority", PriorityOrder, []int{1, 3, 2, 0, 4}},
+		{"fifo", FIFO, []int{0, 1, 2, 3, 4}},
+		{"lifo", LIFO, []int{4, 3, 2, 1, 0}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := NewQueue(WithDiscipline(tt.discipline))
+			for i, p := range pushed {
+				if err := q.Push(Event{ID: string(rune('a' + i)), Priority: p}); err != nil {
+					t.Fatal(err)
+				}
+			}
+			q.Close()
+			var got []int
+			for {
+				ev, err := q.Pop(context.Background())
+				if errors.Is(err, io.EOF) {
+					break
+				}
+				got = append(got, int(ev.ID[0]-'a'))
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestQueueDropLowest(t *testing.T) {
+	q := NewQueue(WithLimit(3, OverflowDropLowest))
+	for _, p := range []int{2, 1, 1, 0, 3, 1} {
+		if err := q.Push(Event{Priority: p}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	q.Close()
+	// 0 and the final 1 are dropped as the lowest, 3 replaces the newest buffered 1
+	if got := drain(t, q); !slices.Equal(got, []int{3, 2, 1}) {
+		t.Fatalf("got %v", got)
+	}
+	if s := q.Stats(); s != (QueueStats{Pushed: 4, Delivered: 3, Dropped: 3}) {
+		t.Fatalf("got %+v", s)
+	}
+}
+
+func TestQueuePopCancel(t *testing.T) {
+	q := NewQueue()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := q.Pop(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("got error %v, want %v", err, context.DeadlineExceeded)
+	}
+}
+
+// TestRunQueuePriority checks that RunQueue delivers a backlog by priority.
+func TestRunQueuePriority(t *testing.T) {
+	var reg Registry
+	NewTrigger[bindEvent](&reg, "press")
+	var got []int
+	NewAction(&reg, "seen", []string{"id"}, func(id int) { got = append(got, id) })
+	cm, err := BuildMachine("test.mova", strings.NewReader(`state a { on press(ID) -> seen(id=ID); };`), &reg, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, _ := cm.New()
+	q := NewQueue()
+	for i, p := range []int{0, 0, 10, 0} {
+		q.Push(Event{Name: "press", Data: bindEvent{ID: i}, Priority: p})
+	}
+	q.Close()
+	if _, err := m.RunQueue(context.Background(), q); err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(got, []int{2, 0, 1, 3}) {
+		t.Fatalf("got %v", got)
+	}
+}
@@ -0,0 +1,42 @@
+package mova
+
+import "math/rand/v2"
+
+// WithSeed seeds the randomness of the machine, such as jitter between retries, so runs and
+// replays are reproducible. Without it, a random seed is used.
+func WithSeed(seed uint64) InstanceOption {
+	return func(m *StateMachine) {
+		m.random = rand.NewPCG(seed, seed)
+	}
+}
+
+// Random returns a pseudo-random number in [0, 1) from the source of the machine. Actions
+// needing randomness should use it, so they are reproducible using WithSeed and snapshots.
+func (m *StateMachine) Random() float64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.random == nil {
+		m.random = rand.NewPCG(rand.Uint64(), rand.Uint64())
+	}
+	return rand.New(m.random).Float64()
+}
+
+// randomState returns the state of the source to be restored with restoreRandom, nil if unused.
+func (m *StateMachine) randomState() []byte {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.random == nil {
+		return nil
+	}
+	b, _ := m.random.MarshalBinary()
+	return b
+}
+
+func restoreRandom(state []byte) InstanceOption {
+	return func(m *StateMachine) {
+		pcg := &rand.PCG{}
+		if pcg.UnmarshalBinary(state) == nil {
+			m.random = pcg
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package mova
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+var ErrRateLimited = errors.New("rate limited")
+
+// RateLimit is a token bucket: Burst events are allowed at once, and the bucket refills at Rate
+// events per second.
+type RateLimit struct {
+	Rate  float64
+	Burst int
+	Drop  bool // drop events over the limit silently instead of returning ErrRateLimited
+}
+
+type bucket struct {
+	limit  RateLimit
+	tokens float64
+	last   time.Time
+}
+
+// refill adds the tokens earned since the last refill and reports whether an event may pass.
+func (b *bucket) refill(now time.Time) bool {
+	if b.last.IsZero() {
+		b.tokens = float64(b.limit.Burst)
+	} else {
+		b.tokens = min(float64(b.limit.Burst), b.tokens+now.Sub(b.last).Seconds()*b.limit.Rate)
+	}
+	b.last = now
+	return b.tokens >= 1
+}
+
+// WithRateLimit limits the events emitted to the instance, e.g. when it is exposed over a network
+// adapter. Events emitted by completing asynchronous actions are not limited.
+func WithRateLimit(limit RateLimit) InstanceOption {
+	return func(m *StateMachine) {
+		m.limit = &bucket{limit: limit}
+	}
+}
+
+// WithEventRateLimit limits how often the named event may be emitted to the instance, in addition
+// to WithRateLimit.
+func WithEventRateLimit(event string, limit RateLimit) InstanceOption {
+	return func(m *StateMachine) {
+		name, ok := m.reg.triggerName(event)
+		if !ok {
+			panic(fmt.Errorf("unspecified event %q", event))
+		}
+		if m.limits == nil {
+			m.limits = make(map[string]*bucket)
+		}
+		m.limits[name] = &bucket{limit: limit}
+	}
+}
+
+// limited takes a token from the buckets limiting the event, it returns nil if the event may pass.
+// The returned bucket decides whether the event is dropped. m.mu must be held.
+func (m *StateMachine) limited(name string) *bucket {
+	if m.limit == nil && m.limits == nil {
+		return nil
+	}
+	now := m.clock.Now()
+	name, _ = m.reg.triggerName(name)
+	buckets := []*bucket{m.limits[name], m.limit}
+	for _, b := range buckets {
+		if b != nil && !b.refill(now) {
+			return b
+		}
+	}
+	for _, b := range buckets {
+		if b != nil {
+			b.tokens--
+		}
+	}
+	return nil
+}
@@ -0,0 +1,134 @@
+package mova
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// WithMissingCheck makes BuildMachine look up every action and trigger in the registry before type
+// checking and report all missing ones at once as *MissingError.
+func WithMissingCheck() BuildOption {
+	return func(c *buildConfig) {
+		c.checkMissing = true
+	}
+}
+
+// Missing is an action or trigger used by a source but not in the registry.
+type Missing struct {
+	Kind string // "action" or "trigger"
+	Name string
+	Uses []Span
+}
+
+type MissingError struct {
+	Filename string
+	Missing  []Missing // sorted by kind and name
+}
+
+func (e *MissingError) Error() string {
+	var lines []string
+	for _, m := range e.Missing {
+		var uses []string
+		for _, span := range m.Uses {
+			uses = append(uses, fmt.Sprintf("%s:%d:%d", e.Filename, span.Start.Line, span.Start.Column))
+		}
+		lines = append(lines, fmt.Sprintf("unspecified %s %q used at %s", m.Kind, m.Name, strings.Join(uses, ", ")))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// usages returns the actions and triggers used in f with the spans of the statements and triggers using them.
+func usages(f *File) (actions, triggers map[string][]Span) {
+	actions = make(map[string][]Span)
+	triggers = make(map[string][]Span)
+	calls := func(stmts []Statement) {
+		for _, stmt := range stmts {
+			Inspect(stmt, func(n Node) bool {
+				if c, ok := n.(*Call); ok {
+					actions[c.Name] = append(actions[c.Name], c.Span)
+				}
+				return true
+			})
+		}
+	}
+	for _, entry := range f.Entries {
+		st, ok := entry.(*State)
+		if !ok {
+			continue
+		}
+		calls(st.Init)
+		calls(st.Compensate)
+		for _, trg := range st.Triggers {
+			for _, c := range trg.Cond {
+				triggers[c.Name] = append(triggers[c.Name], trg.Span)
+			}
+			calls(trg.Actions)
+		}
+		for _, event := range st.Ignore {
+			triggers[event] = append(triggers[event], st.Span)
+		}
+	}
+	return
+}
+
+func missing(filename string, f *File, reg *Registry) error {
+	actions, triggers := usages(f)
+	var out MissingError
+	out.Filename = filename
+	for _, name := range slices.Sorted(maps.Keys(actions)) {
+		if _, ok := reg.action(name); !ok {
+			out.Missing = append(out.Missing, Missing{"action", name, actions[name]})
+		}
+	}
+	for _, name := range slices.Sorted(maps.Keys(triggers)) {
+		if _, ok := reg.trigger(name); !ok {
+			out.Missing = append(out.Missing, Missing{"trigger", name, triggers[name]})
+		}
+	}
+	if len(out.Missing) == 0 {
+		return nil
+	}
+	return &out
+}
+
+// deprecations warns about the uses of names registered by RegisterAlias in f.
+func deprecations(f *File, reg *Registry) []Diagnostic {
+	if len(reg.deprecated) == 0 {
+		return nil
+	}
+	var out []Diagnostic
+	actions, triggers := usages(f)
+	warn := func(kind string, uses map[string][]Span, resolve func(string) (string, bool)) {
+		for _, name := range slices.Sorted(maps.Keys(uses)) {
+			target, ok := resolve(name)
+			deprecated := reg.deprecated[name] || reg.ignoreCase && slices.ContainsFunc(slices.Collect(maps.Keys(reg.deprecated)), func(old string) bool {
+				return strings.EqualFold(old, name)
+			})
+			if !ok || target == name || !deprecated {
+				continue
+			}
+			for _, span := range uses[name] {
+				d := Diagnostic{f.Filename, span, "warning", "deprecated", fmt.Sprintf("%s %q is deprecated, use %q", kind, name, target)}
+				out = append(out, d)
+			}
+		}
+	}
+	warn("action", actions, reg.actionName)
+	warn("trigger", triggers, reg.triggerName)
+	return out
+}
+
+// Warnings returns the uses of deprecated names in the machine file, see RegisterAlias, triggers
+// of a state which are equally specific and both match some event, conditions which never match
+// and event-data dropped as not every condition of its trigger mentions it.
+func (cm *CompiledMachine) Warnings() []Diagnostic {
+	return slices.Clone(cm.warnings)
+}
+
+// RequiredActions returns the names of the actions the machine calls, sorted.
+func (cm *CompiledMachine) RequiredActions() []string {
+	actions, _ := usages(cm.file)
+	return slices.Sorted(maps.Keys(actions))
+}
@@ -0,0 +1,101 @@
+package mova
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"slices"
+)
+
+// Result describes how an event was handled, see EmitResult.
+type Result struct {
+	Handled bool     // a trigger matched the event
+	Queued  bool     // the event was queued behind the event being handled, nothing ran yet
+	Dropped bool     // the event exceeded a rate limit and was dropped, see RateLimit
+	Ignored bool     // no trigger matched, but the state ignores the event
+	Trigger int      // index of the trigger which fired in From, -1 if none
+	Actions []string // actions called, including init actions of entered states, in order
+	Moved   bool     // the machine changed state, possibly into the same state
+	From    string   // state the event was delivered to
+	State   string   // state after handling the event
+}
+
+// WhatIf reports how the current state would handle an event, without running any action or
+// changing state: the trigger which would fire, the actions it calls and the state it moves to.
+// Calls and moves in `if` and `choose` are included whether or not they would be taken, so Moved
+// tells the trigger may move, and State is empty if it may move to different states.
+func (m *StateMachine) WhatIf(name string, v any) (Result, error) {
+	state := m.current.Load()
+	res := Result{Trigger: -1, From: state.Name, State: state.Name}
+	name, rval, err := m.checkEvent(name, v)
+	if err != nil {
+		return res, err
+	}
+	index, err := state.match(name, rval, m.scope)
+	if err != nil {
+		return res, err
+	} else if index == -1 && slices.Contains(state.ignore, name) {
+		res.Ignored = true
+		return res, nil
+	} else if index == -1 {
+		return res, io.EOF
+	}
+	trg := state.Triggers[index]
+	res.Handled = true
+	res.Trigger = index
+	res.Actions = slices.Clone(trg.calls)
+	if dests := slices.Compact(slices.Sorted(slices.Values(trg.moves))); len(dests) > 0 {
+		res.Moved = true
+		res.State = ""
+		if len(dests) == 1 {
+			res.State = dests[0]
+		}
+	}
+	return res, nil
+}
+
+// EmitResult is Emit, reporting which trigger fired, the actions it called and the resulting state.
+// Queued events handled afterwards are not included.
+func (m *StateMachine) EmitResult(name string, v any) (Result, error) {
+	return m.EmitResultContext(context.Background(), name, v)
+}
+
+// EmitResultContext is EmitResult with a context, see EmitContext.
+func (m *StateMachine) EmitResultContext(ctx context.Context, name string, v any) (Result, error) {
+	return m.emit(ctx, name, v, true)
+}
+
+// emit handles or queues an event, checking the rate limits if limit is set.
+func (m *StateMachine) emit(ctx context.Context, name string, v any, limit bool) (Result, error) {
+	res := Result{Trigger: -1}
+	m.mu.Lock()
+	var b *bucket
+	if limit {
+		b = m.limited(name)
+	}
+	if b != nil {
+		m.mu.Unlock()
+		m.record(JournalEntry{Kind: JournalRateLimited, State: m.Current(), Event: name})
+		if b.limit.Drop {
+			res.Dropped = true
+			return res, nil
+		}
+		return res, fmt.Errorf("%w: %s", ErrRateLimited, name)
+	}
+	if m.busy {
+		m.pending = append(m.pending, event{ctx, name, v})
+		m.mu.Unlock()
+		res.Queued = true
+		return res, nil
+	}
+	m.busy = true
+	m.idle = false
+	m.mu.Unlock()
+
+	res.From = m.Current()
+	m.result = &res
+	err := m.handle(ctx, name, v)
+	m.result = nil
+	res.State = m.Current()
+	return res, m.drain(err)
+}
@@ -0,0 +1,85 @@
+package mova
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+)
+
+var ErrNoCorrelationKey = errors.New("event has no correlation key")
+
+// CorrelationKey returns the key of the instance an event belongs to, ok is false if it carries none.
+type CorrelationKey func(ev Event) (key string, ok bool)
+
+// FieldKey correlates events by the field of their event-data named field or tagged `mova:"field"`.
+// Events without such a field or with its zero value carry no key.
+func FieldKey(field string) CorrelationKey {
+	return func(ev Event) (string, bool) {
+		v := reflect.ValueOf(ev.Data)
+		for v.Kind() == reflect.Pointer && !v.IsNil() {
+			v = v.Elem()
+		}
+		if v.Kind() != reflect.Struct {
+			return "", false
+		}
+		idx := getTypeField(v.Type(), field)
+		if idx < 0 || v.Field(idx).IsZero() {
+			return "", false
+		}
+		return fmt.Sprint(v.Field(idx).Interface()), true
+	}
+}
+
+// Router is a Manager whose instances are chosen by a correlation key of the events,
+// such as the order an event is about. Instances are created on the first event with their key
+// and deleted once they reach a final state.
+type Router struct {
+	*Manager
+	key CorrelationKey
+}
+
+// NewRouter returns a Router creating instances of cm with opts, named by key.
+func NewRouter(cm *CompiledMachine, key CorrelationKey, opts ...InstanceOption) *Router {
+	return &Router{Manager: NewManager(cm, opts...), key: key}
+}
+
+// instance returns the instance named id, restoring or creating it if it is not in memory. id must
+// be locked.
+func (r *Router) instance(ctx context.Context, id string) (*managed, error) {
+	if inst, err := r.lookup(ctx, id); inst != nil || err != nil {
+		return inst, err
+	}
+	m, err := r.cm.New(append(r.opts, WithID(id))...)
+	if err != nil {
+		return nil, err
+	}
+	return r.add(id, m), nil
+}
+
+// Route delivers ev to the instance of its key and returns the key.
+func (r *Router) Route(ctx context.Context, ev Event) (string, error) {
+	id, ok := r.key(ev)
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrNoCorrelationKey, ev.Name)
+	}
+	defer r.lock(id)()
+	inst, err := r.instance(ctx, id)
+	if err != nil {
+		return id, err
+	}
+	err = r.emit(ctx, inst, ev)
+	if cur := inst.m.current.Load(); cur != nil && cur.Final {
+		// the next event with the key starts a new instance
+		r.mu.Lock()
+		delete(r.instances, id)
+		store := r.life.Store
+		r.mu.Unlock()
+		if store != nil {
+			if serr := store.Delete(ctx, id); serr != nil {
+				err = errors.Join(err, fmt.Errorf("unable to delete instance %q: %w", id, serr))
+			}
+		}
+	}
+	return id, err
+}
@@ -0,0 +1,79 @@
+package mova
+
+import (
+	"context"
+	"errors"
+	"io"
+	"reflect"
+)
+
+// Event is an event delivered to Run.
+type Event struct {
+	ID       string // optional, see WithDedup
+	Name     string
+	Data     any
+	Priority int // see Queue
+}
+
+// ExitEvent is the event-data of the builtin `exit` trigger, which fires when the machine leaves a state
+// or when Run shuts down.
+type ExitEvent struct{}
+
+// exit runs the exit actions of state.
+func (m *StateMachine) exit(state *CompiledState) error {
+	ev := reflect.ValueOf(ExitEvent{})
+	for _, trg := range state.Triggers {
+		if trg.Test("exit", ev) {
+			return m.batch(trg.actions, m.scope())
+		}
+	}
+	return nil
+}
+
+// Run handles events until events is closed, ctx is cancelled or an event fails. On shutdown it waits for running
+// asynchronous actions and handles their completion events, then runs the exit actions of the current state.
+// It returns the final state and ctx.Err() if cancelled, or the first error returned by EmitEvent.
+// Events not matching any trigger and duplicate events are ignored.
+func (m *StateMachine) Run(ctx context.Context, events <-chan Event) (string, error) {
+	return m.run(ctx, func() (Event, error) {
+		select {
+		case <-ctx.Done():
+			return Event{}, ctx.Err()
+		case ev, ok := <-events:
+			if !ok {
+				return Event{}, io.EOF
+			}
+			return ev, nil
+		}
+	})
+}
+
+// RunQueue is Run taking events from q until it is closed and drained.
+func (m *StateMachine) RunQueue(ctx context.Context, q *Queue) (string, error) {
+	return m.run(ctx, func() (Event, error) {
+		return q.Pop(ctx)
+	})
+}
+
+// run handles events from next until it returns an error, io.EOF ends without error.
+func (m *StateMachine) run(ctx context.Context, next func() (Event, error)) (string, error) {
+	var err error
+	for {
+		ev, nerr := next()
+		if nerr != nil {
+			if !errors.Is(nerr, io.EOF) {
+				err = nerr
+			}
+			break
+		}
+		if eerr := m.EmitEvent(ctx, ev); eerr != nil && !errors.Is(eerr, io.EOF) && !errors.Is(eerr, ErrDuplicateEvent) {
+			err = eerr
+			break
+		}
+	}
+	m.async.Wait()
+	if xerr := m.exit(m.current.Load()); xerr != nil {
+		err = errors.Join(err, xerr)
+	}
+	return m.Current(), err
+}
@@ -0,0 +1,68 @@
+package mova
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// TestRunShutdown checks that Run waits for asynchronous actions and runs the exit actions of the
+// current state however it stops.
+func TestRunShutdown(t *testing.T) {
+	var reg Registry
+	NewTrigger[bindEvent](&reg, "press")
+	var fetched, exited atomic.Int32
+	release := make(chan struct{})
+	NewAsyncAction(&reg, "fetch", nil, func() { <-release; fetched.Add(1) })
+	NewAction(&reg, "fail", nil, func() error { return errors.New("broken") })
+	NewAction(&reg, "leave", nil, func() { exited.Add(1) })
+	src := `state a {
+		on press(ID=1) -> fetch;
+		on press(ID=2) -> fail;
+		on exit -> leave;
+	};`
+	cm, err := BuildMachine("test.mova", strings.NewReader(src), &reg, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		ids  []int
+		stop func(chan Event, context.CancelFunc)
+		err  string
+	}{
+		{"closed", []int{1, 3}, func(events chan Event, _ context.CancelFunc) { close(events) }, ""},
+		{"cancelled", []int{1}, func(_ chan Event, cancel context.CancelFunc) { cancel() }, "context canceled"},
+		{"failed", []int{1, 2}, func(chan Event, context.CancelFunc) {}, "broken"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fetched.Store(0)
+			exited.Store(0)
+			release = make(chan struct{})
+			m, _ := cm.New()
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			events := make(chan Event)
+			done := make(chan error, 1)
+			go func() {
+				_, err := m.Run(ctx, events)
+				done <- err
+			}()
+			for _, id := range tt.ids {
+				events <- Event{Name: "press", Data: bindEvent{ID: id}}
+			}
+			tt.stop(events, cancel)
+			close(release)
+			err := <-done
+			if tt.err == "" && err != nil || tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)) {
+				t.Fatalf("got error %v, want %q", err, tt.err)
+			}
+			if fetched.Load() != 1 || exited.Load() != 1 {
+				t.Fatalf("fetched %d, exited %d times, want 1", fetched.Load(), exited.Load())
+			}
+		})
+	}
+}
@@ -1,28 +1,79 @@
 package mova
 
 import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"io"
 	"maps"
+	"math/rand/v2"
 	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
 func getTypeField(base reflect.Type, name string) int {
 	for i := range base.NumField() {
 		field := base.Field(i)
-		if field.Name == name || field.Tag.Get("mova") == name {
+		if field.Name == name || field.Tag.Get("mova") == name || protoName(field.Tag) == name {
 			return i
 		}
 	}
 	return -1
 }
 
+// protoName returns the field name in the tag of a generated protobuf message, such as order_id.
+func protoName(tag reflect.StructTag) string {
+	for opt := range strings.SplitSeq(tag.Get("protobuf"), ",") {
+		if name, ok := strings.CutPrefix(opt, "name="); ok {
+			return name
+		}
+	}
+	return ""
+}
+
+// dataType returns the struct type of event-data of type typ, which may be a pointer to it.
+func dataType(typ reflect.Type) reflect.Type {
+	if typ.Kind() == reflect.Pointer {
+		return typ.Elem()
+	}
+	return typ
+}
+
+// dataValue dereferences event-data passed as pointer, a nil pointer gives the zero value.
+func dataValue(v reflect.Value) reflect.Value {
+	if v.Kind() != reflect.Pointer {
+		return v
+	}
+	if v.IsNil() {
+		return reflect.Zero(v.Type().Elem())
+	}
+	return v.Elem()
+}
+
 type Registry struct {
 	triggers map[string]reflect.Type
+	decoders map[string]func([]byte) (any, error)
 	actions  map[string]ActionSpec
+	types    []*TypeSpec
+	funcs    map[string]*funcSpec
+
+	aliases       map[string]string // alternative trigger names, see NewTriggerAlias
+	actionAliases map[string]string // see RegisterAlias
+	deprecated    map[string]bool   // aliases registered by RegisterAlias
+	ignoreCase    bool              // see IgnoreCase
+
+	tokens     []rule
+	statements map[string]StatementParser
 }
 
+// NewTrigger registers the trigger name with event-data of type T, a struct or a pointer to a struct.
 func NewTrigger[T any](r *Registry, name string) {
 	if r.triggers == nil {
 		r.triggers = make(map[string]reflect.Type)
@@ -30,63 +81,272 @@ func NewTrigger[T any](r *Registry, name string) {
 	r.triggers[name] = reflect.TypeFor[T]()
 }
 
+// NewTriggerDecoder registers a trigger like NewTrigger, whose event-data is decoded from raw bytes
+// by decode, see Registry.DecodeBytes.
+func NewTriggerDecoder[T any](r *Registry, name string, decode func([]byte) (T, error)) {
+	NewTrigger[T](r, name)
+	if r.decoders == nil {
+		r.decoders = make(map[string]func([]byte) (any, error))
+	}
+	r.decoders[name] = func(data []byte) (any, error) {
+		return decode(data)
+	}
+}
+
+var builtinTriggers = map[string]reflect.Type{
+	"error": reflect.TypeFor[ErrorEvent](),
+	"exit":  reflect.TypeFor[ExitEvent](),
+}
+
+func (r *Registry) trigger(name string) (reflect.Type, bool) {
+	name, ok := r.triggerName(name)
+	if !ok {
+		return nil, false
+	}
+	if typ, ok := r.triggers[name]; ok {
+		return typ, true
+	}
+	return builtinTriggers[name], true
+}
+
+// Machine is the view of a running machine available to actions.
+type Machine interface {
+	Current() string
+	Move(state string) error
+	Emit(name string, v any) error
+}
+
+var _ Machine = (*StateMachine)(nil)
+
+var (
+	machineType      = reflect.TypeFor[*StateMachine]()
+	machineIfaceType = reflect.TypeFor[Machine]()
+)
+
+func isInjected(typ reflect.Type) bool {
+	return typ == machineType || typ == machineIfaceType || typ == contextType || typ == txType
+}
+
+// NewAction registers fn as action. args names the parameters of fn in order,
+// parameters of type *StateMachine, Machine, context.Context or *Tx are injected by the runtime and not named.
+// If args is empty and fn takes a single struct besides them, its exported fields are the arguments,
+// named by their `mova` tag or else their name, missing arguments leave the field zero.
 func NewAction(r *Registry, name string, args []string, fn any) {
 	val := reflect.ValueOf(fn)
-	if val.Type().NumIn() != len(args) {
-		panic(fmt.Errorf("action has %d arguments, %d expected", val.Type().NumIn(), len(args)))
+	typ := val.Type()
+	inputs := make([]string, typ.NumIn())
+	next, last := 0, -1
+	for i := range typ.NumIn() {
+		if isInjected(typ.In(i)) {
+			continue
+		}
+		if next < len(args) {
+			inputs[i] = args[next]
+		}
+		next, last = next+1, i
+	}
+	var params *structParams
+	if len(args) == 0 && next == 1 && typ.In(last).Kind() == reflect.Struct {
+		params = newStructParams(last, typ.In(last))
+	} else if next != len(args) {
+		panic(fmt.Errorf("action has %d arguments, %d expected", next, len(args)))
 	}
 	if r.actions == nil {
 		r.actions = make(map[string]ActionSpec)
 	}
 	r.actions[name] = ActionSpec{
-		Inputs:   args,
+		Inputs:   inputs,
 		Function: val,
+		params:   params,
 	}
 }
 
 type ActionSpec struct {
-	Inputs   []string      // expected input name -> type
+	Inputs   []string      // expected input name -> type, empty for injected parameters
 	Function reflect.Value // executed with resolved inputs
+	Async    bool          // executed on a goroutine, see NewAsyncAction
+	Policy   Policy        // default timeout and retries, see SetPolicy
+	Invoke   Invoker       // calls Function without reflection if set, see NewAction0
+
+	Validators map[string][]Validator // by argument, see SetValidator
+
+	params *structParams // struct parameter populated from the arguments, see NewAction
 }
 
 type CompiledMachine struct {
-	reg        *Registry
-	constants  map[string]Value
-	firstState string
-	states     map[string]*CompiledState
+	file      *File
+	reg       *Registry
+	constants map[string]Value
+	defined   map[string]Span // constants defined by the file, see SetStmt
+	order     []string        // state names in file order, the first is the initial state
+	states    map[string]*CompiledState
+	checks    []func() error
+	version   string
+	vartypes  map[string]reflect.Type // instance variables, see AssignStmt
+	globals   []stateVar              // instance variables declared using var, see VarDecl
+	warnings  []Diagnostic
+
+	// set by CheckType for Execute, kept out of the AST so a File compiles more than once
+	folded map[Statement]map[string]Value // arguments evaluated as far as possible, see foldArgs
+	locals map[*AssignStmt]reflect.Type   // assignments to variables of a state, see AssignStmt
+
+	bindingCheck bool // see WithBindingCheck
 }
 
+// StateMachine is a running instance of a CompiledMachine.
+// The CompiledMachine is shared between all instances and never modified.
 type StateMachine struct {
-	CompiledMachine
-	current *CompiledState
+	*CompiledMachine
+	ID      string
+	Meta    map[string]any
+	vars    map[string]any // assigned instance variables, see AssignStmt
+	locals  map[string]any // variables of the current state, see VarDecl
+	current atomic.Pointer[CompiledState]
+	hooks   []TransitionHook
+
+	rollback    bool
+	tx          *Tx
+	saga        bool
+	scheduler   *Scheduler
+	dedup       DedupStore
+	dedupWindow time.Duration
+	steps       []sagaStep // completed steps of the saga
+	metrics     Metrics
+	tracer      Tracer
+	journal     Journal
+	ctx         context.Context // context of the event being handled
+	trigger     int             // index of the trigger being executed, -1 if none
+	result      *Result         // result of the event being handled, see EmitResult
+
+	batchTimeout time.Duration        // see WithBatchTimeout
+	deadline     time.Time            // end of the trigger being executed, zero if unlimited
+	limit        *bucket              // see WithRateLimit
+	limits       map[string]*bucket   // by event, see WithEventRateLimit
+	middleware   []Middleware         // see Use
+	dispatchers  []DispatchMiddleware // see UseDispatch
+
+	mu      sync.Mutex
+	busy    bool
+	pending []event
+	async   sync.WaitGroup
+	running int // asynchronous actions in flight
+	debug   atomic.Pointer[debugger]
+
+	idle      bool // settled since the last event, see IdleHook
+	idleHooks []IdleHook
+	idleWait  chan struct{} // closed when settled, see WaitIdle
+
+	transbuf    int
+	transitions chan Transition
+
+	clock     Clock
+	random    *rand.PCG // see Random
+	since     time.Time // the current state was entered
+	durations map[string]time.Duration
+	timeHooks []StateTimeHook
+}
+
+type event struct {
+	ctx  context.Context
+	name string
+	data any
 }
 
+type InstanceOption func(*StateMachine)
+
+// WithID sets the instance identifier, available to the machine as `self.id`.
+func WithID(id string) InstanceOption {
+	return func(m *StateMachine) {
+		m.ID = id
+	}
+}
+
+// WithMeta attaches arbitrary data to the instance, available to the machine as `self.meta`.
+func WithMeta(meta map[string]any) InstanceOption {
+	return func(m *StateMachine) {
+		m.Meta = meta
+	}
+}
+
+// TransitionHook is called after the machine changed from one state to another, before the init actions of the new state run.
+// from is empty for the initial transition.
+type TransitionHook func(m *StateMachine, from, to string)
+
 type Condition struct {
 	TriggerName string
 	Value       map[string]any
+	Equal       map[string]func(a, b any) bool // custom comparison per event-data
+	Deferred    map[string]Value               // compared per event, as they refer to instance variables or event-data
+
+	never bool     // requires different values for the same event-data
+	keys  []string // event-data mentioned by the condition, bound when evaluating Deferred
+	types map[string]reflect.Type
 }
 
+// Test reports whether an event matches the condition, the comparisons in Deferred are not evaluated.
 func (cond Condition) Test(name string, inputs reflect.Value) bool {
 	if cond.TriggerName != name {
 		return false
 	}
+	inputs = dataValue(inputs)
 	inputtypes := inputs.Type()
 	for name, value := range cond.Value {
 		i := getTypeField(inputtypes, name)
 		if i == -1 {
 			return false
 		}
-		if value != inputs.Field(i).Interface() {
+		if eq, ok := cond.Equal[name]; ok {
+			if !eq(value, inputs.Field(i).Interface()) {
+				return false
+			}
+		} else if value != inputs.Field(i).Interface() {
 			return false
 		}
 	}
 	return true
 }
 
+// matches is Test including the comparisons in Deferred, which are evaluated in scope with the
+// event-data of the condition bound.
+func (cond Condition) matches(name string, inputs reflect.Value, scope func() map[string]Value) (bool, error) {
+	if !cond.Test(name, inputs) {
+		return false, nil
+	}
+	if len(cond.Deferred) == 0 {
+		return true, nil
+	}
+	data := dataValue(inputs)
+	ctx := scope()
+	for _, key := range cond.keys {
+		if i := getTypeField(data.Type(), key); i != -1 {
+			ctx[key] = &ConstValue{data.Field(i).Interface()}
+		}
+	}
+	for _, key := range slices.Sorted(maps.Keys(cond.Deferred)) {
+		want, err := cond.Deferred[key].EvalValue(ctx)
+		if err != nil {
+			return false, fmt.Errorf("in condition on %s: cannot evaluate value for event-data %q: %w", cond.TriggerName, key, err)
+		}
+		want = coerce(want, cond.types[key])
+		got := data.Field(getTypeField(data.Type(), key)).Interface()
+		if eq, ok := cond.Equal[key]; ok {
+			if !eq(want, got) {
+				return false, nil
+			}
+		} else if want != got {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
 type CompiledTrigger struct {
 	cond      []Condition
 	datatypes []string
+	optional  map[string]reflect.Type // event-data not mentioned by every condition, see Arg.Optional
 	actions   []Action
+	moves     []string // destinations of moves in actions
+	calls     []string // actions called by actions, see WhatIf
 }
 
 func (trg CompiledTrigger) Test(name string, inputs reflect.Value) bool {
@@ -99,46 +359,208 @@ func (trg CompiledTrigger) Test(name string, inputs reflect.Value) bool {
 }
 
 type CompiledState struct {
-	Init     []Action
-	Triggers []CompiledTrigger
+	Name       string
+	Final      bool
+	Task       string // awaited external task, "" if none
+	Params     map[string]reflect.Type
+	Init       []Action
+	Compensate []Action // nil if the state has no compensate block
+	Triggers   []CompiledTrigger
+
+	initMoves []string   // destinations of moves in Init
+	vars      []stateVar // see VarDecl
+	matches   []match    // conditions of Triggers in the order they are tried, see simplify
+	ignore    []string   // events dropped without error if no trigger handles them
+}
+
+// match returns the index of the trigger handling an event, or -1 if none does.
+func (st *CompiledState) match(name string, inputs reflect.Value, scope func() map[string]Value) (int, error) {
+	for _, mt := range st.matches {
+		if ok, err := mt.cond.matches(name, inputs, scope); err != nil {
+			return -1, err
+		} else if ok {
+			return mt.trigger, nil
+		}
+	}
+	return -1, nil
 }
 
 var ErrEmptyMachine = errors.New("empty state machine")
 
-func BuildMachine(filename string, r io.Reader, reg *Registry, constants map[string]any) (*CompiledMachine, error) {
-	p := parser{lexer: newLexer(r, rules), filename: filename}
-	ast, err := p.ParseFile()
+type BuildOption func(*buildConfig)
+
+type buildConfig struct {
+	checkMissing  bool
+	strict        bool
+	exhaustive    bool
+	bindingCheck  bool
+	template      bool
+	templateData  any
+	templateFuncs map[string]any
+	features      map[string]bool
+}
+
+// BuildMachine parses and compiles a machine, see Parse and File.Compile.
+func BuildMachine(filename string, r io.Reader, reg *Registry, constants map[string]any, opts ...BuildOption) (*CompiledMachine, error) {
+	var conf buildConfig
+	for _, opt := range opts {
+		opt(&conf)
+	}
+	if conf.template {
+		var err error
+		if r, err = expandTemplate(filename, r, &conf); err != nil {
+			return nil, err
+		}
+	}
+	f, err := Parse(filename, r, reg, opts...)
 	if err != nil {
 		return nil, err
 	}
+	return f.Compile(reg, constants, opts...)
+}
+
+// Compile type checks the entries of f and builds a machine. The machine refers to the entries,
+// which must not be modified afterwards. The constants are copied, changing the map afterwards does
+// not affect the machine, and the file cannot redefine them.
+//
+// A file parsed with other features than those of WithFeatures is parsed again from its source,
+// the machine then refers to the new entries. Without WithFeatures the branches selected when
+// parsing are compiled.
+func (f *File) Compile(reg *Registry, constants map[string]any, opts ...BuildOption) (*CompiledMachine, error) {
+	var conf buildConfig
+	for _, opt := range opts {
+		opt(&conf)
+	}
+	if conf.features != nil && !f.selects(conf.features) {
+		g, err := Parse(f.Filename, bytes.NewReader(f.src), reg, opts...)
+		if err != nil {
+			return nil, err
+		}
+		f = g
+	}
+	if conf.checkMissing {
+		if err := missing(f.Filename, f, reg); err != nil {
+			return nil, err
+		}
+	}
 
+	hash := sha256.New()
 	var m CompiledMachine
+	m.file = f
 	m.reg = reg
+	m.bindingCheck = conf.bindingCheck || conf.strict
 	m.constants = make(map[string]Value)
+	m.defined = make(map[string]Span)
 	for name, value := range constants {
 		m.constants[name] = &ConstValue{value}
 	}
+	m.constants["self.id"] = &TypeDummyValue{reflect.TypeFor[string]()}
+	m.constants["self.meta"] = &TypeDummyValue{reflect.TypeFor[map[string]any]()}
 	m.states = make(map[string]*CompiledState)
-	for _, entry := range ast.Entries {
+	m.folded = make(map[Statement]map[string]Value)
+	m.locals = make(map[*AssignStmt]reflect.Type)
+	m.warnings = deprecations(f, reg)
+	if f.Version != 0 {
+		fmt.Fprintf(hash, "mova %d;\n", f.Version)
+	}
+	if err := m.declareVars(); err != nil {
+		return nil, inFile(f.Filename, err)
+	}
+	for _, entry := range f.Entries {
 		if err := entry.EvalToplevel(&m); err != nil {
-			return nil, err
+			return nil, inFile(f.Filename, located(entrySpan(entry), err))
 		}
+		io.WriteString(hash, formatEntry(entry)+"\n")
 	}
 	if len(m.states) == 0 {
 		return nil, ErrEmptyMachine
 	}
+	for _, check := range m.checks {
+		if err := check(); err != nil {
+			return nil, inFile(f.Filename, err)
+		}
+	}
+	m.checks = nil
+	m.folded = nil
+	m.locals = nil
+	if conf.strict {
+		if err := m.strict(); err != nil {
+			return nil, err
+		}
+	}
+	m.simplify()
+	if conf.exhaustive {
+		if err := m.exhaustive(); err != nil {
+			return nil, err
+		}
+	}
+	m.version = hex.EncodeToString(hash.Sum(nil))[:16]
 	return &m, nil
 }
 
-func (cm *CompiledMachine) New() (*StateMachine, error) {
+// Version identifies the definition the machine was built from, changes to comments and layout
+// of the source keep the version.
+func (cm *CompiledMachine) Version() string {
+	return cm.version
+}
+
+func (cm *CompiledMachine) New(opts ...InstanceOption) (*StateMachine, error) {
+	m := cm.instance(opts)
+	if err := m.resetVars(); err != nil {
+		return m, err
+	}
+	err := m.move(m.order[0], nil)
+	if err != nil && m.saga {
+		if cerr := m.compensate(); cerr != nil {
+			err = errors.Join(err, cerr)
+		}
+	}
+	m.settled()
+	return m, err
+}
+
+// instance creates a machine which is not yet in any state.
+func (cm *CompiledMachine) instance(opts []InstanceOption) *StateMachine {
 	var m StateMachine
-	m.CompiledMachine = *cm
-	err := m.move(m.firstState)
-	return &m, err
+	m.init(cm, opts)
+	return &m
+}
+
+// init sets all per-instance data to its defaults and applies opts. Nothing is kept from an earlier
+// use of m, see Pool.
+func (m *StateMachine) init(cm *CompiledMachine, opts []InstanceOption) {
+	*m = StateMachine{
+		CompiledMachine: cm,
+		trigger:         -1,
+		metrics:         nopMetrics{},
+		tracer:          nopTracer{},
+		clock:           realClock{},
+	}
+	for _, opt := range opts {
+		opt(m)
+	}
+}
+
+// scope returns the variables visible to actions of this instance.
+func (m *StateMachine) scope() map[string]Value {
+	ctx := maps.Clone(m.constants)
+	ctx["self.id"] = &ConstValue{m.ID}
+	ctx["self.meta"] = &ConstValue{m.Meta}
+	for name, v := range m.Vars() {
+		ctx[name] = &ConstValue{v}
+	}
+	for name, v := range m.locals {
+		ctx[name] = &ConstValue{v}
+	}
+	return ctx
 }
 
 func (m *StateMachine) batch(actions []Action, ctx map[string]Value) error {
-	for _, action := range actions {
+	for i, action := range actions {
+		m.checkpoint(i, ctx)
+		if err := m.batchExpired(); err != nil {
+			return err
+		}
 		if err := action(m, ctx); err != nil {
 			return err
 		}
@@ -146,38 +568,233 @@ func (m *StateMachine) batch(actions []Action, ctx map[string]Value) error {
 	return nil
 }
 
-func (m *StateMachine) move(dest string) error {
+// WithTransitionHook registers hook before the machine enters its initial state.
+func WithTransitionHook(hook TransitionHook) InstanceOption {
+	return func(m *StateMachine) {
+		m.hooks = append(m.hooks, hook)
+	}
+}
+
+func (m *StateMachine) OnTransition(hook TransitionHook) {
+	m.hooks = append(m.hooks, hook)
+}
+
+func (m *StateMachine) Current() string {
+	cur := m.current.Load()
+	if cur == nil {
+		return ""
+	}
+	return cur.Name
+}
+
+// Reset moves the machine back to its initial state and runs its init actions. Instance variables
+// are set to their initial values.
+func (m *StateMachine) Reset() error {
+	if err := m.resetVars(); err != nil {
+		return err
+	}
+	err := m.move(m.order[0], nil)
+	m.settled()
+	return err
+}
+
+// ForceState moves the machine to the named state without an event, e.g. after restoring external state.
+// Parameters of the state are set to their zero value. Init actions only run if runInit is set.
+func (m *StateMachine) ForceState(name string, runInit bool) error {
+	return m.enter(name, nil, runInit)
+}
+
+// Move transitions to the named state, as `move` would. Parameters of the state are set to their zero value.
+func (m *StateMachine) Move(state string) error {
+	return m.move(state, nil)
+}
+
+func (m *StateMachine) move(dest string, args map[string]Value) error {
+	return m.enter(dest, args, true)
+}
+
+func (m *StateMachine) enter(dest string, args map[string]Value, runInit bool) error {
 	newstate, ok := m.states[dest]
 	if !ok {
 		return fmt.Errorf("unknown state %q", dest)
 	}
-	m.current = newstate
-	return m.batch(newstate.Init, m.constants)
+	prev := m.current.Load()
+	if runInit && prev != nil {
+		if err := m.exit(prev); err != nil {
+			return err
+		}
+	}
+	from := m.Current()
+	m.current.Store(newstate)
+	spent := m.entered(from)
+	if from != "" {
+		for _, hook := range m.timeHooks {
+			hook(m, from, spent)
+		}
+	}
+	m.metrics.Transition(from, dest)
+	m.record(JournalEntry{Kind: JournalTransition, From: from, To: dest})
+	if m.result != nil {
+		m.result.Moved = true
+	}
+	for _, hook := range m.hooks {
+		hook(m, from, dest)
+	}
+	m.publish(from, dest)
+	m.locals = nil
+	ctx := m.scope()
+	for name, typ := range newstate.Params {
+		if v, ok := args[name]; ok {
+			ctx[name] = v
+		} else {
+			ctx[name] = &ConstValue{reflect.Zero(typ).Interface()}
+		}
+	}
+	if err := m.resetLocals(newstate, ctx); err != nil || !runInit {
+		return err
+	}
+	done := m.beginStep(newstate, ctx)
+	err := m.batch(newstate.Init, ctx)
+	done(err)
+	return err
 }
 
+// Emit delivers an event to the current state. It returns io.EOF if no trigger matched.
+// Events emitted while another event is being handled, by actions or by completing asynchronous actions,
+// are queued and handled by the ongoing Emit after it finished.
 func (m *StateMachine) Emit(name string, v any) error {
+	return m.EmitContext(context.Background(), name, v)
+}
+
+// EmitContext is Emit with a context, which is passed to actions taking a context.Context and used as parent for tracing.
+func (m *StateMachine) EmitContext(ctx context.Context, name string, v any) error {
+	_, err := m.EmitResultContext(ctx, name, v)
+	return err
+}
+
+// drain handles the events queued while handling an event, which resulted in err.
+func (m *StateMachine) drain(err error) error {
+	var errs []error
+	for {
+		m.mu.Lock()
+		if len(m.pending) == 0 {
+			m.busy = false
+			m.mu.Unlock()
+			m.settled()
+			break
+		}
+		ev := m.pending[0]
+		m.pending = m.pending[1:]
+		m.mu.Unlock()
+
+		if qerr := m.handle(ev.ctx, ev.name, ev.data); qerr != nil && !errors.Is(qerr, io.EOF) {
+			errs = append(errs, fmt.Errorf("queued event %q: %w", ev.name, qerr))
+		}
+	}
+	if len(errs) > 0 {
+		return errors.Join(append([]error{err}, errs...)...)
+	}
+	return err
+}
+
+// checkEvent checks the event-data v of an event, it returns the registered name of the event.
+func (cm *CompiledMachine) checkEvent(name string, v any) (string, reflect.Value, error) {
 	rval := reflect.ValueOf(v)
-	etyp, ok := m.reg.triggers[name]
+	etyp, ok := cm.reg.trigger(name)
 	if !ok {
-		return fmt.Errorf("unspecified event %q", name)
+		return name, rval, fmt.Errorf("unspecified event %q", name)
 	}
+	name, _ = cm.reg.triggerName(name)
 	if etyp != rval.Type() {
-		return fmt.Errorf("invalid type for event %q, expected %v got %v", name, etyp, rval.Type())
+		return name, rval, fmt.Errorf("invalid type for event %q, expected %v got %v", name, etyp, rval.Type())
 	}
-	for _, trg := range m.current.Triggers {
-		if !trg.Test(name, rval) {
-			continue
+	return name, rval, nil
+}
+
+func (m *StateMachine) dispatch(ctx context.Context, name string, v any) (err error) {
+	name, rval, err := m.checkEvent(name, v)
+	if err != nil {
+		return err
+	}
+	m.metrics.EventEmitted(name)
+	state := m.current.Load()
+	m.record(JournalEntry{Kind: JournalEvent, State: state.Name, Event: name, Data: v})
+	ctx, end := m.tracer.Start(ctx, "emit "+name, map[string]any{
+		"mova.state": state.Name,
+		"mova.event": name,
+	})
+	defer func() {
+		end(err)
+	}()
+	m.ctx = ctx
+	defer func() {
+		m.ctx = nil
+	}()
+	err = m.fire(state, name, rval)
+	if errors.Is(err, io.EOF) {
+		m.metrics.EventUnhandled(state.Name, name)
+		m.record(JournalEntry{Kind: JournalUnhandled, State: state.Name, Event: name})
+	} else {
+		m.metrics.EventHandled(state.Name, name)
+	}
+	if err == nil || errors.Is(err, io.EOF) || name == "error" {
+		return err
+	}
+	if m.saga {
+		if cerr := m.compensate(); cerr != nil {
+			err = errors.Join(err, cerr)
 		}
+	}
+	return m.handleError(state, name, err)
+}
 
-		ctx := maps.Clone(m.constants)
-		for _, name := range trg.datatypes {
-			i := getTypeField(rval.Type(), name)
-			if i == -1 {
-				continue
-			}
-			ctx[name] = &ConstValue{rval.Field(i).Interface()}
+func (m *StateMachine) fire(state *CompiledState, name string, rval reflect.Value) error {
+	index, err := state.match(name, rval, m.scope)
+	if err != nil {
+		return err
+	} else if index == -1 && slices.Contains(state.ignore, name) {
+		if m.result != nil {
+			m.result.Ignored = true
 		}
-		return m.batch(trg.actions, ctx)
+		return nil
+	} else if index == -1 {
+		return io.EOF
 	}
-	return io.EOF
+	trg := state.Triggers[index]
+	m.trigger = index
+	if m.result != nil && m.result.Trigger == -1 {
+		m.result.Handled = true
+		m.result.Trigger = index
+	}
+	m.record(JournalEntry{Kind: JournalTrigger, State: state.Name, Event: name, Trigger: &index})
+
+	ctx := m.scope()
+	data := dataValue(rval)
+	for _, name := range trg.datatypes {
+		i := getTypeField(data.Type(), name)
+		if i == -1 {
+			continue
+		}
+		ctx[name] = &ConstValue{data.Field(i).Interface()}
+	}
+	for name, typ := range trg.optional {
+		if i := getTypeField(data.Type(), name); i != -1 && data.Type().Field(i).Type == typ {
+			ctx[name] = &ConstValue{data.Field(i).Interface()}
+		} else {
+			ctx[name] = &ConstValue{reflect.Zero(typ).Interface()}
+		}
+	}
+	if m.batchTimeout > 0 {
+		m.deadline = m.clock.Now().Add(m.batchTimeout)
+	}
+	defer func() {
+		m.trigger = -1
+		m.deadline = time.Time{}
+	}()
+	return m.transaction(trg.actions, ctx)
+}
+
+// Registry returns the registry the machine was built with.
+func (cm *CompiledMachine) Registry() *Registry {
+	return cm.reg
 }
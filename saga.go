@@ -0,0 +1,60 @@
+package mova
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+)
+
+// sagaStep is a state whose init actions completed, with the scope they ran in.
+type sagaStep struct {
+	state *CompiledState
+	ctx   map[string]Value
+}
+
+// WithSaga runs the machine as a saga: every state with a `compensate { ... };` block is a step,
+// which completes when its init actions succeed. If an action fails while handling an event,
+// the compensations of all completed steps run in reverse order, before the `error` trigger fires.
+// Reaching a final state ends the saga. Completed steps are not part of snapshots.
+func WithSaga() InstanceOption {
+	return func(m *StateMachine) {
+		m.saga = true
+	}
+}
+
+// beginStep records the step of state, it returns a function to call with the result of the init actions.
+func (m *StateMachine) beginStep(state *CompiledState, ctx map[string]Value) func(error) {
+	if !m.saga {
+		return func(error) {}
+	}
+	idx := -1
+	if state.Compensate != nil {
+		idx = len(m.steps)
+		m.steps = append(m.steps, sagaStep{state, ctx})
+	}
+	return func(err error) {
+		// a failure after the init actions moved on is not part of this step
+		if err != nil && m.current.Load() == state {
+			if idx >= 0 && idx < len(m.steps) && m.steps[idx].state == state {
+				m.steps = slices.Delete(m.steps, idx, idx+1)
+			}
+		} else if err == nil && state.Final {
+			m.steps = nil
+		}
+	}
+}
+
+// compensate runs the compensations of all completed steps in reverse order.
+func (m *StateMachine) compensate() error {
+	steps := m.steps
+	m.steps = nil
+	var errs []error
+	for i := len(steps) - 1; i >= 0; i-- {
+		step := steps[i]
+		m.record(JournalEntry{Kind: JournalCompensation, State: step.state.Name})
+		if err := m.batch(step.state.Compensate, step.ctx); err != nil {
+			errs = append(errs, fmt.Errorf("compensating %s: %w", step.state.Name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
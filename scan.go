@@ -0,0 +1,294 @@
+package mova
+
+import (
+	"bytes"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Words and characters of the builtin tokens, also used by TextMateGrammar.
+var (
+	keywords       = []string{"state", "on", "move"}
+	booleans       = []string{"true", "false"}
+	directiveWords = []string{"if", "else", "endif"}
+	punctuation    = "{}(),;=:%?"
+)
+
+// scan matches the builtin token at the start of buf, it returns its kind and length.
+// Whitespace has the empty kind, a length of 0 means no builtin token matches.
+// Where several tokens match, the longest wins and ties go to the token listed first:
+//
+//	whitespace, comment, directive, arrow, punct, string, duration, float, int, bool, keyword, identifier
+func scan(buf []byte) (string, int) {
+	if len(buf) == 0 {
+		return "", 0
+	}
+	switch c := buf[0]; {
+	case isSpace(c):
+		n := 1
+		for n < len(buf) && isSpace(buf[n]) {
+			n++
+		}
+		return "", n
+	case c == '#':
+		return "comment", lineEnd(buf)
+	case c == '@':
+		for _, word := range directiveWords {
+			if hasWord(buf[1:], word) {
+				return "directive", lineEnd(buf)
+			}
+		}
+		return "", 0
+	case c == '-' && len(buf) > 1 && buf[1] == '>':
+		return "arrow", 2
+	case strings.IndexByte(punctuation, c) != -1:
+		return "punct", 1
+	case c == '"':
+		if n := scanLongString(buf); n > 0 {
+			return "string", n
+		}
+		return "string", scanQuoted(buf, '"', true)
+	case c == '`':
+		if i := bytes.IndexByte(buf[1:], '`'); i != -1 {
+			return "string", i + 2
+		}
+		return "", 0
+	case c == '\'':
+		if n := scanQuoted(buf, '\'', false); n > 2 {
+			return "identifier", n
+		}
+		return "", 0
+	case c == '+' || c == '-' || isDigit(c):
+		kind, n := "duration", scanDuration(buf)
+		if m := scanFloat(buf); m > n {
+			kind, n = "float", m
+		}
+		if m := scanInt(buf); m > n {
+			kind, n = "int", m
+		}
+		return kind, n
+	}
+	n := scanIdent(buf)
+	for _, word := range booleans {
+		if len(word) == n && hasWord(buf, word) {
+			return "bool", n
+		}
+	}
+	for _, word := range keywords {
+		if len(word) == n && hasWord(buf, word) {
+			return "keyword", n
+		}
+	}
+	return "identifier", n
+}
+
+// isSpace reports whether c is whitespace, as \s in regular expressions.
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\f' || c == '\r'
+}
+
+func isDigit(c byte) bool {
+	return '0' <= c && c <= '9'
+}
+
+// isWord reports whether c is a word character, as \w in regular expressions.
+func isWord(c byte) bool {
+	return isDigit(c) || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || c == '_'
+}
+
+// hasWord reports whether buf starts with word, followed by a word boundary.
+func hasWord(buf []byte, word string) bool {
+	return bytes.HasPrefix(buf, []byte(word)) && (len(buf) == len(word) || !isWord(buf[len(word)]))
+}
+
+// lineEnd returns the length of buf up to the end of the line.
+func lineEnd(buf []byte) int {
+	if i := bytes.IndexByte(buf, '\n'); i != -1 {
+		return i
+	}
+	return len(buf)
+}
+
+// scanEscape returns the length of the escape sequence at the start of buf, 0 if it is incomplete.
+// Multiline escapes may escape a newline.
+func scanEscape(buf []byte, multiline bool) int {
+	if len(buf) < 2 || buf[1] == '\n' && !multiline {
+		return 0
+	}
+	_, sz := utf8.DecodeRune(buf[1:])
+	return 1 + sz
+}
+
+// scanLongString matches a """-delimited string, which is unterminated at the end of buf.
+func scanLongString(buf []byte) int {
+	if !bytes.HasPrefix(buf, []byte(`"""`)) {
+		return 0
+	}
+	for i := 3; ; {
+		switch {
+		case bytes.HasPrefix(buf[i:], []byte(`"""`)):
+			return i + 3
+		case i == len(buf):
+			return i
+		case buf[i] == '\\':
+			n := scanEscape(buf[i:], true)
+			if n == 0 {
+				return 0
+			}
+			i += n
+		default:
+			i++
+		}
+	}
+}
+
+// scanQuoted matches a string or identifier delimited by quote. Newlines are not allowed
+// in identifiers, neither escaped nor unescaped.
+func scanQuoted(buf []byte, quote byte, newlines bool) int {
+	for i := 1; i < len(buf); {
+		switch buf[i] {
+		case quote:
+			return i + 1
+		case '\\':
+			n := scanEscape(buf[i:], false)
+			if n == 0 {
+				return 0
+			}
+			i += n
+		case '\n':
+			if !newlines {
+				return 0
+			}
+			i++
+		default:
+			i++
+		}
+	}
+	return 0
+}
+
+// scanDigits matches digits, optionally separated by single underscores, at buf[i:].
+// It returns the end of the digits or i if there are none. Unless leading, the first digit may
+// be preceded by an underscore.
+func scanDigits(buf []byte, i int, leading bool, digit func(byte) bool) int {
+	start := i
+	for i < len(buf) {
+		if digit(buf[i]) {
+			i++
+		} else if buf[i] == '_' && (i > start || !leading) && i+1 < len(buf) && digit(buf[i+1]) {
+			i += 2
+		} else {
+			break
+		}
+	}
+	return i
+}
+
+// sign returns the length of an optional sign at the start of buf.
+func sign(buf []byte) int {
+	if len(buf) > 0 && (buf[0] == '+' || buf[0] == '-') {
+		return 1
+	}
+	return 0
+}
+
+func scanFloat(buf []byte) int {
+	i := sign(buf)
+	j := scanDigits(buf, i, true, isDigit)
+	if j == i || j == len(buf) || buf[j] != '.' {
+		return 0
+	}
+	return scanDigits(buf, j+1, true, isDigit)
+}
+
+func scanInt(buf []byte) int {
+	i := sign(buf)
+	if len(buf) > i+1 && buf[i] == '0' {
+		var digit func(byte) bool
+		switch buf[i+1] {
+		case 'x', 'X':
+			digit = func(c byte) bool { return isDigit(c) || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F' }
+		case 'b', 'B':
+			digit = func(c byte) bool { return c == '0' || c == '1' }
+		case 'o', 'O':
+			digit = func(c byte) bool { return '0' <= c && c <= '7' }
+		}
+		if digit != nil {
+			if j := scanDigits(buf, i+2, false, digit); j > i+2 {
+				return j
+			}
+		}
+	}
+	if j := scanDigits(buf, i, true, isDigit); j > i {
+		return j
+	}
+	return 0
+}
+
+var durationUnits = []string{"ns", "us", "ms", "s", "m", "h"}
+
+// scanDuration matches a duration like 1h30m, whose last unit ends at a word boundary.
+func scanDuration(buf []byte) int {
+	if n := scanDurationAt(buf, sign(buf)); n > 0 {
+		return n
+	}
+	return 0
+}
+
+// scanDurationAt matches one or more numbers with a unit at buf[i:], it returns -1 on failure.
+func scanDurationAt(buf []byte, i int) int {
+	j := i
+	for j < len(buf) && isDigit(buf[j]) {
+		j++
+	}
+	if j == i {
+		return -1
+	}
+	if j < len(buf) && buf[j] == '.' {
+		for j++; j < len(buf) && isDigit(buf[j]); j++ {
+		}
+	}
+	for _, unit := range durationUnits {
+		if !bytes.HasPrefix(buf[j:], []byte(unit)) {
+			continue
+		}
+		end := j + len(unit)
+		if n := scanDurationAt(buf, end); n != -1 {
+			return n
+		}
+		if end == len(buf) || !isWord(buf[end]) {
+			return end
+		}
+	}
+	return -1
+}
+
+// scanIdent matches an identifier like a.b, it returns 0 if buf does not start with one.
+func scanIdent(buf []byte) int {
+	n := scanName(buf)
+	if n == 0 {
+		return 0
+	}
+	for n+1 < len(buf) && buf[n] == '.' {
+		m := scanName(buf[n+1:])
+		if m == 0 {
+			break
+		}
+		n += 1 + m
+	}
+	return n
+}
+
+// scanName matches a single letter or underscore, followed by letters, digits and underscores.
+func scanName(buf []byte) int {
+	n := 0
+	for n < len(buf) {
+		r, sz := utf8.DecodeRune(buf[n:])
+		if !(r == '_' || unicode.IsLetter(r) || n > 0 && unicode.IsNumber(r)) {
+			break
+		}
+		n += sz
+	}
+	return n
+}
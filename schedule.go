@@ -0,0 +1,226 @@
+package mova
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"time"
+)
+
+var ErrNoScheduler = errors.New("no scheduler")
+
+// ScheduleStmt emits an event to the machine after a delay, written as
+//
+//	schedule reminder(kind="payment") after 24h
+//
+// The event is kept in the store of the scheduler of the machine, see WithScheduler.
+type ScheduleStmt struct {
+	Span  Span
+	Event string
+	Args  map[string]Value
+	After time.Duration
+}
+
+func (ss *ScheduleStmt) CheckType(ctx map[string]Value, m *CompiledMachine) error {
+	typ, ok := m.reg.trigger(ss.Event)
+	if !ok {
+		return fmt.Errorf("unspecified event %q", ss.Event)
+	}
+	typ = dataType(typ)
+	if ss.After <= 0 {
+		return fmt.Errorf("delay of scheduled event %s must be positive", ss.Event)
+	}
+	for _, key := range slices.Sorted(maps.Keys(ss.Args)) {
+		i := getTypeField(typ, key)
+		if i == -1 || !typ.Field(i).IsExported() {
+			return fmt.Errorf("unspecified event-data %q for event %s", key, ss.Event)
+		}
+		argtype, err := ss.Args[key].EvalType(ctx)
+		if err != nil {
+			return fmt.Errorf("cannot determine type of event-data %q: %w", key, err)
+		}
+		if !coercible(argtype, typ.Field(i).Type) {
+			return fmt.Errorf("type mismatch for event-data %s.%s: expected %v, got %s", ss.Event, key, typ.Field(i).Type, typeString(argtype))
+		}
+	}
+	folded, err := foldArgs(ss.Args, ctx)
+	if err != nil {
+		return err
+	}
+	m.folded[ss] = folded
+	return nil
+}
+
+func (ss *ScheduleStmt) Execute(cm *CompiledMachine) Action {
+	sargs := ss.Args
+	if folded, ok := cm.folded[ss]; ok {
+		sargs = folded
+	}
+	typ, _ := cm.reg.trigger(ss.Event)
+	typ = dataType(typ)
+	return func(m *StateMachine, ctx map[string]Value) error {
+		// event-data is stored by field name, as understood by Registry.Decode
+		data := make(map[string]any, len(sargs))
+		for key, value := range sargs {
+			eval, err := value.EvalValue(ctx)
+			if err != nil {
+				return err
+			}
+			field := typ.Field(getTypeField(typ, key))
+			data[field.Name] = coerce(eval, field.Type)
+		}
+		return m.schedule(ss.Event, data, ss.After)
+	}
+}
+
+func (ss *ScheduleStmt) String() string {
+	return "schedule " + formatName(ss.Event) + formatArgs(ss.Args) + " after " + ss.After.String()
+}
+
+// ScheduledEvent is an event to be delivered to an instance once it is due.
+type ScheduledEvent struct {
+	ID       string          `json:"id"`
+	Instance string          `json:"instance"`
+	At       time.Time       `json:"at"`
+	Event    string          `json:"event"`
+	Data     json.RawMessage `json:"data,omitempty"` // decoded using Registry.Decode
+}
+
+// EventStore persists scheduled events, so they survive restarts of the process.
+type EventStore interface {
+	SaveEvent(ctx context.Context, ev ScheduledEvent) error
+	// DueEvents returns the events due at until, ordered by time.
+	DueEvents(ctx context.Context, until time.Time) ([]ScheduledEvent, error)
+	DeleteEvent(ctx context.Context, id string) error
+}
+
+// Scheduler keeps events scheduled by machines in its store and delivers them once they are due.
+type Scheduler struct {
+	Store    EventStore
+	Interval time.Duration               // how often the store is polled by Run, a second if zero
+	Clock    Clock                       // the system clock if nil
+	OnError  func(ScheduledEvent, error) // called for events which could not be delivered, may be nil
+}
+
+// WithScheduler makes the `schedule` statement of the machine store events in s.
+func WithScheduler(s *Scheduler) InstanceOption {
+	return func(m *StateMachine) {
+		m.scheduler = s
+	}
+}
+
+func (s *Scheduler) clock() Clock {
+	if s.Clock == nil {
+		return realClock{}
+	}
+	return s.Clock
+}
+
+// Schedule stores event with data for the instance named id, to be delivered after d.
+func (s *Scheduler) Schedule(ctx context.Context, id, event string, data any, d time.Duration) error {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("unable to encode event-data of scheduled event %s: %w", event, err)
+	}
+	var key [8]byte
+	rand.Read(key[:])
+	return s.Store.SaveEvent(ctx, ScheduledEvent{
+		ID:       hex.EncodeToString(key[:]),
+		Instance: id,
+		At:       s.clock().Now().Add(d),
+		Event:    event,
+		Data:     raw,
+	})
+}
+
+func (m *StateMachine) schedule(event string, data any, d time.Duration) error {
+	if m.scheduler == nil {
+		return fmt.Errorf("%w: cannot schedule %s", ErrNoScheduler, event)
+	}
+	ctx := m.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return m.scheduler.Schedule(ctx, m.ID, event, data, d)
+}
+
+// Deliver emits the due events to the instances of mg and removes them from the store.
+// Events not handled by the instance are dropped, other failures are passed to OnError.
+// It returns the number of delivered events.
+func (s *Scheduler) Deliver(ctx context.Context, mg *Manager) (int, error) {
+	due, err := s.Store.DueEvents(ctx, s.clock().Now())
+	if err != nil {
+		return 0, err
+	}
+	n := 0
+	for _, ev := range due {
+		v, err := mg.Machine().Registry().Decode(ev.Event, ev.Data)
+		if err == nil {
+			err = mg.Emit(ctx, ev.Instance, ev.Event, v)
+		}
+		if err != nil && !errors.Is(err, io.EOF) && s.OnError != nil {
+			s.OnError(ev, err)
+		}
+		if err := s.Store.DeleteEvent(ctx, ev.ID); err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, nil
+}
+
+// Run calls Deliver every Interval until ctx is cancelled.
+func (s *Scheduler) Run(ctx context.Context, mg *Manager) error {
+	interval := s.Interval
+	if interval <= 0 {
+		interval = time.Second
+	}
+	for {
+		if _, err := s.Deliver(ctx, mg); err != nil && s.OnError != nil {
+			s.OnError(ScheduledEvent{}, err)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-s.clock().After(interval):
+		}
+	}
+}
+
+func (ms *MemoryStore) SaveEvent(ctx context.Context, ev ScheduledEvent) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	if ms.events == nil {
+		ms.events = make(map[string]ScheduledEvent)
+	}
+	ms.events[ev.ID] = ev
+	return nil
+}
+
+func (ms *MemoryStore) DueEvents(ctx context.Context, until time.Time) ([]ScheduledEvent, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	var due []ScheduledEvent
+	for _, ev := range ms.events {
+		if !ev.At.After(until) {
+			due = append(due, ev)
+		}
+	}
+	slices.SortFunc(due, func(a, b ScheduledEvent) int {
+		return a.At.Compare(b.At)
+	})
+	return due, nil
+}
+
+func (ms *MemoryStore) DeleteEvent(ctx context.Context, id string) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	delete(ms.events, id)
+	return nil
+}
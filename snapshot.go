@@ -0,0 +1,107 @@
+package mova
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"reflect"
+)
+
+var ErrIncompatibleSnapshot = errors.New("incompatible snapshot")
+
+// Snapshot is the persistable state of a machine instance.
+type Snapshot struct {
+	Version string         `json:"version"`
+	ID      string         `json:"id,omitempty"`
+	Meta    map[string]any `json:"meta,omitempty"`
+	State   string         `json:"state"`
+	Random  []byte         `json:"random,omitempty"` // state of the random source, see WithSeed
+	Vars    map[string]any `json:"vars,omitempty"`   // assigned instance variables, see StateMachine.Vars
+	Locals  map[string]any `json:"locals,omitempty"` // variables of the state, see StateMachine.Locals
+}
+
+// Migration converts a snapshot taken from another version of a machine.
+type Migration func(Snapshot) (Snapshot, error)
+
+func (m *StateMachine) Snapshot() Snapshot {
+	return Snapshot{
+		Version: m.version,
+		ID:      m.ID,
+		Meta:    m.Meta,
+		State:   m.Current(),
+		Random:  m.randomState(),
+		Vars:    maps.Clone(m.vars),
+		Locals:  maps.Clone(m.locals),
+	}
+}
+
+// CompatibleWith reports whether s was taken from a machine built from the same source as cm.
+func (cm *CompiledMachine) CompatibleWith(s Snapshot) error {
+	if s.Version != cm.version {
+		return fmt.Errorf("%w: taken from version %s, machine is version %s", ErrIncompatibleSnapshot, s.Version, cm.version)
+	}
+	return nil
+}
+
+// Restore creates an instance in the state recorded by s, without running init actions.
+// Variables missing in s have their initial value. If s is incompatible, it is passed to migrate,
+// or rejected if migrate is nil.
+func (cm *CompiledMachine) Restore(s Snapshot, migrate Migration, opts ...InstanceOption) (*StateMachine, error) {
+	if err := cm.CompatibleWith(s); err != nil {
+		if migrate == nil {
+			return nil, err
+		}
+		migrated, err := migrate(s)
+		if err != nil {
+			return nil, fmt.Errorf("unable to migrate snapshot from version %s: %w", s.Version, err)
+		}
+		s = migrated
+	}
+	if _, ok := cm.states[s.State]; !ok {
+		return nil, fmt.Errorf("%w: unknown state %q", ErrIncompatibleSnapshot, s.State)
+	}
+	restored := []InstanceOption{WithID(s.ID), WithMeta(s.Meta)}
+	if s.Random != nil {
+		restored = append(restored, restoreRandom(s.Random))
+	}
+	m := cm.instance(append(restored, opts...))
+	if err := m.resetVars(); err != nil {
+		return nil, err
+	}
+	var err error
+	if m.vars, err = restoreVars(m.vars, s.Vars, cm.vartypes); err != nil {
+		return nil, err
+	}
+	// variables of the state are reset on entering, the initial values may depend on instance variables
+	if err := m.ForceState(s.State, false); err != nil {
+		return nil, err
+	}
+	locals := make(map[string]reflect.Type)
+	for _, v := range cm.states[s.State].vars {
+		locals[v.name] = v.typ
+	}
+	if m.locals, err = restoreVars(m.locals, s.Locals, locals); err != nil {
+		return nil, err
+	}
+	m.settled()
+	return m, nil
+}
+
+// restoreVars sets the variables in vars to the ones of a snapshot, which have the given types.
+func restoreVars(vars, snapshot map[string]any, types map[string]reflect.Type) (map[string]any, error) {
+	for name, v := range snapshot {
+		typ := types[name]
+		if typ == nil {
+			return nil, fmt.Errorf("%w: unknown variable %q", ErrIncompatibleSnapshot, name)
+		}
+		v, err := restoreValue(v, typ)
+		if err != nil {
+			return nil, fmt.Errorf("%w: variable %q: %v", ErrIncompatibleSnapshot, name, err)
+		}
+		if vars == nil {
+			vars = make(map[string]any)
+		}
+		vars[name] = v
+	}
+	return vars, nil
+}
@@ -0,0 +1,192 @@
+package mova
+
+import (
+	"maps"
+	"slices"
+	"strings"
+)
+
+// Stats summarizes the size of a machine, see CompiledMachine.Stats.
+type Stats struct {
+	States       int
+	Triggers     int
+	Conditions   int
+	Actions      int            // action calls, in init actions and triggers
+	Moves        int            // move statements
+	Events       int            // distinct events consumed by triggers
+	Constants    int            // distinct constants referenced, defined in the source or passed to BuildMachine
+	FanOut       map[string]int // number of states handling each event
+	UnusedEvents []string       // registered triggers no state handles, sorted
+}
+
+// valueRefs calls fn with every variable referenced by v.
+func valueRefs(v Value, fn func(string)) {
+	switch v := v.(type) {
+	case *ReferenceValue:
+		fn(v.Ref)
+		// a field of a variable references the variable
+		for i := strings.LastIndex(v.Ref, "."); i != -1; i = strings.LastIndex(v.Ref[:i], ".") {
+			fn(v.Ref[:i])
+		}
+	case *CastValue:
+		valueRefs(v.Value, fn)
+	case *FuncValue:
+		for _, arg := range v.Args {
+			valueRefs(arg, fn)
+		}
+	}
+}
+
+func statementArgs(stmt Statement) map[string]Value {
+	switch s := stmt.(type) {
+	case *Call:
+		return s.Args
+	case *MoveStmt:
+		return s.Args
+	case *ScheduleStmt:
+		return s.Args
+	}
+	return nil
+}
+
+// Stats counts the elements of the machine.
+func (cm *CompiledMachine) Stats() Stats {
+	st := Stats{FanOut: make(map[string]int)}
+	constants := make(map[string]bool)
+	refs := func(v Value, local map[string]bool) {
+		valueRefs(v, func(name string) {
+			if _, ok := cm.constants[name]; ok && !local[name] && !strings.HasPrefix(name, "self.") && cm.vartypes[name] == nil {
+				constants[name] = true
+			}
+		})
+	}
+	var statements func(stmts []Statement, local map[string]bool)
+	statements = func(stmts []Statement, local map[string]bool) {
+		for _, stmt := range stmts {
+			switch s := stmt.(type) {
+			case *Call:
+				st.Actions++
+			case *CaptureStmt:
+				st.Actions++
+				for _, v := range s.Call.Args {
+					refs(v, local)
+				}
+				local[s.Name] = true
+			case *IfStmt:
+				refs(s.Cond, local)
+				statements(slices.Concat(s.Then, s.Else), local)
+			case *ParallelStmt:
+				statements(s.Actions, local)
+			case *MoveStmt:
+				st.Moves++
+			case *ChoiceStmt:
+				st.Moves++
+				for _, b := range s.Branches {
+					for _, v := range b.Move.Args {
+						refs(v, local)
+					}
+				}
+			}
+			for _, v := range statementArgs(stmt) {
+				refs(v, local)
+			}
+			if as, ok := stmt.(*AssignStmt); ok {
+				refs(as.Value, local)
+			}
+		}
+	}
+	for _, entry := range cm.file.Entries {
+		switch e := entry.(type) {
+		case *SetStmt:
+			refs(e.Value, nil)
+		case *VarDecl:
+			if e.Value != nil {
+				refs(e.Value, nil)
+			}
+		case *State:
+			st.States++
+			params := make(map[string]bool)
+			for _, p := range e.Params {
+				params[p.Name] = true
+			}
+			for _, decl := range e.Vars {
+				if decl.Value != nil {
+					refs(decl.Value, params)
+				}
+				params[decl.Name] = true
+			}
+			statements(e.Init, params)
+			statements(e.Compensate, params)
+			handled := make(map[string]bool)
+			for _, trg := range e.Triggers {
+				st.Triggers++
+				local := make(map[string]bool)
+				for _, decl := range e.Vars {
+					local[decl.Name] = true
+				}
+				for _, c := range trg.Cond {
+					st.Conditions++
+					handled[c.Name] = true
+					for _, p := range c.Params {
+						if p.Value != nil {
+							refs(p.Value, nil)
+						}
+						local[p.Key] = true
+					}
+				}
+				statements(trg.Actions, local)
+			}
+			for name := range handled {
+				st.FanOut[name]++
+			}
+		}
+	}
+	st.Events = len(st.FanOut)
+	st.Constants = len(constants)
+	for _, name := range slices.Sorted(maps.Keys(cm.reg.triggers)) {
+		if st.FanOut[name] == 0 {
+			st.UnusedEvents = append(st.UnusedEvents, name)
+		}
+	}
+	return st
+}
+
+// EventUsage describes how a machine consumes an event, see CompiledMachine.Events.
+type EventUsage struct {
+	Name   string
+	States []string // states with a trigger on the event, sorted
+	Fields []string // event-data mentioned in conditions, sorted
+}
+
+// Events lists the events the machine handles, sorted by name.
+func (cm *CompiledMachine) Events() []EventUsage {
+	states := make(map[string]map[string]bool)
+	fields := make(map[string]map[string]bool)
+	for _, entry := range cm.file.Entries {
+		st, ok := entry.(*State)
+		if !ok {
+			continue
+		}
+		for _, trg := range st.Triggers {
+			for _, c := range trg.Cond {
+				if states[c.Name] == nil {
+					states[c.Name] = make(map[string]bool)
+					fields[c.Name] = make(map[string]bool)
+				}
+				states[c.Name][st.Name] = true
+				for _, p := range c.Params {
+					fields[c.Name][p.Key] = true
+				}
+			}
+		}
+	}
+	var out []EventUsage
+	for _, name := range slices.Sorted(maps.Keys(states)) {
+		out = append(out, EventUsage{
+			Name:   name,
+			States: slices.Sorted(maps.Keys(states[name])),
+			Fields: slices.Sorted(maps.Keys(fields[name])),
+		})
+	}
+	return out
+}
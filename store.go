@@ -0,0 +1,51 @@
+package mova
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+var ErrNotStored = errors.New("snapshot not stored")
+
+// Store persists snapshots of instances by their ID, see Lifecycle.
+type Store interface {
+	Save(ctx context.Context, s Snapshot) error
+	// Load returns ErrNotStored if there is no snapshot for id.
+	Load(ctx context.Context, id string) (Snapshot, error)
+	Delete(ctx context.Context, id string) error
+}
+
+// MemoryStore is a Store and EventStore keeping snapshots and scheduled events in memory.
+type MemoryStore struct {
+	mu        sync.Mutex
+	snapshots map[string]Snapshot
+	events    map[string]ScheduledEvent
+}
+
+func (ms *MemoryStore) Save(ctx context.Context, s Snapshot) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	if ms.snapshots == nil {
+		ms.snapshots = make(map[string]Snapshot)
+	}
+	ms.snapshots[s.ID] = s
+	return nil
+}
+
+func (ms *MemoryStore) Load(ctx context.Context, id string) (Snapshot, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	s, ok := ms.snapshots[id]
+	if !ok {
+		return Snapshot{}, ErrNotStored
+	}
+	return s, nil
+}
+
+func (ms *MemoryStore) Delete(ctx context.Context, id string) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	delete(ms.snapshots, id)
+	return nil
+}
@@ -0,0 +1,131 @@
+package mova
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// WithStrict makes BuildMachine reject declarations which are likely mistakes: constants shadowed
+// by event-data or variables of states, unused constants, unused event-data and states which cannot be left but are not
+// marked `final`. It implies WithBindingCheck. Statements added by extensions are not looked into. All violations are returned at once, each as *CompileError.
+func WithStrict() BuildOption {
+	return func(c *buildConfig) {
+		c.strict = true
+	}
+}
+
+// WithBindingCheck makes BuildMachine reject event-data bound by some, but not all conditions of
+// a trigger, unless marked as optional. Otherwise it is dropped with a warning, see Warnings.
+func WithBindingCheck() BuildOption {
+	return func(c *buildConfig) {
+		c.bindingCheck = true
+	}
+}
+
+// references returns the variables referenced by the values below node.
+func references(node Node) map[string]bool {
+	refs := make(map[string]bool)
+	Inspect(node, func(n Node) bool {
+		if v, ok := n.(Value); ok {
+			valueRefs(v, func(name string) { refs[name] = true })
+		}
+		return true
+	})
+	return refs
+}
+
+// strict checks the rules of WithStrict.
+func (cm *CompiledMachine) strict() error {
+	var errs []*CompileError
+	fail := func(span Span, format string, args ...any) {
+		errs = append(errs, &CompileError{Filename: cm.file.Filename, Span: span, Err: fmt.Errorf(format, args...)})
+	}
+	used := make(map[string]bool)
+	use := func(refs map[string]bool, local map[string]bool) {
+		for name := range refs {
+			if !local[name] {
+				used[name] = true
+			}
+		}
+	}
+	for _, entry := range cm.file.Entries {
+		switch e := entry.(type) {
+		case *SetStmt:
+			use(references(e.Value), nil)
+		case *VarDecl:
+			use(references(e), nil)
+		case *State:
+			params := make(map[string]bool)
+			for _, p := range e.Params {
+				params[p.Name] = true
+			}
+			for _, decl := range e.Vars {
+				use(references(decl), params)
+				if _, ok := cm.constants[decl.Name]; ok && cm.vartypes[decl.Name] == nil {
+					fail(decl.Span, "constant %q is shadowed by variable of state %s", decl.Name, e.Name)
+				}
+				params[decl.Name] = true
+			}
+			for _, stmt := range slices.Concat(e.Init, e.Compensate) {
+				use(references(stmt), params)
+			}
+			for i := range e.Triggers {
+				trg := &e.Triggers[i]
+				bound := make(map[string]bool)
+				for _, decl := range e.Vars {
+					bound[decl.Name] = true
+				}
+				for _, c := range trg.Cond {
+					for _, p := range c.Params {
+						if p.Value != nil {
+							use(references(p.Value), nil)
+							continue
+						}
+						if _, ok := cm.constants[p.Key]; ok && !strings.HasPrefix(p.Key, "self.") {
+							fail(trg.Span, "constant %q is shadowed by event-data of trigger %s", p.Key, c.Name)
+						}
+						bound[p.Key] = true
+					}
+				}
+				refs := make(map[string]bool)
+				for _, stmt := range trg.Actions {
+					for name := range references(stmt) {
+						refs[name] = true
+					}
+					Inspect(stmt, func(n Node) bool {
+						if cs, ok := n.(*CaptureStmt); ok {
+							bound[cs.Name] = true
+						}
+						return true
+					})
+				}
+				for _, c := range trg.Cond {
+					for _, p := range c.Params {
+						if p.Value == nil && !refs[p.Key] {
+							fail(trg.Span, "unused event-data %q of trigger %s", p.Key, c.Name)
+						}
+					}
+				}
+				use(refs, bound)
+			}
+			if st := cm.states[e.Name]; !st.Final && len(cm.edges(st, nil)) == 0 {
+				fail(e.Span, "state %s cannot be left and is not final", e.Name)
+			}
+		}
+	}
+	for _, entry := range cm.file.Entries {
+		if e, ok := entry.(*SetStmt); ok && !used[e.Key] {
+			fail(e.Span, "unused constant %q", e.Key)
+		}
+	}
+	slices.SortStableFunc(errs, func(a, b *CompileError) int {
+		return a.Span.Start.Offset - b.Span.Start.Offset
+	})
+	var out []error
+	for _, err := range errs {
+		out = append(out, err)
+	}
+	return errors.Join(out...)
+}
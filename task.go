@@ -0,0 +1,62 @@
+package mova
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+var ErrNoTask = errors.New("task not awaited")
+
+// Task is an external task an instance waits for in a state declared `awaiting task "<name>"`.
+// It is completed by emitting the event of the same name, see Manager.CompleteTask.
+type Task struct {
+	Instance string    `json:"instance"`
+	Name     string    `json:"name"`
+	State    string    `json:"state"`
+	Since    time.Time `json:"since"` // the state was entered
+}
+
+// AwaitedTask returns the task the machine waits for in its current state.
+func (m *StateMachine) AwaitedTask() (Task, bool) {
+	cur := m.current.Load()
+	if cur == nil || cur.Task == "" {
+		return Task{}, false
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return Task{Instance: m.ID, Name: cur.Task, State: cur.Name, Since: m.since}, true
+}
+
+// Tasks returns the tasks awaited by the instances in memory, sorted by instance.
+func (mg *Manager) Tasks() []Task {
+	mg.mu.Lock()
+	defer mg.mu.Unlock()
+	var tasks []Task
+	for _, inst := range mg.instances {
+		if t, ok := inst.m.AwaitedTask(); ok {
+			tasks = append(tasks, t)
+		}
+	}
+	slices.SortFunc(tasks, func(a, b Task) int {
+		return strings.Compare(a.Instance, b.Instance)
+	})
+	return tasks
+}
+
+// CompleteTask emits the event named task with data to the instance named id.
+// It returns ErrNoTask if the instance is not waiting for task.
+func (mg *Manager) CompleteTask(ctx context.Context, id, task string, data any) error {
+	return mg.deliver(ctx, id, task, Event{Name: task, Data: data})
+}
+
+// awaits returns an error unless m waits for task.
+func awaits(m *StateMachine, task string) error {
+	if t, ok := m.AwaitedTask(); !ok || t.Name != task {
+		return fmt.Errorf("%w: instance %q does not wait for %q", ErrNoTask, m.ID, task)
+	}
+	return nil
+}
@@ -0,0 +1,45 @@
+package mova
+
+import (
+	"bytes"
+	"io"
+	"text/template"
+)
+
+// WithTemplate makes BuildMachine execute the source as text/template with data before parsing it,
+// locations in errors refer to the expanded source. Besides funcs, templates can use `quote` to
+// write a string literal and `ident` to write a name, quoted if necessary:
+//
+//	{{range .Buttons}}
+//	state {{ident .}} { on press -> log(msg={{quote .}}); };
+//	{{end}}
+func WithTemplate(data any, funcs template.FuncMap) BuildOption {
+	return func(c *buildConfig) {
+		c.template = true
+		c.templateData = data
+		c.templateFuncs = funcs
+	}
+}
+
+func expandTemplate(filename string, r io.Reader, conf *buildConfig) (io.Reader, error) {
+	src, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	tmpl, err := template.New(filename).
+		Option("missingkey=error").
+		Funcs(template.FuncMap{
+			"quote": func(s string) string { return formatValue(&ConstValue{s}) },
+			"ident": formatName,
+		}).
+		Funcs(conf.templateFuncs).
+		Parse(string(src))
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, conf.templateData); err != nil {
+		return nil, err
+	}
+	return &buf, nil
+}
@@ -0,0 +1,23 @@
+package mova
+
+import "context"
+
+// Tracer creates spans for the handling of events and the execution of actions, see WithTracer.
+// end is called with the resulting error once the span is finished.
+type Tracer interface {
+	Start(ctx context.Context, name string, attrs map[string]any) (_ context.Context, end func(err error))
+}
+
+// WithTracer reports every handled event and every executed action as span to t.
+// Spans of events are children of the context passed to EmitContext, spans of actions are children of their event.
+func WithTracer(t Tracer) InstanceOption {
+	return func(m *StateMachine) {
+		m.tracer = t
+	}
+}
+
+type nopTracer struct{}
+
+func (nopTracer) Start(ctx context.Context, _ string, _ map[string]any) (context.Context, func(error)) {
+	return ctx, func(error) {}
+}
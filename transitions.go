@@ -0,0 +1,48 @@
+package mova
+
+import "time"
+
+// DefaultTransitionBuffer is the capacity of the channel returned by Transitions.
+const DefaultTransitionBuffer = 16
+
+type Transition struct {
+	From string
+	To   string
+	Time time.Time
+}
+
+// WithTransitionBuffer sets the capacity of the channel returned by Transitions.
+func WithTransitionBuffer(n int) InstanceOption {
+	return func(m *StateMachine) {
+		m.transbuf = n
+	}
+}
+
+// Transitions returns a channel receiving every following state change.
+// If the channel is full, transitions are dropped rather than blocking the machine.
+// The channel is created on first use and never closed.
+func (m *StateMachine) Transitions() <-chan Transition {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.transitions == nil {
+		size := m.transbuf
+		if size <= 0 {
+			size = DefaultTransitionBuffer
+		}
+		m.transitions = make(chan Transition, size)
+	}
+	return m.transitions
+}
+
+func (m *StateMachine) publish(from, to string) {
+	m.mu.Lock()
+	ch := m.transitions
+	m.mu.Unlock()
+	if ch == nil {
+		return
+	}
+	select {
+	case ch <- Transition{From: from, To: to, Time: m.clock.Now()}:
+	default:
+	}
+}
@@ -0,0 +1,90 @@
+package mova
+
+import (
+	"strings"
+	"testing"
+)
+
+type keyEvent struct {
+	ID   string
+	Held bool
+}
+
+type clickEvent struct {
+	ID   int
+	Held bool
+}
+
+// TestTriggerBinding checks that event-data mentioned by several conditions of a trigger is bound to
+// the value of the event which fired it.
+func TestTriggerBinding(t *testing.T) {
+	var reg Registry
+	NewTrigger[bindEvent](&reg, "press")
+	NewTrigger[clickEvent](&reg, "click")
+	var got []any
+	NewAction(&reg, "show", []string{"v"}, func(v any) { got = append(got, v) })
+	NewAction(&reg, "held", []string{"v"}, func(v bool) { got = append(got, v) })
+	src := `state a {
+		on press(ID), click(ID) -> show(v=ID);
+		on click(ID=7, Held?), press(ID=8) -> held(v=Held);
+	};`
+	cm, err := BuildMachine("test.mova", strings.NewReader(src), &reg, nil, WithBindingCheck())
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, _ := cm.New()
+	events := []struct {
+		name string
+		data any
+		want any
+	}{
+		{"press", bindEvent{ID: 1}, 1},
+		{"click", clickEvent{ID: 2}, 2},
+		{"click", clickEvent{ID: 7, Held: true}, true},
+		{"press", bindEvent{ID: 8}, false},
+	}
+	for _, ev := range events {
+		got = nil
+		if err := m.Emit(ev.name, ev.data); err != nil {
+			t.Fatal(err)
+		}
+		if len(got) != 1 || got[0] != ev.want {
+			t.Errorf("%s %+v: got %v, want %v", ev.name, ev.data, got, ev.want)
+		}
+	}
+}
+
+func TestTriggerBindingErrors(t *testing.T) {
+	var reg Registry
+	NewTrigger[bindEvent](&reg, "press")
+	NewTrigger[keyEvent](&reg, "key")
+	NewTrigger[clickEvent](&reg, "click")
+	NewAction(&reg, "show", []string{"v"}, func(v any) {})
+	tests := []struct {
+		src   string
+		check bool // see WithBindingCheck
+		err   string
+	}{
+		{`state a { on press(ID), key(ID) -> show(v=ID); };`, false,
+			`in trigger a#0: type mismatch for event-data "ID": int in condition #0, but string in condition #1`},
+		{`state a { on press(ID), click(ID=1), key(ID) -> show(v=ID); };`, false,
+			`type mismatch for event-data "ID": int in condition #0, but string in condition #2`},
+		{`state a { on key(Held), press, click(ID, Held) -> show(v=1); };`, true,
+			`in trigger a#0: event-data "Held" not mentioned in condition #1`},
+		// dropped from the trigger without the check
+		{`state a { on click(Held), press -> show(v=Held); };`, false,
+			`undefined variable "Held"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.src, func(t *testing.T) {
+			var opts []BuildOption
+			if tt.check {
+				opts = append(opts, WithBindingCheck())
+			}
+			_, err := BuildMachine("test.mova", strings.NewReader(tt.src), &reg, nil, opts...)
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Fatalf("got error %v, want %q", err, tt.err)
+			}
+		})
+	}
+}
@@ -0,0 +1,69 @@
+package mova
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+// Tx collects compensations of the actions executed by a trigger.
+// An action receives it by declaring a *Tx parameter.
+type Tx struct {
+	mu    sync.Mutex
+	undos []func() error
+}
+
+var txType = reflect.TypeFor[*Tx]()
+
+// OnRollback registers undo to be called if a later action of the same trigger fails.
+// Compensations only run on machines created with WithRollback.
+func (tx *Tx) OnRollback(undo func() error) {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+	tx.undos = append(tx.undos, undo)
+}
+
+// rollback runs all registered compensations in reverse order.
+func (tx *Tx) rollback() error {
+	tx.mu.Lock()
+	undos := tx.undos
+	tx.undos = nil
+	tx.mu.Unlock()
+
+	var errs []error
+	for i := len(undos) - 1; i >= 0; i-- {
+		if err := undos[i](); err != nil {
+			errs = append(errs, fmt.Errorf("rollback: %w", err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// WithRollback makes trigger execution transactional: if an action fails, the compensations registered
+// by the preceding actions run in reverse order and the machine returns to the state it was in before the event.
+func WithRollback() InstanceOption {
+	return func(m *StateMachine) {
+		m.rollback = true
+	}
+}
+
+func (m *StateMachine) transaction(actions []Action, ctx map[string]Value) error {
+	prev, locals := m.current.Load(), m.locals
+	m.tx = &Tx{}
+	err := m.batch(actions, ctx)
+	tx := m.tx
+	m.tx = nil
+	if err == nil || !m.rollback {
+		return err
+	}
+	rerr := tx.rollback()
+	if m.current.Load() != prev {
+		rerr = errors.Join(rerr, m.enter(prev.Name, nil, false))
+		m.locals = locals
+	}
+	if rerr != nil {
+		return errors.Join(err, rerr)
+	}
+	return err
+}
@@ -0,0 +1,85 @@
+package mova
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"slices"
+)
+
+// TypeSpec describes a custom value type usable in machine files, see NewType.
+type TypeSpec struct {
+	Name    string                    // name in parameter lists and casts
+	Type    reflect.Type              // Go type of values
+	Pattern *regexp.Regexp            // syntax of literals, nil if values can only be cast from strings
+	Parse   func(string) (any, error) // converts a literal or string to a value
+	Equal   func(a, b any) bool       // compares values in conditions, nil to use ==
+}
+
+// NewType registers a custom type called name. Literals matching pattern are converted using parse,
+// strings can be converted using `name("...")`. If equal is nil, values are compared using ==.
+func NewType[T any](r *Registry, name string, pattern string, parse func(string) (T, error), equal func(a, b T) bool) {
+	if _, ok := typeNames[name]; ok {
+		panic(fmt.Errorf("cannot redefine builtin type %q", name))
+	}
+	spec := &TypeSpec{
+		Name: name,
+		Type: reflect.TypeFor[T](),
+		Parse: func(s string) (any, error) {
+			return parse(s)
+		},
+	}
+	if pattern != "" {
+		spec.Pattern = regexp.MustCompile(`^(` + pattern + `)`)
+	}
+	if equal != nil {
+		spec.Equal = func(a, b any) bool {
+			return equal(a.(T), b.(T))
+		}
+	}
+	r.types = slices.DeleteFunc(r.types, func(t *TypeSpec) bool { return t.Name == name })
+	r.types = append(r.types, spec)
+}
+
+func (r *Registry) customType(name string) *TypeSpec {
+	for _, t := range r.types {
+		if t.Name == name {
+			return t
+		}
+	}
+	return nil
+}
+
+func (r *Registry) typeFor(typ reflect.Type) *TypeSpec {
+	for _, t := range r.types {
+		if t.Type == typ {
+			return t
+		}
+	}
+	return nil
+}
+
+// typeByName resolves the name of a builtin or custom type.
+func (r *Registry) typeByName(name string) (reflect.Type, bool) {
+	if t := r.customType(name); t != nil {
+		return t.Type, true
+	}
+	typ, ok := typeNames[name]
+	return typ, ok
+}
+
+func literalToken(t *TypeSpec) string {
+	return "literal " + t.Name
+}
+
+// rules returns the lexer rules of custom tokens and literals of custom types,
+// which win over builtin tokens of the same length, see scan.
+func (r *Registry) rules() []rule {
+	custom := slices.Clone(r.tokens)
+	for _, t := range r.types {
+		if t.Pattern != nil {
+			custom = append(custom, rule{literalToken(t), t.Pattern})
+		}
+	}
+	return custom
+}
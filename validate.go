@@ -0,0 +1,73 @@
+package mova
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"maps"
+	"reflect"
+	"slices"
+)
+
+// ErrInvalidArgument is returned when an argument of an action is rejected by a validator, see SetValidator.
+var ErrInvalidArgument = errors.New("invalid argument")
+
+// Validator checks an argument of an action before the action is called.
+type Validator func(v any) error
+
+// SetValidator adds validators to an argument of a registered action. Constant arguments are
+// validated when building the machine, others before every call.
+func SetValidator(r *Registry, action, arg string, validators ...Validator) {
+	spec, ok := r.actions[action]
+	if !ok {
+		panic(fmt.Errorf("unspecified action %q", action))
+	}
+	if _, ok := spec.arg(arg); !ok {
+		panic(fmt.Errorf("unspecified argument %q for action %s", arg, action))
+	}
+	spec.Validators = maps.Clone(spec.Validators) // may be shared with a copy of the registry
+	if spec.Validators == nil {
+		spec.Validators = make(map[string][]Validator)
+	}
+	spec.Validators[arg] = append(slices.Clip(spec.Validators[arg]), validators...)
+	r.actions[action] = spec
+}
+
+// validate runs the validators of argument arg of action on v.
+func (spec ActionSpec) validate(action, arg string, v reflect.Value) error {
+	for _, check := range spec.Validators[arg] {
+		if err := check(v.Interface()); err != nil {
+			return fmt.Errorf("%w %s.%s: %w", ErrInvalidArgument, action, arg, err)
+		}
+	}
+	return nil
+}
+
+// InRange accepts values between min and max inclusive.
+func InRange[T cmp.Ordered](min, max T) Validator {
+	return func(v any) error {
+		x, ok := v.(T)
+		if !ok {
+			return fmt.Errorf("expected %T, got %T", min, v)
+		}
+		if x < min || x > max {
+			return fmt.Errorf("%v is not between %v and %v", x, min, max)
+		}
+		return nil
+	}
+}
+
+// NotEmpty rejects empty strings, slices and maps.
+func NotEmpty() Validator {
+	return func(v any) error {
+		switch rv := reflect.ValueOf(v); rv.Kind() {
+		case reflect.String, reflect.Slice, reflect.Map, reflect.Array:
+			if rv.Len() == 0 {
+				return errors.New("must not be empty")
+			}
+			return nil
+		default:
+			return fmt.Errorf("cannot check %T for emptiness", v)
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package mova
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"reflect"
+)
+
+// VarDecl declares a variable, written as
+//
+//	var retries: int = 0;
+//
+// Either the type or the initial value may be omitted, the zero value of the type is the default.
+// Declared at toplevel, it is an instance variable, see AssignStmt, which is set to its initial value
+// when an instance is created or reset. Unlike constants, instance variables are changed using `set`
+// and are part of snapshots.
+//
+// Declared at the start of a state block, it is a variable of the state, which is set to its initial
+// value whenever the state is entered, and visible to the actions and trigger conditions of the state.
+type VarDecl struct {
+	Span  Span
+	Name  string
+	Type  string // "" if inferred from Value
+	Value Value  // nil for the zero value
+}
+
+// stateVar is a compiled VarDecl.
+type stateVar struct {
+	name  string
+	typ   reflect.Type
+	value Value // nil for the zero value
+}
+
+// localVar is the type of a state variable while type checking, set by AssignStmt.
+type localVar struct {
+	TypeDummyValue
+}
+
+// check determines the type of the variable and folds its initial value.
+func (d *VarDecl) check(ctx map[string]Value, m *CompiledMachine) (stateVar, error) {
+	v := stateVar{name: d.Name}
+	if d.Type != "" {
+		typ, ok := m.reg.typeByName(d.Type)
+		if !ok {
+			return v, fmt.Errorf("unknown type %q for variable %q", d.Type, d.Name)
+		}
+		v.typ = typ
+	}
+	if d.Value == nil {
+		if v.typ == nil {
+			return v, fmt.Errorf("variable %q has neither type nor value", d.Name)
+		}
+		return v, nil
+	}
+	valuetype, err := d.Value.EvalType(ctx)
+	if err != nil {
+		return v, fmt.Errorf("cannot determine type of variable %q: %w", d.Name, err)
+	}
+	if v.typ == nil && valuetype == nil {
+		return v, fmt.Errorf("cannot determine type of variable %q: untyped nil, declare its type", d.Name)
+	} else if v.typ == nil {
+		v.typ = valuetype
+	} else if !coercible(valuetype, v.typ) {
+		return v, fmt.Errorf("type mismatch for variable %q: expected %v, got %s", d.Name, v.typ, typeString(valuetype))
+	}
+	v.value, err = fold(d.Value, ctx, 0)
+	return v, err
+}
+
+func (d *VarDecl) String() string {
+	text := "var " + formatName(d.Name)
+	if d.Type != "" {
+		text += ": " + d.Type
+	}
+	if d.Value != nil {
+		text += " = " + formatValue(d.Value)
+	}
+	return text + ";"
+}
+
+// EvalToplevel does nothing, instance variables are declared before states are checked, see declareVars.
+func (d *VarDecl) EvalToplevel(m *CompiledMachine) error {
+	return nil
+}
+
+// initialValues evaluates the initial values of vars in order and adds them to ctx.
+func initialValues(vars []stateVar, ctx map[string]Value) (map[string]any, error) {
+	if len(vars) == 0 {
+		return nil, nil
+	}
+	out := make(map[string]any, len(vars))
+	for _, v := range vars {
+		eval := reflect.Zero(v.typ).Interface()
+		if v.value != nil {
+			var err error
+			if eval, err = v.value.EvalValue(ctx); err != nil {
+				return nil, fmt.Errorf("cannot evaluate variable %q: %w", v.name, err)
+			}
+			eval = coerce(eval, v.typ)
+		}
+		out[v.name] = eval
+		ctx[v.name] = &ConstValue{eval}
+	}
+	return out, nil
+}
+
+// resetLocals sets the variables of st, which was entered, to their initial values and adds them to ctx.
+func (m *StateMachine) resetLocals(st *CompiledState, ctx map[string]Value) error {
+	m.locals = nil
+	locals, err := initialValues(st.vars, ctx)
+	if err != nil {
+		return fmt.Errorf("in state %s: %w", st.Name, err)
+	}
+	m.locals = locals
+	return nil
+}
+
+// resetVars sets the instance variables to their initial values, assigned ones without declaration are unset.
+func (m *StateMachine) resetVars() error {
+	m.vars = nil
+	vars, err := initialValues(m.globals, m.scope())
+	m.vars = vars
+	return err
+}
+
+// restoreValue converts v, a variable of a snapshot, to typ. Snapshots decoded from JSON hold
+// numbers as float64 and structs as maps, which are converted by encoding them again.
+func restoreValue(v any, typ reflect.Type) (any, error) {
+	if v != nil && reflect.TypeOf(v) == typ {
+		return v, nil
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	out := reflect.New(typ)
+	if err := json.Unmarshal(data, out.Interface()); err != nil {
+		return nil, err
+	}
+	return out.Elem().Interface(), nil
+}
+
+// Locals returns the variables of the current state, see VarDecl.
+func (m *StateMachine) Locals() map[string]any {
+	return maps.Clone(m.locals)
+}
@@ -0,0 +1,204 @@
+package mova
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+)
+
+// Node is an element of the AST: *File, *State, *SetStmt, *VarDecl, *Trigger, *TriggerCond, a Statement or a Value.
+// The branches of a *ChoiceStmt are visited as *MoveStmt, the call of a *CaptureStmt as *Call.
+// Statements added by extensions are visited, but not their contents.
+type Node any
+
+// Visitor is called by Walk for every node. If it returns a non-nil visitor w,
+// the children of the node are visited with w followed by a call of w.Visit(nil).
+type Visitor interface {
+	Visit(node Node) (w Visitor)
+}
+
+// Walk traverses the AST in source order, arguments of calls and moves sorted by name.
+// Nodes are pointers into the tree and may be modified, see Rewrite to replace them.
+func Walk(node Node, v Visitor) {
+	if v = v.Visit(node); v == nil {
+		return
+	}
+	walkArgs := func(args map[string]Value) {
+		for _, key := range slices.Sorted(maps.Keys(args)) {
+			Walk(args[key], v)
+		}
+	}
+	switch n := node.(type) {
+	case *File:
+		for _, e := range n.Entries {
+			Walk(e, v)
+		}
+	case *SetStmt:
+		Walk(n.Value, v)
+	case *VarDecl:
+		if n.Value != nil {
+			Walk(n.Value, v)
+		}
+	case *State:
+		for _, decl := range n.Vars {
+			Walk(decl, v)
+		}
+		for _, stmt := range n.Init {
+			Walk(stmt, v)
+		}
+		for _, stmt := range n.Compensate {
+			Walk(stmt, v)
+		}
+		for i := range n.Triggers {
+			Walk(&n.Triggers[i], v)
+		}
+	case *Trigger:
+		for i := range n.Cond {
+			Walk(&n.Cond[i], v)
+		}
+		for _, stmt := range n.Actions {
+			Walk(stmt, v)
+		}
+	case *TriggerCond:
+		for _, p := range n.Params {
+			if p.Value != nil {
+				Walk(p.Value, v)
+			}
+		}
+	case *Call:
+		walkArgs(n.Args)
+	case *MoveStmt:
+		walkArgs(n.Args)
+	case *ScheduleStmt:
+		walkArgs(n.Args)
+	case *AssignStmt:
+		Walk(n.Value, v)
+	case *CaptureStmt:
+		Walk(n.Call, v)
+	case *IfStmt:
+		Walk(n.Cond, v)
+		for _, stmt := range slices.Concat(n.Then, n.Else) {
+			Walk(stmt, v)
+		}
+	case *ParallelStmt:
+		for _, stmt := range n.Actions {
+			Walk(stmt, v)
+		}
+	case *ChoiceStmt:
+		for _, b := range n.Branches {
+			Walk(b.Move, v)
+		}
+	case *CastValue:
+		Walk(n.Value, v)
+	case *FuncValue:
+		for _, arg := range n.Args {
+			Walk(arg, v)
+		}
+	}
+	v.Visit(nil)
+}
+
+type inspector func(Node) bool
+
+func (f inspector) Visit(node Node) Visitor {
+	if node != nil && f(node) {
+		return f
+	}
+	return nil
+}
+
+// Inspect calls f for every node in the AST, the children of a node are skipped if f returns false.
+func Inspect(node Node, f func(Node) bool) {
+	Walk(node, inspector(f))
+}
+
+// Rewrite calls f for every node after its children and replaces the node by the result.
+// Entries, statements and values may be replaced by nodes of another type, as long as they
+// fit where they are used. It returns the rewritten node.
+func Rewrite(node Node, f func(Node) Node) Node {
+	args := func(args map[string]Value) {
+		for key, val := range args {
+			args[key] = rewriteAs[Value](val, f)
+		}
+	}
+	switch n := node.(type) {
+	case *File:
+		for i, e := range n.Entries {
+			n.Entries[i] = rewriteAs[Entry](e, f)
+		}
+	case *SetStmt:
+		n.Value = rewriteAs[Value](n.Value, f)
+	case *VarDecl:
+		if n.Value != nil {
+			n.Value = rewriteAs[Value](n.Value, f)
+		}
+	case *State:
+		for i, decl := range n.Vars {
+			n.Vars[i] = rewriteAs[*VarDecl](decl, f)
+		}
+		for i, stmt := range n.Init {
+			n.Init[i] = rewriteAs[Statement](stmt, f)
+		}
+		for i, stmt := range n.Compensate {
+			n.Compensate[i] = rewriteAs[Statement](stmt, f)
+		}
+		for i := range n.Triggers {
+			n.Triggers[i] = *rewriteAs[*Trigger](&n.Triggers[i], f)
+		}
+	case *Trigger:
+		for i := range n.Cond {
+			n.Cond[i] = *rewriteAs[*TriggerCond](&n.Cond[i], f)
+		}
+		for i, stmt := range n.Actions {
+			n.Actions[i] = rewriteAs[Statement](stmt, f)
+		}
+	case *TriggerCond:
+		for i, p := range n.Params {
+			if p.Value != nil {
+				n.Params[i].Value = rewriteAs[Value](p.Value, f)
+			}
+		}
+	case *Call:
+		args(n.Args)
+	case *MoveStmt:
+		args(n.Args)
+	case *ScheduleStmt:
+		args(n.Args)
+	case *AssignStmt:
+		n.Value = rewriteAs[Value](n.Value, f)
+	case *CaptureStmt:
+		n.Call = rewriteAs[*Call](n.Call, f)
+	case *IfStmt:
+		n.Cond = rewriteAs[Value](n.Cond, f)
+		for i, stmt := range n.Then {
+			n.Then[i] = rewriteAs[Statement](stmt, f)
+		}
+		for i, stmt := range n.Else {
+			n.Else[i] = rewriteAs[Statement](stmt, f)
+		}
+	case *ParallelStmt:
+		for i, stmt := range n.Actions {
+			n.Actions[i] = rewriteAs[Statement](stmt, f)
+		}
+	case *ChoiceStmt:
+		for i, b := range n.Branches {
+			n.Branches[i].Move = rewriteAs[*MoveStmt](b.Move, f)
+		}
+	case *CastValue:
+		n.Value = rewriteAs[Value](n.Value, f)
+	case *FuncValue:
+		for i, arg := range n.Args {
+			n.Args[i] = rewriteAs[Value](arg, f)
+		}
+	}
+	return f(node)
+}
+
+func rewriteAs[T any](node Node, f func(Node) Node) T {
+	out := Rewrite(node, f)
+	t, ok := out.(T)
+	if !ok {
+		panic(fmt.Errorf("cannot replace %T by %T", node, out))
+	}
+	return t
+}